@@ -0,0 +1,171 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// LRU adapts a cache to the method set of the LRUCache interface from
+// hashicorp/golang-lru, with string keys and byte slice values, so that it can stand
+// in wherever that interface is expected. Those methods have no way to return errors,
+// so failures are reported as misses and the most recent error is kept for Err.
+type LRU struct {
+	c *Cache
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewLRU creates an adapter for the given cache. Resizing the adapter does not affect
+// the limits of c itself.
+func NewLRU(c *Cache) *LRU {
+	cc := *c
+	return &LRU{c: &cc}
+}
+
+// Err returns the most recent error encountered by the adapter and clears it
+func (a *LRU) Err() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	err := a.err
+	a.err = nil
+	return err
+}
+
+// fail records err unless it is nil or a missing entry
+func (a *LRU) fail(err error) {
+	if err == nil || os.IsNotExist(err) || err == ErrEmpty {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.err = err
+}
+
+// Add sets the value for the given key and reports whether any entry was evicted
+func (a *LRU) Add(key string, value []byte) (evicted bool) {
+	err := a.c.update(func(x *state) error {
+		err := a.c.put(x, a.c.id([]byte(key)), value, nil)
+		if err != nil {
+			return err
+		}
+		before := x.total().Entries
+		err = a.c.evict(x, a.c.ns)
+		evicted = x.total().Entries < before
+		return err
+	})
+	a.fail(err)
+	return evicted
+}
+
+// Get returns the value for the given key and marks it as recently used
+func (a *LRU) Get(key string) (value []byte, ok bool) {
+	value, err := a.c.GetString(key)
+	a.fail(err)
+	return value, err == nil
+}
+
+// Contains reports whether the key is present without marking it as recently used
+func (a *LRU) Contains(key string) bool {
+	found, err := a.c.Exists([]byte(key))
+	a.fail(err)
+	return found
+}
+
+// Peek returns the value for the given key without marking it as recently used
+func (a *LRU) Peek(key string) (value []byte, ok bool) {
+	value, err := a.peek(a.c.id([]byte(key)))
+	a.fail(err)
+	return value, err == nil
+}
+
+// peek reads the value for the given identifier without changing the list
+func (a *LRU) peek(id []byte) ([]byte, error) {
+	var value []byte
+	err := a.c.view(func() error {
+		found, err := a.c.exists(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &os.PathError{Op: "peek", Path: a.c.path(id), Err: os.ErrNotExist}
+		}
+		value, err = ioutil.ReadFile(a.c.path(id))
+		return err
+	})
+	return value, err
+}
+
+// Remove removes the given key and reports whether it was present
+func (a *LRU) Remove(key string) bool {
+	err := a.c.DeleteString(key)
+	a.fail(err)
+	return err == nil
+}
+
+// RemoveOldest removes the least recently used entry and returns it
+func (a *LRU) RemoveOldest() (key string, value []byte, ok bool) {
+	k, value, err := a.c.Pop()
+	a.fail(err)
+	return string(k), value, err == nil
+}
+
+// GetOldest returns the least recently used entry without marking it as recently used
+func (a *LRU) GetOldest() (key string, value []byte, ok bool) {
+	k, err := a.c.Oldest()
+	if err != nil || k == nil {
+		a.fail(err)
+		return "", nil, false
+	}
+	value, ok = a.Peek(string(k))
+	return string(k), value, ok
+}
+
+// Keys returns the keys from least to most recently used, as golang-lru does
+func (a *LRU) Keys() []string {
+	keys, err := a.c.Keys()
+	a.fail(err)
+	out := make([]string, len(keys))
+	for i, key := range keys {
+		out[len(keys)-1-i] = string(key)
+	}
+	return out
+}
+
+// Values returns the values from least to most recently used
+func (a *LRU) Values() [][]byte {
+	var values [][]byte
+	for _, key := range a.Keys() {
+		if value, ok := a.Peek(key); ok {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// Len returns the number of entries in the cache
+func (a *LRU) Len() int {
+	s, err := a.c.Stats()
+	a.fail(err)
+	return int(s.Entries)
+}
+
+// Purge removes every entry
+func (a *LRU) Purge() {
+	a.fail(a.c.DeleteMatching(func([]byte) bool { return true }))
+}
+
+// Resize changes the maximum number of entries and returns the number evicted
+func (a *LRU) Resize(size int) (evicted int) {
+	err := a.c.update(func(x *state) error {
+		// the limits are only read with the lock held
+		a.c.limits.entries = int64(size)
+		before := x.total().Entries
+		err := a.c.evict(x, a.c.ns)
+		evicted = int(before - x.total().Entries)
+		return err
+	})
+	a.fail(err)
+	return evicted
+}
@@ -0,0 +1,95 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUAdapter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxEntries(2))
+	require.NoError(t, err)
+
+	a := NewLRU(c)
+
+	assert.False(t, a.Add("a", []byte("1")))
+	assert.False(t, a.Add("b", []byte("2")))
+	assert.True(t, a.Add("c", []byte("3")))
+	assert.Equal(t, 2, a.Len())
+	assert.Equal(t, []string{"b", "c"}, a.Keys())
+
+	// peeking does not change the order
+	val, ok := a.Peek("b")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("2"), val)
+	assert.Equal(t, []string{"b", "c"}, a.Keys())
+
+	_, ok = a.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, []string{"c", "b"}, a.Keys())
+
+	key, val, ok := a.GetOldest()
+	assert.True(t, ok)
+	assert.Equal(t, "c", key)
+	assert.Equal(t, []byte("3"), val)
+
+	assert.False(t, a.Contains("a"))
+	_, ok = a.Get("a")
+	assert.False(t, ok)
+
+	assert.Equal(t, 1, a.Resize(1))
+	assert.Equal(t, []string{"b"}, a.Keys())
+
+	key, _, ok = a.RemoveOldest()
+	assert.True(t, ok)
+	assert.Equal(t, "b", key)
+
+	a.Add("d", nil)
+	a.Purge()
+	assert.Equal(t, 0, a.Len())
+	assert.False(t, a.Remove("d"))
+	assert.NoError(t, a.Err())
+
+	// resizing the adapter leaves the cache's own limit alone
+	assert.EqualValues(t, 2, c.limits.entries)
+}
+
+func TestLRUAdapterConcurrentResize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+	a := NewLRU(c)
+
+	// run under the race detector to check that resizing is synchronized with adds
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			a.Add(strconv.Itoa(i), nil)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			a.Resize(5 + i%3)
+		}
+	}()
+	wg.Wait()
+
+	a.Resize(5)
+	assert.Equal(t, 5, a.Len())
+	assert.NoError(t, a.Err())
+}
@@ -0,0 +1,147 @@
+package lrudir
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// adoptee is a file found in a directory being adopted
+type adoptee struct {
+	name  string
+	id    []byte
+	mtime int64
+}
+
+// Adopt initializes a cache in a directory that already holds values, such as one
+// managed by hand or by another tool, and registers every file and directory in it as
+// an entry whose key is its name. Entries are ordered by modification time, with the
+// most recently modified at the head, and that time is recorded as when each was last
+// used. Values whose names differ from the escaped form of their key are renamed. Size
+// limits given as options are applied once every value has been registered. Adopt
+// fails without changing anything if the directory already holds a cache or if some
+// name is not a valid key, or two names map to the same key.
+func Adopt(path string, opts ...Option) (*Cache, error) {
+	var cfg Cache
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var found []adoptee
+	seen := make(map[string]string)
+	for _, f := range files {
+		key := []byte(f.Name())
+		err = cfg.ValidateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("cannot adopt %s: %v", filepath.Join(path, f.Name()), err)
+		}
+		id := cfg.id(key)
+		if other, ok := seen[string(id)]; ok {
+			return nil, fmt.Errorf("cannot adopt both %s and %s as they have the same key", other, f.Name())
+		}
+		seen[string(id)] = f.Name()
+		found = append(found, adoptee{name: f.Name(), id: id, mtime: f.ModTime().UnixNano()})
+	}
+
+	// the least recently modified goes in first so that it ends up at the tail
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].mtime < found[j].mtime
+	})
+
+	c, err := Create(path, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.update(func(x *state) error {
+		return c.adopt(x, found)
+	})
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// adopt registers files already in the cache directory as entries, in the order given.
+// Values that must be renamed are first moved aside into the metadata directory, since
+// the name one of them needs may be held by another that has not moved yet. Nothing
+// staged there ends in "~tmp", so if this process crashes the values are left for the
+// user to recover rather than rolled back. It must be called with the lock held.
+func (c *Cache) adopt(x *state, found []adoptee) error {
+	aside, err := ioutil.TempDir(c.internalPath(""), "adopt~")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(aside)
+
+	var moved []int
+	for i, f := range found {
+		if filepath.Join(c.Dir, f.name) == c.path(f.id) {
+			continue
+		}
+		err = os.Rename(filepath.Join(c.Dir, f.name), filepath.Join(aside, strconv.Itoa(i)))
+		if err != nil {
+			return err
+		}
+		moved = append(moved, i)
+	}
+	for _, i := range moved {
+		err = os.Rename(filepath.Join(aside, strconv.Itoa(i)), c.path(found[i].id))
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, f := range found {
+		err = c.register(x, f.id, &meta{Used: f.mtime})
+		if err != nil {
+			return err
+		}
+	}
+
+	x.Usage, err = c.count()
+	if err != nil {
+		return err
+	}
+	c.debug("adopted existing values", "dir", c.Dir, "entries", len(found), "renamed", len(moved))
+	return c.evict(x, c.ns)
+}
+
+// register makes the value file already in place for the given identifier an entry
+// with the given metadata, moving it to the head of the list. The usage is left for the
+// caller to recount. It must be called with the lock held.
+func (c *Cache) register(x *state, id []byte, m *meta) error {
+	err := c.journal(id)
+	if err != nil {
+		return err
+	}
+	err = c.writeMeta(id, m)
+	if err != nil {
+		return err
+	}
+	err = c.writeKey(id)
+	if err != nil {
+		return err
+	}
+
+	err = c.detach(id)
+	if errors.Is(err, ErrCorrupt) {
+		err = c.repairList(x)
+		if err == nil {
+			err = c.detach(id)
+		}
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return c.attachHead(id)
+}
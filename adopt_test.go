@@ -0,0 +1,74 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdopt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// ".b" must be renamed to a name that "#5cb" holds until it moves too
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, name := range []string{"a", ".b", "#5cb", "c"} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(path, []byte("value-"+name), 0777))
+		mtime := base.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, os.Chtimes(path, mtime, mtime))
+	}
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "d"), 0777))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "d", "x"), []byte("xyz"), 0777))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "d"), base.Add(-time.Hour), base.Add(-time.Hour)))
+
+	c, err := Adopt(dir, WithMaxEntries(4))
+	require.NoError(t, err)
+	defer c.Close()
+
+	// the directory was oldest, so it was evicted to fit
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("c"), []byte("#5cb"), []byte(".b"), []byte("a")}, keys)
+
+	info, err := c.Stat([]byte("a"))
+	require.NoError(t, err)
+	assert.True(t, info.LastUsed.Equal(base))
+
+	for _, name := range []string{"a", ".b", "#5cb", "c"} {
+		val, err := c.Get([]byte(name))
+		require.NoError(t, err)
+		assert.Equal(t, "value-"+name, string(val))
+	}
+
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, s.Entries)
+	require.NoError(t, c.Verify())
+
+	// a directory that is already a cache is refused
+	_, err = Adopt(dir)
+	assert.True(t, os.IsExist(err))
+}
+
+func TestAdoptInvalidKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "toolong"), nil, 0777))
+
+	_, err = Adopt(dir, WithMaxKeyBytes(3))
+	assert.Error(t, err)
+
+	// nothing was changed
+	initialized, err := isCache(dir)
+	require.NoError(t, err)
+	assert.False(t, initialized)
+}
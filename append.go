@@ -0,0 +1,132 @@
+package lrudir
+
+import "os"
+
+// Append adds data to the end of the value for the given key, creating the entry if it
+// does not exist, and moves it to the head of the list. The value file grows in place
+// rather than being rewritten, except when it is shared with another entry by Copy, in
+// which case it is first copied so that the other entry is unaffected.
+func (c *Cache) Append(key, data []byte) error {
+	err := c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+
+	// the value grows in place, so readers holding only its shard must be kept out
+	id := c.id(key)
+	if s := c.shard(id); s != nil {
+		err = s.lock()
+		if err != nil {
+			return err
+		}
+		defer s.unlock()
+	}
+
+	return c.update(func(x *state) error {
+		err := c.append(x, id, data)
+		if err != nil {
+			return err
+		}
+		return c.evict(x, c.ns)
+	})
+}
+
+// append adds data to the end of the value for the given identifier
+func (c *Cache) append(x *state, id, data []byte) error {
+	path := c.path(id)
+	st, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return c.put(x, id, data, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	// an entry that has expired or been invalidated is replaced rather than extended
+	m, err := c.readMeta(id)
+	if err != nil {
+		return err
+	}
+	reason, err := c.invalid(m)
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		err = c.discard(x, id, EventEvict, reason)
+		if err != nil {
+			return err
+		}
+		return c.put(x, id, data, nil)
+	}
+
+	n, err := c.fit(st.Size() + int64(len(data)))
+	if err != nil {
+		return err
+	}
+	// keep as much of the data as fits, which is none if the limit has been lowered
+	if keep := n - st.Size(); keep < 0 {
+		data = nil
+	} else if keep < int64(len(data)) {
+		data = data[:keep]
+	}
+
+	if linkCount(st) != 1 {
+		// break the link so that other entries sharing this file are not modified
+		tmp := c.tempPath(id)
+		err = copyContents(path, tmp)
+		if err != nil {
+			return err
+		}
+		err = c.replace(tmp, path)
+		if err != nil {
+			return err
+		}
+
+		// the value no longer shares a blob with other entries
+		m, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+		if m.Blob != "" {
+			blob := m.Blob
+			m.Blob = ""
+			err = c.writeMeta(id, m)
+			if err != nil {
+				return err
+			}
+			err = c.releaseBlob(blob)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0777)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	ns, _ := splitID(id)
+	x.usage(ns).Bytes += c.roundToBlock(st.Size()+int64(len(data))) - c.roundToBlock(st.Size())
+
+	if !c.noPromoteOnPut {
+		err = c.promote(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.record(EventPut, id, 0)
+	return nil
+}
@@ -0,0 +1,57 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAppend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.Append(k1, []byte("abc"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, nil)
+	require.NoError(t, err)
+
+	err = c.Append(k1, []byte("def"))
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1, k2}, keys)
+
+	val, err := c.Get(k1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abcdef"), val)
+
+	// appending to a copy leaves the original untouched
+	err = c.Copy(k1, k2)
+	require.NoError(t, err)
+
+	err = c.Append(k2, []byte("ghi"))
+	require.NoError(t, err)
+
+	val, err = c.Get(k1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abcdef"), val)
+
+	val, err = c.Get(k2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abcdefghi"), val)
+
+	x, err := c.state()
+	require.NoError(t, err)
+	assert.EqualValues(t, usage{Entries: 2, Bytes: 15, Inodes: 8}, *x.Usage[""])
+}
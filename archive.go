@@ -0,0 +1,309 @@
+package lrudir
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// manifest describes the entries in an exported archive, or is itself exported by
+// ExportManifest
+type manifest struct {
+	Entries []manifestEntry `json:"entries"` // from least to most recently used
+}
+
+// manifestEntry describes one entry in an exported archive or manifest
+type manifestEntry struct {
+	Namespace string `json:"namespace,omitempty"` // relative to the exported cache
+	Key       []byte `json:"key"`
+	Meta      *meta  `json:"meta,omitempty"`
+	File      string `json:"file,omitempty"` // name of the value file, for ExportManifest
+}
+
+// contains returns true if the given identifier belongs to this cache's namespace or to
+// a namespace nested within it. The root namespace contains everything.
+func (c *Cache) contains(id []byte) bool {
+	ns, _ := splitID(id)
+	return c.ns == "" || ns == c.ns || strings.HasPrefix(ns, c.ns+"/")
+}
+
+// relative gets the namespace of the given identifier relative to this cache's
+// namespace, along with its key
+func (c *Cache) relative(id []byte) (string, []byte) {
+	ns, key := splitID(id)
+	switch {
+	case ns == c.ns:
+		return "", key
+	case c.ns == "":
+		return ns, key
+	default:
+		return strings.TrimPrefix(ns, c.ns+"/"), key
+	}
+}
+
+// nested gets a view of the namespace with the given name relative to this cache,
+// where the empty name refers to this cache's own namespace and a slash separates the
+// names of nested namespaces
+func (c *Cache) nested(name string) (*Cache, error) {
+	if name == "" {
+		return c, nil
+	}
+	for _, part := range strings.Split(name, "/") {
+		err := checkNamespace(part)
+		if err != nil {
+			return nil, err
+		}
+		c = c.Namespace(part)
+	}
+	return c, nil
+}
+
+// Export writes every entry in this cache's namespace and any namespaces nested within
+// it to w as a tar archive, including values, metadata, and recency order. The lock is
+// held for the duration so the archive is a consistent snapshot.
+func (c *Cache) Export(w io.Writer) error {
+	err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	var ids [][]byte
+	var m manifest
+	err = c.walkBack(func(id []byte) error {
+		if !c.contains(id) {
+			return nil
+		}
+
+		md, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+		md.Blob = ""
+
+		ns, key := c.relative(id)
+		m.Entries = append(m.Entries, manifestEntry{Namespace: ns, Key: key, Meta: md})
+		ids = append(ids, id)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	buf, err := json.Marshal(&m)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	err = tw.WriteHeader(&tar.Header{
+		Name:     "manifest.json",
+		Mode:     0644,
+		Size:     int64(len(buf)),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = tw.Write(buf)
+	if err != nil {
+		return err
+	}
+
+	for i, id := range ids {
+		err = writeTree(tw, c.path(id), "values/"+strconv.Itoa(i))
+		if err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// writeTree adds the file or directory tree at src to the archive under the given name
+func writeTree(tw *tar.Writer, src, name string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(name, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			hdr.Linkname, err = os.Readlink(p)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = tw.WriteHeader(hdr)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// Import reads a tar archive produced by Export and adds its entries to this cache,
+// replacing any existing entries with the same keys. Imported entries keep their
+// relative recency order and become more recent than all existing entries.
+func (c *Cache) Import(r io.Reader) error {
+	// stage the values inside the cache directory so they can be renamed into place
+	staging, err := ioutil.TempDir(c.internalPath(""), "import~")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	var m *manifest
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		if hdr.Name == "manifest.json" {
+			m = new(manifest)
+			err = json.NewDecoder(tr).Decode(m)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		err = extract(tr, hdr, staging)
+		if err != nil {
+			return err
+		}
+	}
+	if m == nil {
+		return errors.New("archive does not contain a manifest")
+	}
+
+	return c.update(func(x *state) error {
+		for i, e := range m.Entries {
+			ns, err := c.nested(e.Namespace)
+			if err != nil {
+				return err
+			}
+			err = ns.ValidateKey(e.Key)
+			if err != nil {
+				return err
+			}
+			value := filepath.Join(staging, "values", strconv.Itoa(i))
+
+			// blobs are never shared with another cache, and the name of one would be
+			// taken as a path, since the archive may come from anywhere
+			md := e.Meta.clone()
+			md.Blob = ""
+			err = c.commit(x, ns.id(e.Key), value, md)
+			if err != nil {
+				return err
+			}
+		}
+		return c.evict(x, c.ns)
+	})
+}
+
+// extract writes a single file from a tar archive beneath dir. Only regular files and
+// directories are accepted, along with symbolic links that are themselves a whole value
+// as stored by PutLink, so that nothing is ever written through a link.
+func extract(tr *tar.Reader, hdr *tar.Header, dir string) error {
+	name := path.Clean(hdr.Name)
+	parts := strings.Split(name, "/")
+	if len(parts) < 2 || parts[0] != "values" {
+		return errors.New("unexpected file in archive: " + hdr.Name)
+	}
+	if _, err := strconv.Atoi(parts[1]); err != nil {
+		return errors.New("unexpected file in archive: " + hdr.Name)
+	}
+	target := filepath.Join(dir, filepath.FromSlash(name))
+
+	err := checkParents(dir, parts[:len(parts)-1])
+	if err != nil {
+		return err
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(target, 0777)
+	case tar.TypeSymlink:
+		if len(parts) != 2 {
+			return errors.New("unsupported link in archive: " + hdr.Name)
+		}
+		return os.Symlink(hdr.Linkname, target)
+	case tar.TypeReg:
+		err := os.MkdirAll(filepath.Dir(target), 0777)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0777)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(f, tr)
+		if err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	default:
+		return errors.New("unsupported file type in archive: " + hdr.Name)
+	}
+}
+
+// checkParents returns an error if any of the directories named by the given path
+// components beneath dir exists as something other than a directory, such as a link
+// extracted earlier from the same archive
+func checkParents(dir string, parts []string) error {
+	p := dir
+	for _, part := range parts {
+		p = filepath.Join(p, part)
+		st, err := os.Lstat(p)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !st.IsDir() {
+			return fmt.Errorf("archive places files beneath %s, which is not a directory", p)
+		}
+	}
+	return nil
+}
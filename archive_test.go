@@ -0,0 +1,151 @@
+package lrudir
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	src, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(src)
+
+	dst, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dst)
+
+	a, err := Create(src)
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	err = a.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = a.PutWithTags(k2, []byte("two"), "t")
+	require.NoError(t, err)
+
+	err = a.Namespace("ns").Put(k3, []byte("three"))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = a.Export(&buf)
+	require.NoError(t, err)
+
+	b, err := Create(dst)
+	require.NoError(t, err)
+
+	err = b.Import(&buf)
+	require.NoError(t, err)
+
+	keys, err := b.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k2, k1}, keys)
+
+	val, err := b.Namespace("ns").Get(k3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("three"), val)
+
+	val, err = b.Get(k1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	err = b.InvalidateTag("t")
+	require.NoError(t, err)
+
+	keys, err = b.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1}, keys)
+}
+
+// craftArchive builds an archive with the given manifest followed by the given headers,
+// each regular file holding the content "x"
+func craftArchive(t *testing.T, manifest string, hdrs ...*tar.Header) *bytes.Buffer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0644, Size: int64(len(manifest)), Typeflag: tar.TypeReg}))
+	_, err := tw.Write([]byte(manifest))
+	require.NoError(t, err)
+	for _, hdr := range hdrs {
+		if hdr.Typeflag == tar.TypeReg {
+			hdr.Size = 1
+		}
+		require.NoError(t, tw.WriteHeader(hdr))
+		if hdr.Typeflag == tar.TypeReg {
+			_, err = tw.Write([]byte("x"))
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, tw.Close())
+	return &buf
+}
+
+func TestImportRejectsWritesThroughLinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	outside, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(outside)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	buf := craftArchive(t, `{"entries":[{"key":"YQ=="}]}`,
+		&tar.Header{Name: "values/0", Typeflag: tar.TypeSymlink, Linkname: outside},
+		&tar.Header{Name: "values/0/pwned", Typeflag: tar.TypeReg, Mode: 0644})
+	assert.Error(t, c.Import(buf))
+
+	_, err = os.Stat(filepath.Join(outside, "pwned"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestImportIgnoresBlobs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	outside, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(outside)
+
+	victim := filepath.Join(outside, "victim")
+	require.NoError(t, ioutil.WriteFile(victim, nil, 0644))
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	rel, err := filepath.Rel(c.internalPath("blobs"), victim)
+	require.NoError(t, err)
+	buf := craftArchive(t, `{"entries":[{"key":"YQ==","meta":{"blob":"`+filepath.ToSlash(rel)+`"}}]}`,
+		&tar.Header{Name: "values/0", Typeflag: tar.TypeReg, Mode: 0644})
+	require.NoError(t, c.Import(buf))
+
+	require.NoError(t, c.Delete([]byte("a")))
+	_, err = os.Stat(victim)
+	assert.NoError(t, err)
+	require.NoError(t, c.Verify())
+}
+
+func TestImportRejectsNamespaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	buf := craftArchive(t, `{"entries":[{"namespace":"a\u0000b","key":"YQ=="}]}`,
+		&tar.Header{Name: "values/0", Typeflag: tar.TypeReg, Mode: 0644})
+	assert.Error(t, c.Import(buf))
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
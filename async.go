@@ -0,0 +1,163 @@
+package lrudir
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// Defaults for the pool that performs writes for PutAsync
+const (
+	defaultAsyncWorkers = 4
+	defaultAsyncQueue   = 64
+)
+
+// WithAsyncWriters sets the number of goroutines that perform writes for PutAsync, and
+// how many writes each one buffers before PutAsync blocks. It only has an effect if
+// given to the handle whose PutAsync starts the pool.
+func WithAsyncWriters(workers, queueSize int) Option {
+	return func(c *Cache) {
+		c.asyncWorkers = workers
+		c.asyncQueue = queueSize
+	}
+}
+
+// writer is the pool of goroutines that performs writes for PutAsync. Each key is
+// always written by the same goroutine, so writes to one key happen in order.
+type writer struct {
+	queues  []chan asyncOp
+	workers sync.WaitGroup // counts running goroutines
+	mu      sync.Mutex     // guards the fields below
+	done    *sync.Cond     // signalled when pending drops to zero
+	pending int            // number of writes queued but not yet performed
+	err     error          // first error from a write since the last Flush
+	stopped bool           // whether the pool has been stopped by Close
+}
+
+// asyncOp is a write waiting to be performed
+type asyncOp struct {
+	c          *Cache // the handle, and so the namespace, to write through
+	key, value []byte
+}
+
+// PutAsync queues the given value to be written for the given key by a background
+// goroutine, and returns without waiting for the write. The key and value are copied,
+// so the caller may reuse them. Writes to the same key are performed in the order they
+// were queued, but a Get may not see a queued write until Flush has returned. Errors
+// from queued writes are reported by Flush. Close waits for queued writes to finish.
+func (c *Cache) PutAsync(key, value []byte) error {
+	err := c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+
+	w := c.writer()
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return ErrClosed
+	}
+	w.pending++
+	w.mu.Unlock()
+
+	h := fnv.New32a()
+	h.Write(c.id(key))
+	q := w.queues[h.Sum32()%uint32(len(w.queues))]
+	q <- asyncOp{
+		c:     c,
+		key:   append([]byte(nil), key...),
+		value: append([]byte(nil), value...),
+	}
+	return nil
+}
+
+// Flush waits until every write queued by PutAsync so far has been performed, and
+// returns the first error encountered by any of them since the last Flush.
+func (c *Cache) Flush() error {
+	c.shared.wmu.Lock()
+	w := c.shared.writer
+	c.shared.wmu.Unlock()
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.pending > 0 {
+		w.done.Wait()
+	}
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// writer gets the pool for PutAsync, starting it if necessary
+func (c *Cache) writer() *writer {
+	c.shared.wmu.Lock()
+	defer c.shared.wmu.Unlock()
+	if c.shared.writer != nil {
+		return c.shared.writer
+	}
+
+	workers, size := c.asyncWorkers, c.asyncQueue
+	if workers <= 0 {
+		workers = defaultAsyncWorkers
+	}
+	if size <= 0 {
+		size = defaultAsyncQueue
+	}
+
+	w := &writer{queues: make([]chan asyncOp, workers)}
+	w.done = sync.NewCond(&w.mu)
+	for i := range w.queues {
+		w.queues[i] = make(chan asyncOp, size)
+		w.workers.Add(1)
+		go w.run(w.queues[i])
+	}
+	c.shared.writer = w
+	return w
+}
+
+// run performs the writes from one queue until it is closed
+func (w *writer) run(q chan asyncOp) {
+	defer w.workers.Done()
+	for op := range q {
+		err := op.c.Put(op.key, op.value)
+		w.mu.Lock()
+		if err != nil && w.err == nil {
+			w.err = err
+		}
+		w.pending--
+		if w.pending == 0 {
+			w.done.Broadcast()
+		}
+		w.mu.Unlock()
+	}
+}
+
+// stopWriter waits for queued writes to finish and stops the pool, if it was started.
+// It returns the first error from a write that has not been reported by Flush.
+func (c *Cache) stopWriter() error {
+	c.shared.wmu.Lock()
+	w := c.shared.writer
+	c.shared.wmu.Unlock()
+	if w == nil {
+		return nil
+	}
+
+	w.mu.Lock()
+	if w.stopped {
+		w.mu.Unlock()
+		return nil
+	}
+	w.stopped = true
+	w.mu.Unlock()
+
+	// no more writes can be queued, so once the pending ones are done the queues
+	// can be closed
+	err := c.Flush()
+	for _, q := range w.queues {
+		close(q)
+	}
+	w.workers.Wait()
+	return err
+}
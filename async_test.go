@@ -0,0 +1,81 @@
+package lrudir
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutAsync(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithAsyncWriters(3, 2), WithMaxValueBytes(8))
+	require.NoError(t, err)
+
+	ns := c.Namespace("ns")
+	value := []byte("value")
+	for i := 0; i < 20; i++ {
+		err = c.PutAsync([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprint(i)))
+		require.NoError(t, err)
+
+		// later writes to the same key win
+		value[0] = byte('a' + i)
+		err = ns.PutAsync([]byte("same"), value)
+		require.NoError(t, err)
+	}
+
+	err = c.Flush()
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 20)
+
+	val, err := c.Get([]byte("key7"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("7"), val)
+
+	val, err = ns.Get([]byte("same"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("talue"), val)
+
+	// errors are reported by Flush, once
+	err = c.PutAsync([]byte("key"), []byte("too large to store"))
+	require.NoError(t, err)
+	assert.True(t, errors.Is(c.Flush(), ErrValueTooLarge))
+	assert.NoError(t, c.Flush())
+}
+
+func TestCloseFlushes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		err = c.PutAsync([]byte(fmt.Sprintf("key%d", i)), []byte("x"))
+		require.NoError(t, err)
+	}
+
+	err = c.Close()
+	require.NoError(t, err)
+
+	err = c.PutAsync([]byte("late"), []byte("x"))
+	assert.Equal(t, ErrClosed, err)
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 10)
+}
@@ -0,0 +1,130 @@
+package lrudir
+
+import (
+	"os"
+	"time"
+)
+
+// WithBatchedPromotion makes reads record that an entry was accessed rather than moving
+// it to the head of the list straight away, which takes several file writes per read.
+// Recorded accesses are applied together once n have accumulated, every interval,
+// before any write, and on Close. Until then the order of the list, as seen
+// by Keys and by other processes, lags behind reads. Writes and Touch still move
+// entries immediately. A non-positive n or interval disables that trigger.
+func WithBatchedPromotion(n int, interval time.Duration) Option {
+	return func(c *Cache) {
+		c.batchSize = n
+		c.batchInterval = interval
+		c.batched = true
+	}
+}
+
+// access moves the entry for the given identifier to the head of the list after a read,
+// or records the access to be applied later if promotion is batched. Entries promoted
+// recently are left alone, as configured by WithPromoteAfter. It must be called with
+// the lock held.
+func (c *Cache) access(id []byte) error {
+	if c.promotedRecently(id) {
+		return nil
+	}
+	if !c.batched {
+		return c.promoteReads(id, 1)
+	}
+	if c.recordAccess(id) {
+		return c.applyAccesses()
+	}
+	return nil
+}
+
+// recordAccess records an access to be applied later, returning true if enough have
+// accumulated that they should be applied now
+func (c *Cache) recordAccess(id []byte) bool {
+	c.shared.amu.Lock()
+	defer c.shared.amu.Unlock()
+	c.shared.accesses = append(c.shared.accesses, string(id))
+	return c.batchSize > 0 && len(c.shared.accesses) >= c.batchSize
+}
+
+// applyAccesses moves every entry with a recorded access to the head of the list, in
+// the order they were accessed. Entries removed since they were accessed are skipped.
+// It must be called with the lock held.
+func (c *Cache) applyAccesses() error {
+	c.shared.amu.Lock()
+	accesses := c.shared.accesses
+	c.shared.accesses = nil
+	c.shared.amu.Unlock()
+
+	// only the most recent access to each entry matters for its position, but every
+	// access is counted
+	counts := make(map[string]int64)
+	var order []string
+	for i := len(accesses) - 1; i >= 0; i-- {
+		if counts[accesses[i]] == 0 {
+			order = append(order, accesses[i])
+		}
+		counts[accesses[i]]++
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		id := []byte(order[i])
+		_, err := os.Stat(c.path(id))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = c.promoteReads(id, counts[order[i]])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushAccesses applies recorded accesses under the lock
+func (c *Cache) flushAccesses() error {
+	c.shared.amu.Lock()
+	n := len(c.shared.accesses)
+	c.shared.amu.Unlock()
+	if n == 0 {
+		return nil
+	}
+
+	err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer c.unlock()
+	return c.applyAccesses()
+}
+
+// startBatching starts the goroutine that applies recorded accesses every interval, if
+// requested by WithBatchedPromotion
+func (c *Cache) startBatching() {
+	if !c.batched || c.batchInterval <= 0 {
+		return
+	}
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	if !c.onClose(func() { close(done); <-stopped }) {
+		return
+	}
+
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(c.batchInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				err := c.flushAccesses()
+				if err != nil && err != ErrClosed {
+					c.debug("failed to apply batched accesses", "err", err)
+				}
+			}
+		}
+	}()
+}
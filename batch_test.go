@@ -0,0 +1,102 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchedPromotion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithBatchedPromotion(3, 0))
+	require.NoError(t, err)
+
+	k1, k2, k3, k4 := []byte("key1"), []byte("key2"), []byte("key3"), []byte("key4")
+	for _, k := range [][]byte{k1, k2, k3} {
+		err = c.Put(k, []byte("x"))
+		require.NoError(t, err)
+	}
+
+	_, err = c.Get(k1)
+	require.NoError(t, err)
+	_, err = c.Get(k2)
+	require.NoError(t, err)
+
+	// the reads have not been applied yet
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k3, k2, k1}, keys)
+
+	// the third read applies all of them, in order
+	_, err = c.Get(k1)
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1, k2, k3}, keys)
+
+	// pending reads are applied before the next write, which comes after them
+	c, err = Open(dir, WithBatchedPromotion(0, 0), WithMaxEntries(3))
+	require.NoError(t, err)
+
+	_, err = c.Get(k3)
+	require.NoError(t, err)
+
+	err = c.Put(k4, []byte("x"))
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k4, k3, k1}, keys)
+
+	// and on Close
+	_, err = c.Get(k1)
+	require.NoError(t, err)
+
+	err = c.Close()
+	require.NoError(t, err)
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1, k4, k3}, keys)
+}
+
+func TestBatchedPromotionInterval(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithBatchedPromotion(0, time.Millisecond))
+	require.NoError(t, err)
+	defer c.Close()
+
+	k1, k2 := []byte("key1"), []byte("key2")
+	for _, k := range [][]byte{k1, k2} {
+		err = c.Put(k, []byte("x"))
+		require.NoError(t, err)
+	}
+
+	_, err = c.Get(k1)
+	require.NoError(t, err)
+
+	var keys [][]byte
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		keys, err = c.Keys()
+		require.NoError(t, err)
+		if string(keys[0]) == string(k1) {
+			break
+		}
+	}
+	assert.EqualValues(t, [][]byte{k1, k2}, keys)
+}
@@ -0,0 +1,63 @@
+package lrudir
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// defaultBlockSize is the block size assumed where the filesystem does not report one
+const defaultBlockSize = 4096
+
+// WithBlockAccounting makes the usage counted against WithMaxBytes and namespace quotas
+// round the size of every value file up to a whole number of blocks of the given size,
+// which is how much space the filesystem actually allocates for it, so that many small
+// values cannot use far more of the volume than the limit allows. A size of zero uses
+// the block size the filesystem reports for the cache directory. Stats then reports
+// the rounded total, while Stat and other descriptions of single entries keep giving
+// their exact sizes. Like the escaping scheme, this is chosen when a cache is created
+// and persisted in the state file, since processes that counted differently would
+// disagree about the usage.
+func WithBlockAccounting(blockSize int64) Option {
+	return func(c *Cache) {
+		c.blockAccounting = true
+		c.blockSize = blockSize
+	}
+}
+
+// chargedSize gets the size that the value at the given path counts for in the usage,
+// which is its size as given by valueSize with every file rounded up to a whole number
+// of blocks for caches created with WithBlockAccounting
+func (c *Cache) chargedSize(path string) (int64, error) {
+	if c.blockSize <= 0 {
+		return valueSize(path)
+	}
+
+	st, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !st.IsDir() {
+		return c.roundToBlock(st.Size()), nil
+	}
+
+	var size int64
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += c.roundToBlock(info.Size())
+		}
+		return nil
+	})
+	return size, err
+}
+
+// roundToBlock rounds a file size up to a whole number of blocks, if the cache was
+// created with WithBlockAccounting
+func (c *Cache) roundToBlock(size int64) int64 {
+	if c.blockSize <= 0 {
+		return size
+	}
+	return (size + c.blockSize - 1) / c.blockSize * c.blockSize
+}
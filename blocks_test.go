@@ -0,0 +1,63 @@
+package lrudir
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlockAccounting(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithBlockAccounting(1024), WithMaxBytes(4096))
+	require.NoError(t, err)
+
+	// each tiny value takes a whole block, so only four fit
+	for i := 0; i < 5; i++ {
+		require.NoError(t, c.Put([]byte(fmt.Sprint(i)), []byte("tiny")))
+	}
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, s.Entries)
+	assert.EqualValues(t, 4096, s.Bytes)
+
+	// appending counts only the blocks added
+	require.NoError(t, c.Append([]byte("4"), bytes.Repeat([]byte("x"), 1020)))
+	s, err = c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 4096, s.Bytes)
+	require.NoError(t, c.Verify())
+
+	// the block size is persisted, unlike the limit
+	c, err = Open(dir, WithMaxBytes(4096))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1024, c.blockSize)
+	require.NoError(t, c.Append([]byte("4"), []byte("x")))
+	s, err = c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, s.Entries)
+	assert.EqualValues(t, 2*1024+2048, s.Bytes)
+	require.NoError(t, c.Verify())
+}
+
+func TestBlockAccountingDetected(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithBlockAccounting(0))
+	require.NoError(t, err)
+	assert.True(t, c.blockSize > 0)
+
+	require.NoError(t, c.Put([]byte("a"), []byte("x")))
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, c.blockSize, s.Bytes)
+}
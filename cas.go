@@ -0,0 +1,94 @@
+package lrudir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// blobDir is the subdirectory of the metadata directory in which content-addressed
+// values are stored
+const blobDir = "blobs"
+
+// WithContentAddressing stores each distinct value once, in a blob named by the SHA-256
+// hash of its contents, and hardlinks the value file for every key with that value to
+// the blob. A blob is removed when the last entry linking to it is removed. Sizes and
+// limits still count each entry's value in full. On filesystems without hardlinks,
+// values are stored separately as usual.
+func WithContentAddressing() Option {
+	return func(c *Cache) {
+		c.contentAddressed = true
+	}
+}
+
+// blobPath gets the path to the blob with the given hash
+func (c *Cache) blobPath(hash string) string {
+	return c.internalPath(filepath.Join(blobDir, hash))
+}
+
+// putShared writes the value for the given identifier as a link to a shared blob,
+// creating the blob if no other entry has the same value
+func (c *Cache) putShared(x *state, id, value []byte, m *meta) error {
+	sum := sha256.Sum256(value)
+	hash := hex.EncodeToString(sum[:])
+	blob := c.blobPath(hash)
+
+	_, err := os.Stat(blob)
+	if os.IsNotExist(err) {
+		err = os.MkdirAll(filepath.Dir(blob), 0777)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(blob+"~tmp", value, 0777)
+		if err != nil {
+			return err
+		}
+		err = os.Rename(blob+"~tmp", blob)
+	}
+	if err != nil {
+		return err
+	}
+
+	tmp := c.tempPath(id)
+	os.Remove(tmp)
+	err = os.Link(blob, tmp)
+	if err != nil {
+		// no hardlinks on this filesystem, so fall back to a private copy
+		err = c.releaseBlob(hash)
+		if err != nil {
+			return err
+		}
+		err = ioutil.WriteFile(tmp, value, 0777)
+		if err != nil {
+			return err
+		}
+		return c.commit(x, id, tmp, m)
+	}
+
+	m.Blob = hash
+	return c.commit(x, id, tmp, m)
+}
+
+// releaseBlob removes the blob with the given hash if no value files link to it any
+// more. It does nothing for the empty hash, or on platforms where link counts are not
+// available.
+func (c *Cache) releaseBlob(hash string) error {
+	if hash == "" {
+		return nil
+	}
+
+	st, err := os.Stat(c.blobPath(hash))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if linkCount(st) == 1 {
+		return os.Remove(c.blobPath(hash))
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentAddressing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithContentAddressing())
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.Put(k1, []byte("same"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("same"))
+	require.NoError(t, err)
+
+	blobs, err := ioutil.ReadDir(filepath.Join(dir, metaDir, blobDir))
+	require.NoError(t, err)
+	assert.Len(t, blobs, 1)
+
+	st1, err := os.Stat(c.Path(k1))
+	require.NoError(t, err)
+	st2, err := os.Stat(c.Path(k2))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(st1, st2))
+
+	// the blob stays while any entry still refers to it
+	err = c.Delete(k1)
+	require.NoError(t, err)
+
+	val, err := c.Get(k2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("same"), val)
+
+	err = c.Put(k2, []byte("different"))
+	require.NoError(t, err)
+
+	err = c.Delete(k2)
+	require.NoError(t, err)
+
+	blobs, err = ioutil.ReadDir(filepath.Join(dir, metaDir, blobDir))
+	require.NoError(t, err)
+	assert.Len(t, blobs, 0)
+}
@@ -0,0 +1,27 @@
+package lrudir
+
+import "time"
+
+// Clock tells the cache the current time. It is consulted for expiry, by the janitor,
+// and for the times recorded in the eviction log, so that tests can control time
+// rather than sleeping.
+type Clock interface {
+	Now() time.Time
+}
+
+// WithClock makes the cache get the current time from clk rather than the system
+// clock. The janitor still sweeps at intervals of real time, but what it considers
+// expired is decided by clk.
+func WithClock(clk Clock) Option {
+	return func(c *Cache) {
+		c.clock = clk
+	}
+}
+
+// now gets the current time
+func (c *Cache) now() time.Time {
+	if c.clock != nil {
+		return c.clock.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,59 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeClock is a clock that only moves when told to
+type fakeClock struct {
+	t time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.t
+}
+
+func TestClock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := Create(dir, WithClock(clk))
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.PutWithTTL(k1, []byte("one"), time.Hour)
+	require.NoError(t, err)
+
+	err = c.PutWithExpiry(k2, []byte("two"), clk.t.Add(2*time.Hour))
+	require.NoError(t, err)
+
+	m, err := c.readMeta(c.id(k1))
+	require.NoError(t, err)
+	assert.Equal(t, clk.t.Add(time.Hour).UnixNano(), m.Expires)
+
+	clk.t = clk.t.Add(90 * time.Minute)
+
+	_, err = c.Get(k1)
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = c.Get(k2)
+	require.NoError(t, err)
+
+	clk.t = clk.t.Add(time.Hour)
+
+	err = c.ExpireNow()
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
@@ -0,0 +1,9 @@
+package lrudir
+
+import "golang.org/x/sys/unix"
+
+// cloneFile creates dst as a copy-on-write clone of src, failing if the filesystem
+// does not support cloning, as on APFS. The destination must not already exist.
+func cloneFile(src, dst string) error {
+	return unix.Clonefile(src, dst, 0)
+}
@@ -0,0 +1,33 @@
+package lrudir
+
+import (
+	"os"
+	"syscall"
+)
+
+// ficlone is the FICLONE ioctl, which makes one file share the extents of another on
+// filesystems that support it, such as btrfs and XFS
+const ficlone = 0x40049409
+
+// cloneFile creates dst as a copy-on-write clone of src, failing if the filesystem
+// does not support cloning. The destination must not already exist.
+func cloneFile(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0777)
+	if err != nil {
+		return err
+	}
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, w.Fd(), ficlone, r.Fd())
+	if errno != 0 {
+		w.Close()
+		os.Remove(dst)
+		return errno
+	}
+	return w.Close()
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package lrudir
+
+import "errors"
+
+// errNoClone is returned by cloneFile on platforms that do not support it
+var errNoClone = errors.New("cloning files is not supported on this platform")
+
+// cloneFile creates dst as a copy-on-write clone of src, failing if the filesystem
+// does not support cloning. The destination must not already exist.
+func cloneFile(src, dst string) error {
+	return errNoClone
+}
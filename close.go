@@ -0,0 +1,79 @@
+package lrudir
+
+import "errors"
+
+// ErrClosed is returned by operations on a cache that has been closed
+var ErrClosed = errors.New("cache is closed")
+
+// Close finishes any writes queued by PutAsync, stops any watchers and background work
+// started for the cache, marks it as cleanly closed in its state, and releases the
+// file lock. It waits for operations in progress to finish. Closing a cache closes all
+// of its namespaces, and operations on any of them afterwards return ErrClosed, as
+// does a second Close.
+func (c *Cache) Close() error {
+	// writes queued by PutAsync and batched accesses need the cache to still be open
+	werr := c.stopWriter()
+	if err := c.flushAccesses(); werr == nil {
+		werr = err
+	}
+
+	c.shared.mu.Lock()
+	if c.shared.closed {
+		c.shared.mu.Unlock()
+		return ErrClosed
+	}
+	c.shared.closed = true
+	closers := c.shared.closers
+	c.shared.closers = nil
+	c.shared.mu.Unlock()
+
+	// background work may be waiting for the lock, which it can no longer get, so the
+	// closers run without holding it
+	for i := len(closers) - 1; i >= 0; i-- {
+		closers[i]()
+	}
+
+	// operations holding a shard may be waiting for the lock, so the shards are closed
+	// before taking it
+	c.closeShards()
+
+	c.shared.mu.Lock()
+	defer c.shared.mu.Unlock()
+
+	err := c.locker.Lock()
+	if err != nil {
+		c.locker.Close()
+		return err
+	}
+
+	x, err := c.state()
+	if err == nil {
+		x.Closed = true
+		err = c.setState(x)
+	}
+
+	uerr := c.locker.Unlock()
+	cerr := c.locker.Close()
+	if err != nil {
+		return err
+	}
+	if uerr != nil {
+		return uerr
+	}
+	if cerr != nil {
+		return cerr
+	}
+	return werr
+}
+
+// onClose arranges for f to be called when the cache is closed. It returns false,
+// without calling f, if the cache is already closed.
+func (c *Cache) onClose(f func()) bool {
+	c.shared.mu.Lock()
+	defer c.shared.mu.Unlock()
+	if c.shared.closed {
+		return false
+	}
+	c.shared.closers = append(c.shared.closers, f)
+	return true
+}
@@ -0,0 +1,56 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClose(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("foo"), []byte("bar"))
+	require.NoError(t, err)
+
+	events, _ := c.Watch()
+	ns := c.Namespace("a")
+
+	err = c.Close()
+	require.NoError(t, err)
+
+	// watchers are stopped
+	for range events {
+	}
+
+	_, err = c.Get([]byte("foo"))
+	assert.Equal(t, ErrClosed, err)
+
+	_, err = ns.Keys()
+	assert.Equal(t, ErrClosed, err)
+
+	assert.Equal(t, ErrClosed, c.Close())
+
+	x, err := c.state()
+	require.NoError(t, err)
+	assert.True(t, x.Closed)
+
+	// opening again clears the mark
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	x, err = c.state()
+	require.NoError(t, err)
+	assert.False(t, x.Closed)
+
+	val, err := c.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bar"), val)
+}
@@ -0,0 +1,72 @@
+// Command lrudird serves an LRU directory over HTTP so that several machines can
+// share one cache. Use lrudir.RemoteCache to talk to it. The protocol is plain HTTP
+// rather than gRPC so that any HTTP client, proxy, or CDN can read from the cache, with
+// range and conditional requests, and values of any size are streamed in both
+// directions.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"github.com/alexflint/go-lrudir"
+)
+
+func main() {
+	dir := flag.String("dir", "", "cache directory, created if it does not exist")
+	addr := flag.String("addr", ":7070", "address to listen on")
+	memcached := flag.String("memcached", "", "address to serve the memcached text protocol on, if any")
+	maxEntries := flag.Int("max-entries", 0, "maximum number of entries, or zero for no limit")
+	maxBytes := flag.Int64("max-bytes", 0, "maximum total size of values, or zero for no limit")
+	janitor := flag.Duration("janitor", 0, "interval at which to remove expired entries and compact metadata, or zero for never")
+	compact := flag.Bool("compact", false, "compact the cache's metadata, report the space reclaimed, and exit")
+	flag.Parse()
+
+	if *dir == "" {
+		log.Fatal("-dir is required")
+	}
+
+	opts := []lrudir.Option{
+		lrudir.WithMkdirAll(),
+		lrudir.WithMaxEntries(*maxEntries),
+		lrudir.WithMaxBytes(*maxBytes),
+	}
+	if *janitor > 0 {
+		opts = append(opts, lrudir.WithJanitor(*janitor), lrudir.WithJanitorCompaction())
+	}
+
+	c, err := lrudir.New(*dir, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if *compact {
+		files, reclaimed, err := c.Compact()
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("removed %d files, reclaiming %d bytes\n", files, reclaimed)
+		err = c.Close()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	if *memcached != "" {
+		l, err := net.Listen("tcp", *memcached)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("serving memcached protocol on %s", *memcached)
+		go func() {
+			log.Fatal(c.ServeMemcached(l))
+		}()
+	}
+
+	log.Printf("serving %s on %s", *dir, *addr)
+	log.Fatal(http.ListenAndServe(*addr, lrudir.Server(c)))
+}
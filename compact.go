@@ -0,0 +1,190 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// entrySuffixes are the suffixes of the files in the metadata directory that belong to
+// an entry, named after its value file
+var entrySuffixes = []string{"~next", "~prev", "~meta", "~lease", keySidecar}
+
+// WithJanitorCompaction makes the janitor started by WithJanitor also run Compact after
+// each sweep for expired entries.
+func WithJanitorCompaction() Option {
+	return func(c *Cache) {
+		c.janitorCompacts = true
+	}
+}
+
+// Compact removes dead records from the metadata directory: pointers and metadata for
+// entries whose value files no longer exist, files staged by writes that never
+// finished, here or in the directory given to WithStagingDir, and blobs that no entry
+// links to any more. Values staged by Import and Merge are left alone, since they do
+// not hold the lock while staging them, and so are values staged by Put until they are
+// an hour old, for the same reason. Such records are left behind when a process crashes
+// part way through an operation, and otherwise accumulate for as long as the cache is
+// used. It returns the number of files removed and their total size. Vacuum also
+// removes value files that are not in the list. This is an O(N) operation that holds
+// the lock, and the locks for every shard, throughout.
+func (c *Cache) Compact() (files int, reclaimed int64, err error) {
+	err = c.updateAllShards(func(x *state) error {
+		files, reclaimed, err = c.compact()
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	c.debug("compacted metadata", "dir", c.Dir, "files", files, "bytes", reclaimed)
+	return files, reclaimed, nil
+}
+
+// compact implements Compact, returning the number of files removed and their total
+// size. It must be called with the lock held.
+func (c *Cache) compact() (int, int64, error) {
+	var files int
+	var reclaimed int64
+	drop := func(path string) error {
+		size, err := valueSize(path)
+		if err != nil {
+			return err
+		}
+		err = os.RemoveAll(path)
+		if err != nil {
+			return err
+		}
+		files++
+		reclaimed += size
+		return nil
+	}
+
+	names, err := readDirNames(c.internalPath(""))
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, name := range names {
+		dead, err := c.isDeadRecord(name)
+		if err != nil {
+			return 0, 0, err
+		}
+		if dead {
+			err = drop(c.internalPath(name))
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	blobs, err := readDirNames(c.internalPath(blobDir))
+	if err != nil && !os.IsNotExist(err) {
+		return 0, 0, err
+	}
+	for _, name := range blobs {
+		path := c.internalPath(filepath.Join(blobDir, name))
+		dead := strings.HasSuffix(name, "~tmp")
+		if !dead {
+			st, err := os.Stat(path)
+			if err != nil {
+				return 0, 0, err
+			}
+			// link counts are unknown on some platforms, which report zero
+			dead = linkCount(st) == 1
+		}
+		if dead {
+			err = drop(path)
+			if err != nil {
+				return 0, 0, err
+			}
+		}
+	}
+
+	n, size, err := c.sweepStaging()
+	if err != nil {
+		return 0, 0, err
+	}
+	files += n
+
+	n, err = c.compactOrder()
+	if err != nil {
+		return 0, 0, err
+	}
+	return files + n, reclaimed + size, nil
+}
+
+// stagedGrace is how long a value staged by Put before taking the lock is left alone
+// by Compact, since the write may still be in progress
+const stagedGrace = time.Hour
+
+// abandoned returns true if the value staged by Put at the given path was staged long
+// enough ago that its write must have failed
+func abandoned(path string) (bool, error) {
+	st, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return time.Since(st.ModTime()) > stagedGrace, nil
+}
+
+// isDeadRecord returns true if the given file in the metadata directory is a record that
+// no live entry or operation in progress needs. It must be called with the lock held.
+func (c *Cache) isDeadRecord(name string) (bool, error) {
+	if strings.HasPrefix(name, "import~") || strings.HasPrefix(name, "merge~") {
+		// Import and Merge stage values without holding the lock
+		return false, nil
+	}
+	if strings.HasPrefix(name, "put~") {
+		return abandoned(c.internalPath(name))
+	}
+	if strings.HasSuffix(name, "~tmp") {
+		// nothing else is staged while the lock is held
+		return true, nil
+	}
+	for _, suffix := range entrySuffixes {
+		if !strings.HasSuffix(name, suffix) {
+			continue
+		}
+		value := strings.TrimSuffix(name, suffix)
+		if value == "" {
+			// the sentinels at the ends of the list
+			return false, nil
+		}
+		_, err := os.Lstat(filepath.Join(c.Dir, value))
+		if os.IsNotExist(err) {
+			return true, nil
+		}
+		return false, err
+	}
+	return false, nil
+}
+
+// updateAllShards is like update but also holds the locks for every shard, which
+// excludes writes in a sharded cache that stage their values before taking the lock
+func (c *Cache) updateAllShards(f func(x *state) error) error {
+	for _, s := range c.shared.shards {
+		err := s.lock()
+		if err != nil {
+			return err
+		}
+		defer s.unlock()
+	}
+	return c.update(f)
+}
+
+// readDirNames lists the names of the files in a directory
+func readDirNames(path string) ([]string, error) {
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.Name()
+	}
+	return names, nil
+}
@@ -0,0 +1,47 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompact(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithContentAddressing())
+	require.NoError(t, err)
+
+	err = c.Put([]byte("key1"), []byte("value"))
+	require.NoError(t, err)
+	err = c.PutWithTTL([]byte("key2"), []byte("other"), 0)
+	require.NoError(t, err)
+
+	// records left behind by a crashed process
+	dead := []string{"gone~next", "gone~prev", "gone~meta", "gone~tmp", "meta~tmp", "blobs/orphan"}
+	for _, name := range dead {
+		err = ioutil.WriteFile(c.internalPath(name), []byte("x"), 0777)
+		require.NoError(t, err)
+	}
+
+	files, _, err := c.Compact()
+	require.NoError(t, err)
+	assert.Equal(t, len(dead), files)
+	for _, name := range dead {
+		_, err = os.Stat(c.internalPath(name))
+		assert.True(t, os.IsNotExist(err), name)
+	}
+
+	require.NoError(t, c.Verify())
+	val, err := c.Get([]byte("key1"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(val))
+	val, err = c.Get([]byte("key2"))
+	require.NoError(t, err)
+	assert.Equal(t, "other", string(val))
+}
@@ -0,0 +1,111 @@
+package lrudir
+
+import (
+	"bytes"
+	"io/ioutil"
+)
+
+// PutIfAbsent sets the value for the given key only if the key is not already in the
+// cache, and reports whether it wrote the value. The check and the write happen under
+// one acquisition of the lock, so when several processes race to populate the same key
+// exactly one of them wins.
+func (c *Cache) PutIfAbsent(key, value []byte) (bool, error) {
+	err := c.ValidateKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	var written bool
+	err = c.update(func(x *state) error {
+		id := c.id(key)
+		found, err := c.exists(id)
+		if err != nil || found {
+			return err
+		}
+
+		err = c.put(x, id, value, nil)
+		if err != nil {
+			return err
+		}
+		written = true
+		return c.evict(x, c.ns)
+	})
+	return written, err
+}
+
+// CompareAndSwap sets the value for the given key to new only if the key is in the
+// cache and its current value equals old, and reports whether it wrote the value.
+func (c *Cache) CompareAndSwap(key, old, new []byte) (bool, error) {
+	err := c.ValidateKey(key)
+	if err != nil {
+		return false, err
+	}
+
+	var swapped bool
+	err = c.update(func(x *state) error {
+		id := c.id(key)
+		found, err := c.exists(id)
+		if err != nil || !found {
+			return err
+		}
+
+		cur, err := ioutil.ReadFile(c.path(id))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(cur, old) {
+			return nil
+		}
+
+		m, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+
+		err = c.put(x, id, new, m)
+		if err != nil {
+			return err
+		}
+		swapped = true
+		return c.evict(x, c.ns)
+	})
+	return swapped, err
+}
+
+// GetOrPut gets the value for the given key if it is in the cache, and otherwise sets
+// it to value. Either way the entry moves to the head of the list. The result is the
+// value now in the cache, and loaded reports whether it was already there. The check
+// and the write happen under one acquisition of the lock, so when several processes
+// race to initialize the same key they all end up with the same value. A negative entry
+// is left in place and reported as ErrNegativeEntry.
+func (c *Cache) GetOrPut(key, value []byte) (existing []byte, loaded bool, err error) {
+	err = c.ValidateKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	err = c.update(func(x *state) error {
+		id := c.id(key)
+		found, err := c.exists(id)
+		if err != nil {
+			return err
+		}
+
+		if found {
+			existing, err = c.get(id)
+			loaded = err == nil
+			return err
+		}
+
+		err = c.put(x, id, value, nil)
+		if err != nil {
+			return err
+		}
+		existing = value
+		return c.evict(x, c.ns)
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return existing, loaded, nil
+}
@@ -0,0 +1,87 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutIfAbsent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	written, err := c.PutIfAbsent([]byte("foo"), []byte("first"))
+	require.NoError(t, err)
+	assert.True(t, written)
+
+	written, err = c.PutIfAbsent([]byte("foo"), []byte("second"))
+	require.NoError(t, err)
+	assert.False(t, written)
+
+	val, err := c.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first"), val)
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	swapped, err := c.CompareAndSwap([]byte("foo"), nil, []byte("x"))
+	require.NoError(t, err)
+	assert.False(t, swapped)
+
+	err = c.Put([]byte("foo"), []byte("v1"))
+	require.NoError(t, err)
+
+	swapped, err = c.CompareAndSwap([]byte("foo"), []byte("v0"), []byte("v2"))
+	require.NoError(t, err)
+	assert.False(t, swapped)
+
+	swapped, err = c.CompareAndSwap([]byte("foo"), []byte("v1"), []byte("v2"))
+	require.NoError(t, err)
+	assert.True(t, swapped)
+
+	val, err := c.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("v2"), val)
+}
+
+func TestGetOrPut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	val, loaded, err := c.GetOrPut(k1, []byte("one"))
+	require.NoError(t, err)
+	assert.False(t, loaded)
+	assert.Equal(t, []byte("one"), val)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	val, loaded, err = c.GetOrPut(k1, []byte("uno"))
+	require.NoError(t, err)
+	assert.True(t, loaded)
+	assert.Equal(t, []byte("one"), val)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1, k2}, keys)
+}
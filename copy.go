@@ -0,0 +1,128 @@
+package lrudir
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+)
+
+// Copy duplicates the entry for srcKey under dstKey, including its metadata, and moves
+// the copy to the head of the list. The value is hardlinked when the filesystem allows
+// it and streamed otherwise, so it is never read into memory. If dstKey already exists,
+// it is replaced.
+func (c *Cache) Copy(srcKey, dstKey []byte) error {
+	if len(srcKey) == 0 {
+		return errors.New("cannot copy the empty key")
+	}
+	err := c.ValidateKey(dstKey)
+	if err != nil {
+		return err
+	}
+	return c.update(func(x *state) error {
+		err := c.duplicate(x, c.id(srcKey), c.id(dstKey))
+		if err != nil {
+			return err
+		}
+		return c.evict(x, c.ns)
+	})
+}
+
+// duplicate copies the entry for one identifier to another
+func (c *Cache) duplicate(x *state, from, to []byte) error {
+	m, err := c.readMeta(from)
+	if err != nil {
+		return err
+	}
+
+	tmp := c.tempPath(to)
+	err = copyFile(c.path(from), tmp)
+	if err != nil {
+		return err
+	}
+
+	if bytes.Equal(from, to) {
+		err = os.Remove(tmp)
+		if err != nil {
+			return err
+		}
+		return c.promote(from)
+	}
+
+	return c.commit(x, to, tmp, m)
+}
+
+// promote moves the given identifier to the head of the list and records when it was
+// used
+func (c *Cache) promote(id []byte) error {
+	return c.promoteReads(id, 0)
+}
+
+// promoteReads is like promote but also adds n to the number of reads recorded for the
+// entry
+func (c *Cache) promoteReads(id []byte, n int64) error {
+	err := c.detach(id)
+	if err != nil {
+		return err
+	}
+
+	err = c.attachHead(id)
+	if err != nil {
+		return err
+	}
+
+	m, err := c.readMeta(id)
+	if err != nil {
+		return err
+	}
+	m.Used = c.now().UnixNano()
+	m.Accesses += n
+	return c.writeMeta(id, m)
+}
+
+// copyFile creates dst with the same contents as src, as a hardlink if possible and
+// otherwise as a copy made by copyContents
+func copyFile(src, dst string) error {
+	os.Remove(dst)
+	err := os.Link(src, dst)
+	if err == nil {
+		return nil
+	}
+	return copyContents(src, dst)
+}
+
+// copyContents creates dst as an independent copy of src. The copy is a clone sharing
+// storage with src until either is modified if the filesystem supports it, as btrfs,
+// XFS, and APFS do, and an ordinary copy otherwise. The destination must not already
+// exist.
+func copyContents(src, dst string) error {
+	err := cloneFile(src, dst)
+	if err == nil {
+		copyXattr(src, dst)
+		return nil
+	}
+
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0777)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		w.Close()
+		os.Remove(dst)
+		return err
+	}
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+	copyXattr(src, dst)
+	return nil
+}
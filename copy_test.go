@@ -0,0 +1,81 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	err = c.PutWithTags(k1, []byte("one"), "t")
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	err = c.Copy(k1, k3)
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k3, k2, k1}, keys)
+
+	val, err := c.Get(k3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	// overwriting the source must not affect the copy even when hardlinked
+	err = c.Put(k1, []byte("changed"))
+	require.NoError(t, err)
+
+	val, err = c.Get(k3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	// metadata is copied along with the value
+	err = c.InvalidateTag("t")
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1, k2}, keys)
+}
+
+func TestCopyContents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+	require.NoError(t, ioutil.WriteFile(src, []byte("abc"), 0777))
+
+	// cloning either succeeds or leaves nothing behind, depending on the filesystem
+	err = cloneFile(src, dst)
+	if err != nil {
+		_, err = os.Stat(dst)
+		assert.True(t, os.IsNotExist(err))
+	}
+	os.Remove(dst)
+
+	require.NoError(t, copyContents(src, dst))
+	assert.Error(t, copyContents(src, dst))
+
+	// the copy is independent of the original
+	require.NoError(t, ioutil.WriteFile(src, []byte("changed"), 0777))
+	buf, err := ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(buf))
+}
@@ -0,0 +1,44 @@
+package lrudir
+
+import (
+	"context"
+	"errors"
+)
+
+// PutWithCost sets the value for the given key and records how costly the entry is to
+// keep, for example because it is expensive to regenerate. Costs are only used for the
+// limit set by WithMaxCost. Entries written any other way cost one, and the cost must
+// be positive.
+func (c *Cache) PutWithCost(key, value []byte, cost int64) error {
+	err := c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+	if cost <= 0 {
+		return errors.New("cost must be positive")
+	}
+
+	var m meta
+	if cost != 1 {
+		m.Cost = cost
+	}
+
+	return c.write(context.Background(), c.id(key), value, &m)
+}
+
+// WithMaxCost limits the total cost of the entries in the cache, across all namespaces,
+// where the cost of each entry is as given to PutWithCost or one otherwise. The least
+// recently used entries are evicted when a Put exceeds the limit.
+func WithMaxCost(n int64) Option {
+	return func(c *Cache) {
+		c.limits.cost = n
+	}
+}
+
+// extraCost gets the cost of an entry with this metadata beyond the default of one
+func (m *meta) extraCost() int64 {
+	if m == nil || m.Cost == 0 {
+		return 0
+	}
+	return m.Cost - 1
+}
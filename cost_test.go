@@ -0,0 +1,59 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutWithCost(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxCost(10))
+	require.NoError(t, err)
+
+	k1, k2, k3, k4 := []byte("key1"), []byte("key2"), []byte("key3"), []byte("key4")
+
+	err = c.PutWithCost(k1, []byte("one"), 6)
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	err = c.PutWithCost(k3, []byte("three"), 3)
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k3, k2, k1}, keys)
+
+	// the total cost is now 11, so the oldest entry goes
+	err = c.Put(k4, []byte("four"))
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k4, k3, k2}, keys)
+
+	// replacing an entry replaces its cost
+	err = c.PutWithCost(k3, []byte("three"), 9)
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k3, k4}, keys)
+
+	err = c.Put(k3, []byte("three"))
+	require.NoError(t, err)
+
+	err = c.Verify()
+	require.NoError(t, err)
+
+	err = c.PutWithCost(k1, []byte("one"), 0)
+	assert.Error(t, err)
+}
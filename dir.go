@@ -0,0 +1,166 @@
+package lrudir
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// PutDir moves the directory tree at srcDir into the cache as the value for the given
+// key. The tree is renamed into place when srcDir is on the same filesystem as the
+// cache, and copied and then removed otherwise. The size of the entry is the total size
+// of the files in the tree.
+func (c *Cache) PutDir(key []byte, srcDir string) error {
+	err := c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+
+	st, err := os.Stat(srcDir)
+	if err != nil {
+		return err
+	}
+	if !st.IsDir() {
+		return errors.New(srcDir + " is not a directory")
+	}
+
+	if c.maxValueBytes > 0 {
+		size, err := valueSize(srcDir)
+		if err != nil {
+			return err
+		}
+		if size > c.maxValueBytes {
+			return c.tooLarge(size)
+		}
+	}
+
+	return c.update(func(x *state) error {
+		id := c.id(key)
+		tmp := c.tempPath(id)
+		err := os.Rename(srcDir, tmp)
+		if isCrossDevice(err) {
+			err = copyTree(srcDir, tmp)
+			if err != nil {
+				os.RemoveAll(tmp)
+				return err
+			}
+			err = os.RemoveAll(srcDir)
+			if err != nil {
+				os.RemoveAll(tmp)
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		err = c.commit(x, id, tmp, nil)
+		if err != nil {
+			return err
+		}
+		return c.evict(x, c.ns)
+	})
+}
+
+// dirLease is the duration of the leases taken by DirPath, which are renewed until they
+// are released, so it only bounds how long an entry stays protected after its process
+// exits
+const dirLease = time.Minute
+
+// DirPath gets the location of the value for the given key, moves the entry to the
+// head of the list, and leases it so that it will not be evicted, by this process or
+// any other, until release is called. This is intended for directory entries created
+// by PutDir, whose contents are read in place rather than through Get. As with Lease,
+// explicit deletion still removes the entry.
+func (c *Cache) DirPath(key []byte) (path string, release func(), err error) {
+	if len(key) == 0 {
+		return "", nil, errors.New("cannot get the empty key")
+	}
+
+	l, err := c.Lease(key, dirLease)
+	if err != nil {
+		return "", nil, err
+	}
+	return l.Path(), func() { l.Release() }, nil
+}
+
+// valueSize gets the size of the value at the given path, which is the total size of
+// all files in the tree for directory entries. Symbolic links are not followed.
+func valueSize(path string) (int64, error) {
+	st, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+	if !st.IsDir() {
+		return st.Size(), nil
+	}
+
+	var size int64
+	err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// replace renames src to dst, first removing dst if it cannot simply be renamed over,
+// which is the case when either one is a directory. A src staged on another filesystem
+// is copied over first.
+func (c *Cache) replace(src, dst string) error {
+	err := c.renameFile(src, dst)
+	if err == nil {
+		return nil
+	}
+	if isCrossDevice(err) {
+		src, err = c.unstage(src)
+		if err != nil {
+			return err
+		}
+		err = c.renameFile(src, dst)
+		if err == nil {
+			return nil
+		}
+	}
+	if rmerr := os.RemoveAll(dst); rmerr != nil {
+		return err
+	}
+	return c.renameFile(src, dst)
+}
+
+// copyTree recursively copies the directory tree at src to dst, which must not exist
+func copyTree(src, dst string) error {
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		switch {
+		case info.IsDir():
+			err = os.Mkdir(target, info.Mode().Perm()|0700)
+			if err != nil {
+				return err
+			}
+			copyXattr(p, target)
+			return nil
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		default:
+			return copyContents(p, target)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(src)
+
+	err = os.MkdirAll(filepath.Join(src, "pkg", "lib"), 0777)
+	require.NoError(t, err)
+
+	err = ioutil.WriteFile(filepath.Join(src, "pkg", "lib", "index.js"), []byte("hello"), 0666)
+	require.NoError(t, err)
+
+	c, err := Create(dir, WithMaxEntries(1))
+	require.NoError(t, err)
+
+	err = c.PutDir([]byte("npm/left-pad"), src)
+	require.NoError(t, err)
+
+	path, release, err := c.DirPath([]byte("npm/left-pad"))
+	require.NoError(t, err)
+
+	buf, err := ioutil.ReadFile(filepath.Join(path, "pkg", "lib", "index.js"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), buf)
+
+	x, err := c.state()
+	require.NoError(t, err)
+	assert.EqualValues(t, usage{Entries: 1, Bytes: 5, Inodes: 7}, *x.Usage[""])
+
+	// the leased directory survives eviction, by other processes too
+	err = c.Put([]byte("other"), nil)
+	require.NoError(t, err)
+
+	other, err := Open(dir, WithMaxEntries(1))
+	require.NoError(t, err)
+	err = other.Put([]byte("more"), nil)
+	require.NoError(t, err)
+
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	// once released it is evicted as usual
+	release()
+	err = c.Put([]byte("another"), nil)
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("another")}, keys)
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestPutDirKeepsSourceOnFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	src, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(src)
+	err = ioutil.WriteFile(filepath.Join(src, "file"), []byte("x"), 0666)
+	require.NoError(t, err)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	// a non-empty directory in the way makes the rename fail for a reason other than
+	// crossing filesystems
+	key := []byte("k")
+	blocker := c.tempPath(c.id(key))
+	require.NoError(t, os.MkdirAll(filepath.Join(blocker, "sub"), 0777))
+
+	err = c.PutDir(key, src)
+	assert.Error(t, err)
+
+	_, err = os.Stat(filepath.Join(src, "file"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,184 @@
+package lrudir
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DumpFormat selects the output format for Dump
+type DumpFormat int
+
+// The formats supported by Dump
+const (
+	DumpText     DumpFormat = iota // one line per entry, for reading directly
+	DumpGraphviz                   // a dot graph of the list, for rendering with Graphviz
+)
+
+// dumpNode describes one entry as found by inspect
+type dumpNode struct {
+	id   []byte
+	size int64 // size of the value, or -1 if it is missing
+}
+
+// Dump writes a description of the linked list to w, from the head to the tail, along
+// with any anomalies found such as broken pointers, cycles, missing values, and value
+// files that are not in the list. Unlike Verify it does not stop at the first problem,
+// so it can be used to understand a cache that is already corrupt. It holds a shared
+// lock while reading.
+func (c *Cache) Dump(w io.Writer, format DumpFormat) error {
+	var nodes []dumpNode
+	var anomalies []string
+	err := c.view(func() error {
+		var err error
+		nodes, anomalies, err = c.inspect()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case DumpText:
+		return dumpText(w, nodes, anomalies)
+	case DumpGraphviz:
+		return dumpGraphviz(w, nodes, anomalies)
+	}
+	return fmt.Errorf("unknown dump format %d", format)
+}
+
+// inspect walks the list as far as it can, collecting the entries and describing
+// everything that is not as it should be. It must be called with the lock held.
+func (c *Cache) inspect() ([]dumpNode, []string, error) {
+	var nodes []dumpNode
+	var anomalies []string
+	problem := func(format string, args ...interface{}) {
+		anomalies = append(anomalies, fmt.Sprintf(format, args...))
+	}
+
+	visited := make(map[string]bool)
+	var prev []byte
+	cur, err := c.readPtr(c.nextPtr(nil))
+	if err != nil {
+		problem("cannot read head pointer: %v", err)
+	}
+	for len(cur) > 0 {
+		if visited[string(cur)] {
+			problem("cycle: %s points back to %s", label(prev), label(cur))
+			break
+		}
+		visited[string(cur)] = true
+
+		back, err := c.readPtr(c.prevPtr(cur))
+		switch {
+		case err != nil:
+			problem("%s has no prev pointer", label(cur))
+		case !bytes.Equal(back, prev):
+			problem("prev pointer of %s is %s but should be %s", label(cur), label(back), label(prev))
+		}
+
+		size, err := valueSize(c.path(cur))
+		if err != nil {
+			problem("%s has no value: %v", label(cur), err)
+			size = -1
+		}
+		nodes = append(nodes, dumpNode{id: cur, size: size})
+
+		next, err := c.readPtr(c.nextPtr(cur))
+		if err != nil {
+			problem("%s has no next pointer, so the rest of the list is unreachable", label(cur))
+			break
+		}
+		prev, cur = cur, next
+	}
+
+	tail, err := c.readPtr(c.prevPtr(nil))
+	if err != nil {
+		problem("cannot read tail pointer: %v", err)
+	} else if len(cur) == 0 && !bytes.Equal(tail, prev) {
+		problem("tail pointer is %s but the last entry is %s", label(tail), label(prev))
+	}
+
+	names, err := c.valueNames()
+	if err != nil {
+		return nil, nil, err
+	}
+	reached := make(map[string]bool)
+	for id := range visited {
+		reached[c.escape([]byte(id))] = true
+	}
+	for _, name := range names {
+		if !reached[name] {
+			problem("value file %q is not in the list", name)
+		}
+	}
+	return nodes, anomalies, nil
+}
+
+// label formats an identifier for display, or "(end)" for the sentinel
+func label(id []byte) string {
+	if len(id) == 0 {
+		return "(end)"
+	}
+	ns, key := splitID(id)
+	if ns == "" {
+		return fmt.Sprintf("%q", key)
+	}
+	return fmt.Sprintf("%s:%q", ns, key)
+}
+
+// dumpText writes the entries and anomalies as plain text
+func dumpText(w io.Writer, nodes []dumpNode, anomalies []string) error {
+	var b strings.Builder
+	for i, n := range nodes {
+		if n.size < 0 {
+			fmt.Fprintf(&b, "%d %s (missing value)\n", i, label(n.id))
+		} else {
+			fmt.Fprintf(&b, "%d %s %d bytes\n", i, label(n.id), n.size)
+		}
+	}
+	if len(anomalies) > 0 {
+		b.WriteString("anomalies:\n")
+		for _, a := range anomalies {
+			fmt.Fprintf(&b, "  %s\n", a)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dumpGraphviz writes the entries and anomalies as a dot graph
+func dumpGraphviz(w io.Writer, nodes []dumpNode, anomalies []string) error {
+	var b strings.Builder
+	b.WriteString("digraph lrudir {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	b.WriteString("  head [shape=plaintext];\n")
+	b.WriteString("  tail [shape=plaintext];\n")
+	prev := "head"
+	for i, n := range nodes {
+		name := fmt.Sprintf("n%d", i)
+		text := fmt.Sprintf("%s\\n%d bytes", dotEscape(label(n.id)), n.size)
+		attrs := ""
+		if n.size < 0 {
+			text = dotEscape(label(n.id)) + "\\nmissing value"
+			attrs = ", color=red"
+		}
+		fmt.Fprintf(&b, "  %s [label=\"%s\"%s];\n", name, text, attrs)
+		fmt.Fprintf(&b, "  %s -> %s;\n", prev, name)
+		prev = name
+	}
+	fmt.Fprintf(&b, "  %s -> tail;\n", prev)
+	for i, a := range anomalies {
+		fmt.Fprintf(&b, "  anomaly%d [shape=note, color=red, label=\"%s\"];\n", i, dotEscape(a))
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// dotEscape quotes a string for use inside a double-quoted dot label
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
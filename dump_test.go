@@ -0,0 +1,52 @@
+package lrudir
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("a"), []byte("123"))
+	require.NoError(t, err)
+
+	err = c.Namespace("ns").Put([]byte("b"), nil)
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = c.Dump(&buf, DumpText)
+	require.NoError(t, err)
+	assert.Equal(t, "0 ns:\"b\" 0 bytes\n1 \"a\" 3 bytes\n", buf.String())
+
+	// break the structure in two different ways
+	err = os.Remove(c.path(c.id([]byte("a"))))
+	require.NoError(t, err)
+	err = ioutil.WriteFile(c.path([]byte("stray")), nil, 0777)
+	require.NoError(t, err)
+
+	buf.Reset()
+	err = c.Dump(&buf, DumpText)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "1 \"a\" (missing value)\n")
+	assert.Contains(t, buf.String(), "anomalies:\n")
+	assert.Contains(t, buf.String(), `value file "stray" is not in the list`)
+
+	buf.Reset()
+	err = c.Dump(&buf, DumpGraphviz)
+	require.NoError(t, err)
+	assert.Contains(t, buf.String(), "digraph lrudir {")
+	assert.Contains(t, buf.String(), "head -> n0;")
+	assert.Contains(t, buf.String(), "n1 -> tail;")
+	assert.Contains(t, buf.String(), `label="\"a\"\nmissing value", color=red`)
+}
@@ -0,0 +1,141 @@
+package lrudir
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Entry is an open handle on the value for a key, returned by OpenEntry. The value
+// stays readable through the handle even if the entry is deleted, evicted, or
+// overwritten while it is open, and the space it takes is only reclaimed once every
+// handle on it has been closed.
+type Entry struct {
+	c    *Cache
+	id   string
+	f    *os.File
+	once sync.Once
+}
+
+// openHandles counts the open Entry handles on the value for an identifier
+type openHandles struct {
+	n     int
+	aside []string // value files moved out of the way while open, removed once n is zero
+}
+
+// OpenEntry opens the value for the given key and moves the entry to the head of the
+// list. The caller must close the returned handle. Deleting or evicting the entry while
+// it is open removes it from the cache straight away, but the file itself is only
+// removed once the last handle on it is closed. On POSIX systems the file is unlinked
+// as usual and the operating system keeps its contents until then. On Windows, where
+// open files cannot be removed, the file is moved into the metadata directory and
+// removed by the last Close.
+func (c *Cache) OpenEntry(key []byte) (*Entry, error) {
+	if len(key) == 0 {
+		return nil, errors.New("cannot get the empty key")
+	}
+
+	err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	id := c.id(key)
+	err = c.checkReadable(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := openShared(c.path(id))
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.access(id)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	if c.shared.handles == nil {
+		c.shared.handles = make(map[string]*openHandles)
+	}
+	h := c.shared.handles[string(id)]
+	if h == nil {
+		h = new(openHandles)
+		c.shared.handles[string(id)] = h
+	}
+	h.n++
+	return &Entry{c: c, id: string(id), f: f}, nil
+}
+
+// Read reads from the value, implementing io.Reader
+func (e *Entry) Read(p []byte) (int, error) {
+	return e.f.Read(p)
+}
+
+// ReadAt reads from the value at the given offset, implementing io.ReaderAt
+func (e *Entry) ReadAt(p []byte, off int64) (int, error) {
+	return e.f.ReadAt(p, off)
+}
+
+// Seek sets the offset for the next Read, implementing io.Seeker
+func (e *Entry) Seek(offset int64, whence int) (int64, error) {
+	return e.f.Seek(offset, whence)
+}
+
+// Stat gets information about the value file
+func (e *Entry) Stat() (os.FileInfo, error) {
+	return e.f.Stat()
+}
+
+// Close closes the handle, removing the value file if the entry was removed from the
+// cache while open and this was the last handle on it. Calling it more than once has no
+// effect.
+func (e *Entry) Close() error {
+	var err error
+	e.once.Do(func() {
+		err = e.f.Close()
+
+		e.c.shared.mu.Lock()
+		defer e.c.shared.mu.Unlock()
+		h := e.c.shared.handles[e.id]
+		h.n--
+		if h.n > 0 {
+			return
+		}
+		delete(e.c.shared.handles, e.id)
+		for _, path := range h.aside {
+			rmerr := os.RemoveAll(path)
+			if rmerr != nil && !os.IsNotExist(rmerr) && err == nil {
+				err = rmerr
+			}
+		}
+	})
+	return err
+}
+
+// setAside moves the value file for the given identifier out of the way if it is open
+// through an Entry and the platform cannot remove or replace open files, so that it can
+// be removed once the last handle is closed. It must be called with the lock held,
+// before removing or replacing the value.
+func (c *Cache) setAside(id []byte) error {
+	h := c.shared.handles[string(id)]
+	if unlinkWhileOpen || h == nil {
+		return nil
+	}
+
+	c.shared.asideSeq++
+	aside := c.internalPath(fmt.Sprintf("%s~open%d-%d~tmp", c.escape(id), os.Getpid(), c.shared.asideSeq))
+	err := os.Rename(c.path(id), aside)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	h.aside = append(h.aside, aside)
+	return nil
+}
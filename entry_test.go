@@ -0,0 +1,94 @@
+package lrudir
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	key := []byte("big")
+	require.NoError(t, c.Put(key, []byte("0123456789")))
+
+	_, err = c.OpenEntry([]byte("missing"))
+	assert.True(t, os.IsNotExist(err))
+
+	e, err := c.OpenEntry(key)
+	require.NoError(t, err)
+	var _ io.ReadSeekCloser = e
+
+	// the value stays readable after the entry is deleted
+	require.NoError(t, c.Delete(key))
+	_, err = c.Get(key)
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = e.Seek(4, io.SeekStart)
+	require.NoError(t, err)
+	buf, err := ioutil.ReadAll(e)
+	require.NoError(t, err)
+	assert.Equal(t, "456789", string(buf))
+
+	require.NoError(t, e.Close())
+	require.NoError(t, e.Close())
+	assert.Empty(t, c.shared.handles)
+	require.NoError(t, c.Verify())
+}
+
+func TestOpenEntrySetAside(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// behave as on Windows, where open files cannot be removed
+	unlinkWhileOpen = false
+	defer func() { unlinkWhileOpen = true }()
+
+	c, err := Create(dir, WithMaxEntries(1))
+	require.NoError(t, err)
+
+	key := []byte("big")
+	require.NoError(t, c.Put(key, []byte("first")))
+
+	e1, err := c.OpenEntry(key)
+	require.NoError(t, err)
+	e2, err := c.OpenEntry(key)
+	require.NoError(t, err)
+
+	// overwriting and then evicting both move the open value aside
+	require.NoError(t, c.Put(key, []byte("second")))
+	e3, err := c.OpenEntry(key)
+	require.NoError(t, err)
+	require.NoError(t, c.Put([]byte("other"), []byte("x")))
+
+	h := c.shared.handles[string(c.id(key))]
+	require.Len(t, h.aside, 2)
+
+	buf, err := ioutil.ReadAll(e3)
+	require.NoError(t, err)
+	assert.Equal(t, "second", string(buf))
+
+	require.NoError(t, e1.Close())
+	require.NoError(t, e3.Close())
+	for _, path := range h.aside {
+		_, err = os.Stat(path)
+		assert.NoError(t, err)
+	}
+
+	require.NoError(t, e2.Close())
+	for _, path := range h.aside {
+		_, err = os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	}
+	require.NoError(t, c.Verify())
+}
@@ -0,0 +1,112 @@
+package lrudir
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// evictionLogName is the name of the eviction log within the metadata directory
+const evictionLogName = "evictions"
+
+// Reasons recorded in the eviction log
+const (
+	reasonCapacity = "capacity" // the cache or a namespace was over its limits
+	reasonExpired  = "expired"  // the entry outlived its TTL
+	reasonCorrupt  = "corrupt"  // Repair found the entry without its value
+)
+
+// EvictionRecord describes one entry in the eviction log
+type EvictionRecord struct {
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace,omitempty"`
+	Key       []byte    `json:"key"`
+	Size      int64     `json:"size"`
+	Reason    string    `json:"reason"`
+}
+
+// WithEvictionLog makes the cache append a record to a log in its metadata directory
+// for every entry that is evicted or expires, so that limits can be tuned from
+// history. When the log grows beyond maxBytes it is rotated, keeping one previous
+// generation, so the log never takes more than about twice that. Logging is best
+// effort: a failure to write the log never fails the operation that evicted.
+func WithEvictionLog(maxBytes int64) Option {
+	return func(c *Cache) {
+		c.evictionLog = maxBytes
+	}
+}
+
+// logEviction appends a record for an evicted entry if logging is enabled. It must be
+// called with the lock held.
+func (c *Cache) logEviction(id []byte, size int64, reason string) {
+	if c.evictionLog <= 0 {
+		return
+	}
+
+	ns, key := splitID(id)
+	line, err := json.Marshal(EvictionRecord{
+		Time:      c.now(),
+		Namespace: ns,
+		Key:       key,
+		Size:      size,
+		Reason:    reason,
+	})
+	if err != nil {
+		return
+	}
+
+	path := c.internalPath(evictionLogName)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0777)
+	if err != nil {
+		return
+	}
+	_, err = f.Write(append(line, '\n'))
+	if err != nil {
+		f.Close()
+		return
+	}
+
+	st, err := f.Stat()
+	f.Close()
+	if err == nil && st.Size() > c.evictionLog {
+		os.Rename(path, path+"~old")
+	}
+}
+
+// EvictionLog reads the eviction log, oldest record first, including the previous
+// generation if there is one. It returns no records if logging was never enabled.
+func (c *Cache) EvictionLog() ([]EvictionRecord, error) {
+	var records []EvictionRecord
+	err := c.view(func() error {
+		path := c.internalPath(evictionLogName)
+		for _, name := range []string{path + "~old", path} {
+			f, err := os.Open(name)
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			s := bufio.NewScanner(f)
+			s.Buffer(nil, 1<<20)
+			for s.Scan() {
+				var r EvictionRecord
+				err = json.Unmarshal(s.Bytes(), &r)
+				if err != nil {
+					f.Close()
+					return corrupt("malformed eviction log record: %v", err)
+				}
+				records = append(records, r)
+			}
+			err = s.Err()
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return records, err
+}
@@ -0,0 +1,68 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvictionLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxEntries(1), WithEvictionLog(1<<20))
+	require.NoError(t, err)
+
+	err = c.Namespace("a").Put([]byte("k1"), []byte("abc"))
+	require.NoError(t, err)
+
+	err = c.PutWithTTL([]byte("k2"), nil, time.Nanosecond)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+	_, err = c.Get([]byte("k2"))
+	assert.True(t, os.IsNotExist(err))
+
+	// explicit deletes are not evictions
+	err = c.Put([]byte("k3"), nil)
+	require.NoError(t, err)
+	err = c.Delete([]byte("k3"))
+	require.NoError(t, err)
+
+	records, err := c.EvictionLog()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+
+	assert.Equal(t, "a", records[0].Namespace)
+	assert.Equal(t, []byte("k1"), records[0].Key)
+	assert.EqualValues(t, 3, records[0].Size)
+	assert.Equal(t, "capacity", records[0].Reason)
+
+	assert.Equal(t, []byte("k2"), records[1].Key)
+	assert.Equal(t, "expired", records[1].Reason)
+}
+
+func TestEvictionLogRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxEntries(1), WithEvictionLog(200))
+	require.NoError(t, err)
+
+	for _, key := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		err = c.PutString(key, nil)
+		require.NoError(t, err)
+	}
+
+	// the log is bounded, and the most recent eviction is always kept
+	records, err := c.EvictionLog()
+	require.NoError(t, err)
+	assert.True(t, len(records) < 7)
+	assert.Equal(t, []byte("g"), records[len(records)-1].Key)
+}
@@ -0,0 +1,26 @@
+//go:build linux && (amd64 || arm64)
+// +build linux
+// +build amd64 arm64
+
+package lrudir
+
+import (
+	"os"
+	"syscall"
+)
+
+// Advice for fadvise, with the values used by posix_fadvise on Linux
+const (
+	adviseSequential = 2
+	adviseWillNeed   = 3
+	adviseDontNeed   = 4
+)
+
+// fadvise tells the kernel how the whole of the given file will be accessed
+func fadvise(f *os.File, advice int) error {
+	_, _, errno := syscall.Syscall6(syscall.SYS_FADVISE64, f.Fd(), 0, 0, uintptr(advice), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+//go:build !linux || !(amd64 || arm64)
+// +build !linux !amd64,!arm64
+
+package lrudir
+
+import (
+	"errors"
+	"os"
+)
+
+// Advice for fadvise, which is only supported on some platforms
+const (
+	adviseSequential = iota
+	adviseWillNeed
+	adviseDontNeed
+)
+
+// errNoFadvise is returned by fadvise on platforms that do not support it
+var errNoFadvise = errors.New("fadvise is not supported on this platform")
+
+// fadvise tells the kernel how the whole of the given file will be accessed
+func fadvise(f *os.File, advice int) error {
+	return errNoFadvise
+}
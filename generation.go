@@ -0,0 +1,68 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// generationFile is the name of the file in the metadata directory that holds the
+// current generation. It is separate from the state so that reads can check it cheaply,
+// and it does not exist until the first Bump.
+const generationFile = "generation"
+
+// reasonInvalidated is the eviction reason for entries written before a Bump
+const reasonInvalidated = "invalidated"
+
+// Bump invalidates every entry in the cache, across all namespaces, in constant time.
+// Entries written before the call are treated as absent from then on, and are removed
+// as they are encountered by reads, by ExpireNow, or by eviction.
+func (c *Cache) Bump() error {
+	return c.update(func(x *state) error {
+		gen, err := c.generation()
+		if err != nil {
+			return err
+		}
+
+		path := c.internalPath(generationFile)
+		err = ioutil.WriteFile(path+"~tmp", []byte(strconv.FormatInt(gen+1, 10)), 0777)
+		if err != nil {
+			return err
+		}
+		return c.renameFile(path+"~tmp", path)
+	})
+}
+
+// generation gets the current generation, which is zero until the first Bump
+func (c *Cache) generation() (int64, error) {
+	buf, err := ioutil.ReadFile(c.internalPath(generationFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	gen, err := strconv.ParseInt(strings.TrimSpace(string(buf)), 10, 64)
+	if err != nil {
+		return 0, corrupt("generation file contains %q", buf)
+	}
+	return gen, nil
+}
+
+// invalid gets the reason that an entry with the given metadata should be treated as
+// absent, or the empty string if the entry is live
+func (c *Cache) invalid(m *meta) (string, error) {
+	if m.expired(c.now()) {
+		return reasonExpired, nil
+	}
+	gen, err := c.generation()
+	if err != nil {
+		return "", err
+	}
+	if m.Gen < gen {
+		return reasonInvalidated, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,77 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBump(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	err = c.Bump()
+	require.NoError(t, err)
+
+	err = c.Put(k3, []byte("three"))
+	require.NoError(t, err)
+
+	_, err = c.Get(k1)
+	assert.True(t, os.IsNotExist(err))
+
+	found, err := c.Exists(k2)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	val, err := c.Get(k3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("three"), val)
+
+	// appending to an invalidated entry starts it afresh
+	err = c.Append(k2, []byte("2"))
+	require.NoError(t, err)
+
+	val, err = c.Get(k2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("2"), val)
+
+	// entries are invalidated in every namespace, and removed lazily
+	err = c.Namespace("ns").Put(k1, []byte("uno"))
+	require.NoError(t, err)
+
+	err = c.Bump()
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	err = c.ExpireNow()
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	keys, err = c.Namespace("ns").Keys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	err = c.Verify()
+	require.NoError(t, err)
+}
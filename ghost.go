@@ -0,0 +1,78 @@
+package lrudir
+
+// WithGhostList makes the cache remember the keys of the last n entries that this
+// process evicted to stay within its limits, and count the misses for those keys in
+// the GhostHits field of Stats. Such misses would have been hits in a cache that could
+// hold n more entries, so with n equal to the number of entries the cache holds, the
+// ratio of GhostHits to Misses is the fraction of misses that doubling its capacity
+// would avoid. The keys are remembered in memory, so evictions made by other processes
+// are not counted, just as their hits and misses are not.
+func WithGhostList(n int) Option {
+	return func(c *Cache) {
+		c.ghostSize = n
+	}
+}
+
+// ghostList is a bounded list of recently evicted identifiers
+type ghostList struct {
+	ring []ghost          // the identifiers in the order they were evicted, as a ring
+	next int              // position in ring that the next identifier replaces, once full
+	seq  int64            // number of identifiers added so far
+	set  map[string]int64 // sequence number of each identifier still remembered
+}
+
+// ghost is an identifier in the ghost list along with its sequence number
+type ghost struct {
+	id  string
+	seq int64
+}
+
+// rememberGhost adds an identifier to the ghost list after it has been evicted
+func (c *Cache) rememberGhost(id []byte) {
+	if c.ghostSize <= 0 {
+		return
+	}
+
+	c.shared.gmu.Lock()
+	defer c.shared.gmu.Unlock()
+	g := &c.shared.ghosts
+	if g.set == nil {
+		g.set = make(map[string]int64)
+	}
+
+	g.seq++
+	add := ghost{id: string(id), seq: g.seq}
+	if len(g.ring) < c.ghostSize {
+		g.ring = append(g.ring, add)
+	} else {
+		old := g.ring[g.next]
+		// the identifier may have been forgotten, or evicted again since
+		if g.set[old.id] == old.seq {
+			delete(g.set, old.id)
+		}
+		g.ring[g.next] = add
+		g.next = (g.next + 1) % len(g.ring)
+	}
+	g.set[add.id] = add.seq
+}
+
+// forgetGhost removes an identifier from the ghost list once it has been written again
+func (c *Cache) forgetGhost(id []byte) {
+	if c.ghostSize <= 0 {
+		return
+	}
+	c.shared.gmu.Lock()
+	delete(c.shared.ghosts.set, string(id))
+	c.shared.gmu.Unlock()
+}
+
+// isGhost returns true if the identifier is in the ghost list
+func (c *Cache) isGhost(id []byte) bool {
+	if c.ghostSize <= 0 {
+		return false
+	}
+	c.shared.gmu.Lock()
+	defer c.shared.gmu.Unlock()
+	_, ok := c.shared.ghosts.set[string(id)]
+	return ok
+}
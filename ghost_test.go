@@ -0,0 +1,49 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGhostList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxEntries(2), WithGhostList(2))
+	require.NoError(t, err)
+
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		err = c.Put([]byte(k), []byte("x"))
+		require.NoError(t, err)
+	}
+
+	// "a" was evicted longest ago and has been forgotten, while "b" and "c" would
+	// still be in a cache of twice the size
+	for _, k := range []string{"a", "b", "c", "z"} {
+		_, err = c.Get([]byte(k))
+		assert.True(t, os.IsNotExist(err))
+	}
+
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, s.Misses)
+	assert.EqualValues(t, 2, s.GhostHits)
+
+	// a key written again is no longer a ghost
+	err = c.Put([]byte("c"), []byte("x"))
+	require.NoError(t, err)
+	err = c.Delete([]byte("c"))
+	require.NoError(t, err)
+	_, err = c.Get([]byte("c"))
+	assert.True(t, os.IsNotExist(err))
+
+	s, err = c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 5, s.Misses)
+	assert.EqualValues(t, 2, s.GhostHits)
+}
@@ -0,0 +1,194 @@
+package lrudir
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// HandlerOption configures a handler created by CacheHandler
+type HandlerOption func(*cacheHandler)
+
+// WithResponseTTL sets how long a stored response is replayed before next is called
+// again. Zero, the default, keeps responses until they are evicted.
+func WithResponseTTL(ttl time.Duration) HandlerOption {
+	return func(h *cacheHandler) {
+		h.ttl = ttl
+	}
+}
+
+// WithMaxResponseBytes stops responses with bodies larger than n bytes from being
+// stored. They are still passed through to the client.
+func WithMaxResponseBytes(n int64) HandlerOption {
+	return func(h *cacheHandler) {
+		h.maxBytes = n
+	}
+}
+
+// CacheHandler returns a handler that stores the responses produced by next in c and
+// replays them, status and headers included, for later requests with the same key.
+// Only GET requests that produce a 200 response are stored, and not if the response
+// sets a cookie or has a Cache-Control header with the private or no-store directive.
+// Hop-by-hop headers such as Connection are not stored. If keyFunc is nil the
+// host and request URI are used as the key; if it returns an empty key the request is
+// passed straight to next.
+func CacheHandler(c *Cache, keyFunc func(*http.Request) []byte, next http.Handler, opts ...HandlerOption) http.Handler {
+	if keyFunc == nil {
+		keyFunc = func(r *http.Request) []byte {
+			return []byte(r.Host + r.URL.RequestURI())
+		}
+	}
+	h := &cacheHandler{c: c, keyFunc: keyFunc, next: next}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+type cacheHandler struct {
+	c        *Cache
+	keyFunc  func(*http.Request) []byte
+	next     http.Handler
+	ttl      time.Duration
+	maxBytes int64
+}
+
+// ServeHTTP implements http.Handler
+func (h *cacheHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var key []byte
+	if r.Method == http.MethodGet {
+		key = h.keyFunc(r)
+	}
+	if len(key) == 0 {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	buf, err := h.c.Get(key)
+	if err == nil {
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf)), r)
+		if err == nil {
+			replay(w, resp)
+			return
+		}
+	} else if !os.IsNotExist(err) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rec := &recorder{ResponseWriter: w, status: http.StatusOK, max: h.maxBytes}
+	h.next.ServeHTTP(rec, r)
+	if rec.status != http.StatusOK || rec.overflow || !storable(w.Header()) {
+		return
+	}
+
+	resp := http.Response{
+		StatusCode:    rec.status,
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        endToEnd(w.Header()),
+		Body:          ioutil.NopCloser(&rec.body),
+		ContentLength: int64(rec.body.Len()),
+	}
+	var out bytes.Buffer
+	err = resp.Write(&out)
+	if err != nil {
+		return
+	}
+
+	// the response has already been sent, so failing to store it is not reported
+	if h.ttl > 0 {
+		h.c.PutWithTTL(key, out.Bytes(), h.ttl)
+	} else {
+		h.c.Put(key, out.Bytes())
+	}
+}
+
+// hopByHop lists the headers that apply to a single connection and so are never
+// stored, as given in RFC 7230 section 6.1
+var hopByHop = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// storable returns false if a response with the given headers is specific to the
+// client it was sent to, or asks not to be stored
+func storable(header http.Header) bool {
+	if len(header.Values("Set-Cookie")) > 0 {
+		return false
+	}
+	for _, v := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(v, ",") {
+			name := strings.SplitN(strings.TrimSpace(directive), "=", 2)[0]
+			if strings.EqualFold(name, "private") || strings.EqualFold(name, "no-store") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// endToEnd returns a copy of the given headers without the hop-by-hop headers,
+// including any named by the Connection header
+func endToEnd(header http.Header) http.Header {
+	out := header.Clone()
+	for _, v := range header.Values("Connection") {
+		for _, name := range strings.Split(v, ",") {
+			out.Del(strings.TrimSpace(name))
+		}
+	}
+	for _, name := range hopByHop {
+		out.Del(name)
+	}
+	return out
+}
+
+// replay writes a stored response to w
+func replay(w http.ResponseWriter, resp *http.Response) {
+	defer resp.Body.Close()
+	for k, vs := range resp.Header {
+		w.Header()[k] = vs
+	}
+	w.WriteHeader(resp.StatusCode)
+	body, _ := ioutil.ReadAll(resp.Body)
+	w.Write(body)
+}
+
+// recorder passes a response through to the client while keeping a copy of it
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	max      int64
+	overflow bool // whether the body has outgrown max and is no longer being kept
+}
+
+// WriteHeader implements http.ResponseWriter
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter
+func (r *recorder) Write(p []byte) (int, error) {
+	if !r.overflow {
+		if r.max > 0 && int64(r.body.Len()+len(p)) > r.max {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(p)
+		}
+	}
+	return r.ResponseWriter.Write(p)
+}
@@ -0,0 +1,109 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	var renders int
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders++
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("X-Render", "yes")
+		if r.URL.Path == "/big" {
+			w.Write([]byte("0123456789"))
+			return
+		}
+		w.Write([]byte("page " + r.URL.Path))
+	})
+
+	h := CacheHandler(c, nil, next, WithMaxResponseBytes(8))
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	w := get("/a")
+	assert.Equal(t, "page /a", w.Body.String())
+
+	w = get("/a")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "page /a", w.Body.String())
+	assert.Equal(t, "text/plain", w.Header().Get("Content-Type"))
+	assert.Equal(t, "yes", w.Header().Get("X-Render"))
+	assert.Equal(t, 1, renders)
+
+	// responses over the size cap are passed through but not stored
+	w = get("/big")
+	assert.Equal(t, "0123456789", w.Body.String())
+	get("/big")
+	assert.Equal(t, 3, renders)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 1)
+}
+
+func TestCacheHandlerPrivateResponses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	renders := make(map[string]int)
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders[r.URL.Path]++
+		switch r.URL.Path {
+		case "/cookie":
+			w.Header().Set("Set-Cookie", "session=secret")
+		case "/private":
+			w.Header().Set("Cache-Control", "max-age=60, Private")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+			w.Header().Set("Connection", "X-Hop")
+			w.Header().Set("X-Hop", "1")
+			w.Header().Set("Keep-Alive", "timeout=5")
+		}
+		w.Write([]byte("page " + r.URL.Path))
+	})
+
+	h := CacheHandler(c, nil, next)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	for _, path := range []string{"/cookie", "/private", "/no-store", "/public"} {
+		get(path)
+		w := get(path)
+		assert.Equal(t, "page "+path, w.Body.String())
+	}
+	assert.Equal(t, map[string]int{"/cookie": 2, "/private": 2, "/no-store": 2, "/public": 1}, renders)
+
+	// the replayed response keeps only the end-to-end headers
+	w := get("/public")
+	assert.Equal(t, "public, max-age=60", w.Header().Get("Cache-Control"))
+	assert.Empty(t, w.Header().Get("Connection"))
+	assert.Empty(t, w.Header().Get("X-Hop"))
+	assert.Empty(t, w.Header().Get("Keep-Alive"))
+}
@@ -0,0 +1,14 @@
+//go:build !windows
+// +build !windows
+
+package lrudir
+
+import "os"
+
+// unlinkWhileOpen is whether the platform can remove or replace a file that is open
+var unlinkWhileOpen = true
+
+// openShared opens a value file for reading through an Entry
+func openShared(path string) (*os.File, error) {
+	return os.Open(path)
+}
@@ -0,0 +1,25 @@
+package lrudir
+
+import (
+	"os"
+	"syscall"
+)
+
+// unlinkWhileOpen is whether the platform can remove or replace a file that is open
+var unlinkWhileOpen = false
+
+// openShared opens a value file for reading through an Entry. Unlike os.Open it allows
+// the file to be renamed while open, so that setAside can move it out of the way.
+func openShared(path string) (*os.File, error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	h, err := syscall.CreateFile(p, syscall.GENERIC_READ,
+		syscall.FILE_SHARE_READ|syscall.FILE_SHARE_WRITE|syscall.FILE_SHARE_DELETE,
+		nil, syscall.OPEN_EXISTING, syscall.FILE_FLAG_BACKUP_SEMANTICS, 0)
+	if err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return os.NewFile(uintptr(h), path), nil
+}
@@ -0,0 +1,40 @@
+package lrudir
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// Health performs a cheap self-check suitable for a readiness probe: that the lock can
+// be acquired, that the ends of the list are intact, that the state file can be read,
+// and that the metadata directory is writable. It blocks while another process holds
+// the lock, so callers that need a deadline should run it with one.
+func (c *Cache) Health() error {
+	err := c.lock()
+	if err != nil {
+		return fmt.Errorf("acquiring lock: %w", err)
+	}
+	defer c.unlock()
+
+	err = c.checkSentinels()
+	if err != nil {
+		return fmt.Errorf("checking list: %w", err)
+	}
+
+	_, err = c.state()
+	if err != nil {
+		return fmt.Errorf("reading state: %w", err)
+	}
+
+	path := c.internalPath("health~tmp")
+	err = ioutil.WriteFile(path, []byte("ok"), 0777)
+	if err != nil {
+		return fmt.Errorf("writing to cache directory: %w", err)
+	}
+	err = os.Remove(path)
+	if err != nil {
+		return fmt.Errorf("writing to cache directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package lrudir
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("foo"), nil)
+	require.NoError(t, err)
+
+	assert.NoError(t, c.Health())
+
+	err = os.Remove(c.nextPtr(nil))
+	require.NoError(t, err)
+
+	assert.Error(t, c.Health())
+
+	err = os.Remove(c.internalPath("state"))
+	require.NoError(t, err)
+	err = ioutil.WriteFile(c.nextPtr(nil), []byte("foo"), 0777)
+	require.NoError(t, err)
+
+	err = c.Health()
+	assert.True(t, os.IsNotExist(errors.Unwrap(err)))
+}
@@ -0,0 +1,84 @@
+package lrudir
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// WithStreamingHints makes reads of values of at least minBytes tell the kernel that
+// the value will be read sequentially, so that it reads ahead, and then that the value
+// is no longer needed, so that serving large values does not push the rest of the
+// working set out of the page cache. Get and its variants give the second hint as soon
+// as the value has been read, and readers returned by OpenReader give it when they are
+// closed. The hints are given with posix_fadvise and are skipped on platforms that do
+// not support it.
+func WithStreamingHints(minBytes int64) Option {
+	return func(c *Cache) {
+		c.hintBytes = minBytes
+	}
+}
+
+// readValue reads the value file at the given path, giving the hints configured by
+// WithStreamingHints
+func (c *Cache) readValue(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	hint := c.hintBytes > 0 && st.Size() >= c.hintBytes
+	if hint {
+		fadvise(f, adviseSequential)
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0, st.Size()+bytes.MinRead))
+	_, err = buf.ReadFrom(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if hint {
+		fadvise(f, adviseDontNeed)
+	}
+	return buf.Bytes(), nil
+}
+
+// hintedFile is a value file opened by OpenReader that drops the value from the page
+// cache when it is closed
+type hintedFile struct {
+	*os.File
+}
+
+// Close gives the hint that the value is no longer needed and closes the file
+func (f hintedFile) Close() error {
+	fadvise(f.File, adviseDontNeed)
+	return f.File.Close()
+}
+
+// hinted gives the hints configured by WithStreamingHints for a value file that is
+// about to be read from start to end, returning a reader that gives the final hint
+// when closed
+func (c *Cache) hinted(f *os.File) (io.ReadCloser, error) {
+	if c.hintBytes <= 0 {
+		return f, nil
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if st.Size() < c.hintBytes {
+		return f, nil
+	}
+
+	fadvise(f, adviseSequential)
+	return hintedFile{f}, nil
+}
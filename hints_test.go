@@ -0,0 +1,57 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamingHints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithStreamingHints(4))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("small"), []byte("abc"))
+	require.NoError(t, err)
+	err = c.Put([]byte("large"), []byte("abcdefgh"))
+	require.NoError(t, err)
+	err = c.Put([]byte("empty"), nil)
+	require.NoError(t, err)
+
+	val, err := c.Get([]byte("small"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abc"), val)
+
+	val, err = c.Get([]byte("large"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abcdefgh"), val)
+
+	val, err = c.Get([]byte("empty"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{}, val)
+
+	r, err := c.OpenReader([]byte("large"))
+	require.NoError(t, err)
+	_, hinted := r.(hintedFile)
+	assert.True(t, hinted)
+	val, err = ioutil.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("abcdefgh"), val)
+	require.NoError(t, r.Close())
+
+	r, err = c.OpenReader([]byte("small"))
+	require.NoError(t, err)
+	_, hinted = r.(hintedFile)
+	assert.False(t, hinted)
+	require.NoError(t, r.Close())
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("small"), []byte("large"), []byte("empty")}, keys)
+}
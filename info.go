@@ -0,0 +1,87 @@
+package lrudir
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// EntryInfo describes an entry in the cache
+type EntryInfo struct {
+	Key      []byte
+	Size     int64     // size of the value in bytes
+	LastUsed time.Time // when the entry was last written or moved to the head of the list, or zero if not recorded
+	Accesses int64     // number of reads that have moved the entry to the head of the list
+	Expires  time.Time // when the entry expires, or zero if it does not
+}
+
+// Stat describes the entry for the given key without reading its value or moving it to
+// the head of the list. It returns an error satisfying os.IsNotExist if there is no
+// unexpired entry. Reads are counted in Accesses when they move the entry, so reads
+// skipped by WithPromoteAfter are not counted, and with WithBatchedPromotion reads are
+// counted once they are applied.
+func (c *Cache) Stat(key []byte) (EntryInfo, error) {
+	if len(key) == 0 {
+		return EntryInfo{}, errors.New("cannot stat the empty key")
+	}
+
+	var info EntryInfo
+	err := c.view(func() error {
+		id := c.id(key)
+		found, err := c.exists(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &os.PathError{Op: "stat", Path: c.path(id), Err: os.ErrNotExist}
+		}
+		info, err = c.info(id, key)
+		return err
+	})
+	return info, err
+}
+
+// KeysWithInfo is like Keys but describes each entry as Stat does
+func (c *Cache) KeysWithInfo() ([]EntryInfo, error) {
+	var infos []EntryInfo
+	err := c.view(func() error {
+		return c.walk(func(id []byte) error {
+			key, ok := c.key(id)
+			if !ok {
+				return nil
+			}
+			info, err := c.info(id, key)
+			if err != nil {
+				return err
+			}
+			infos = append(infos, info)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// info describes the entry for the given identifier, which has the given key
+func (c *Cache) info(id, key []byte) (EntryInfo, error) {
+	size, err := valueSize(c.path(id))
+	if err != nil {
+		return EntryInfo{}, err
+	}
+
+	m, err := c.readMeta(id)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+
+	info := EntryInfo{Key: key, Size: size, Accesses: m.Accesses}
+	if m.Used != 0 {
+		info.LastUsed = time.Unix(0, m.Used)
+	}
+	if m.Expires != 0 {
+		info.Expires = time.Unix(0, m.Expires)
+	}
+	return info, nil
+}
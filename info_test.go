@@ -0,0 +1,78 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := Create(dir, WithClock(clk))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("a"), []byte("abc"))
+	require.NoError(t, err)
+	err = c.PutWithTTL([]byte("b"), []byte("x"), time.Hour)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		clk.t = clk.t.Add(time.Minute)
+		_, err = c.Get([]byte("a"))
+		require.NoError(t, err)
+	}
+
+	info, err := c.Stat([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a"), info.Key)
+	assert.EqualValues(t, 3, info.Size)
+	assert.EqualValues(t, 3, info.Accesses)
+	assert.True(t, clk.t.Equal(info.LastUsed))
+	assert.True(t, info.Expires.IsZero())
+
+	// overwriting keeps the count
+	err = c.Put([]byte("a"), []byte("abcd"))
+	require.NoError(t, err)
+
+	infos, err := c.KeysWithInfo()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, []byte("a"), infos[0].Key)
+	assert.EqualValues(t, 4, infos[0].Size)
+	assert.EqualValues(t, 3, infos[0].Accesses)
+	assert.Equal(t, []byte("b"), infos[1].Key)
+	assert.EqualValues(t, 0, infos[1].Accesses)
+	assert.False(t, infos[1].Expires.IsZero())
+
+	_, err = c.Stat([]byte("missing"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestBatchedAccessCount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithBatchedPromotion(0, 0))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("a"), []byte("abc"))
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		_, err = c.Get([]byte("a"))
+		require.NoError(t, err)
+	}
+	require.NoError(t, c.flushAccesses())
+
+	info, err := c.Stat([]byte("a"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, info.Accesses)
+}
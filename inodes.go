@@ -0,0 +1,54 @@
+package lrudir
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// WithMaxInodes limits the number of files the cache creates for its entries, counting
+// each value file (or every file and directory within a directory value) together with
+// the pointer, metadata, and key files kept alongside it, and evicts the least recently
+// used entries to stay under the limit. Workloads with many small values can run out of
+// inodes long before they run out of bytes. The metadata file is counted whether or not
+// an entry currently has one, so that the count does not change as metadata is set and
+// cleared. Lease files and the fixed files in the metadata directory are not counted.
+func WithMaxInodes(n int) Option {
+	return func(c *Cache) {
+		c.limits.inodes = int64(n)
+	}
+}
+
+// entryInodes gets the number of files that the entry with the given identifier counts
+// for in the usage, given the path to its value
+func (c *Cache) entryInodes(id []byte, path string) (int64, error) {
+	st, err := os.Lstat(path)
+	if err != nil {
+		return 0, err
+	}
+
+	n := int64(1)
+	if st.IsDir() {
+		n = 0
+		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			n++
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if !c.orderFile {
+		n += 2 // the next and previous pointers
+	}
+	if !c.xattrMeta {
+		n++
+	}
+	if isHashed(c.escape(id)) {
+		n++
+	}
+	return n, nil
+}
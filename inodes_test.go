@@ -0,0 +1,61 @@
+package lrudir
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxInodes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// each entry is a value, two pointers, and a metadata file
+	c, err := Create(dir, WithMaxInodes(10))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, c.Put([]byte(fmt.Sprint(i)), []byte("x")))
+	}
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, s.Entries)
+	assert.EqualValues(t, 8, s.Inodes)
+
+	// replacing an entry does not count it twice
+	require.NoError(t, c.Put([]byte("2"), []byte("y")))
+	s, err = c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, s.Entries)
+	assert.EqualValues(t, 8, s.Inodes)
+
+	require.NoError(t, c.Delete([]byte("2")))
+	s, err = c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 4, s.Inodes)
+	require.NoError(t, c.Verify())
+}
+
+func TestMaxInodesOrderFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// without pointer files each entry is a value and a metadata file
+	c, err := Create(dir, WithOrderFile(), WithMaxInodes(5))
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, c.Put([]byte(fmt.Sprint(i)), []byte("x")))
+	}
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, s.Entries)
+	assert.EqualValues(t, 4, s.Inodes)
+	require.NoError(t, c.Verify())
+}
@@ -0,0 +1,93 @@
+package lrudir
+
+import "time"
+
+// WithJanitor starts a goroutine that removes expired entries every interval, until the
+// cache is closed. Expired entries are always removed when they are read, but without a
+// janitor an entry that is never read again keeps its disk space until it is evicted.
+func WithJanitor(interval time.Duration) Option {
+	return func(c *Cache) {
+		c.janitor = interval
+	}
+}
+
+// ExpireNow removes every expired entry in the cache, across all namespaces, under a
+// single acquisition of the lock, along with any entries invalidated by Bump. Leased
+// entries, including those in use through DirPath, are left until they are released.
+func (c *Cache) ExpireNow() error {
+	return c.update(c.expire)
+}
+
+// expire implements ExpireNow. It must be called with the lock held.
+func (c *Cache) expire(x *state) error {
+	var expired [][]byte
+	var reasons []string
+	err := c.walk(func(id []byte) error {
+		if c.leased(id) {
+			return nil
+		}
+		m, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+		reason, err := c.invalid(m)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			expired = append(expired, id)
+			reasons = append(reasons, reason)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i, id := range expired {
+		err = c.discard(x, id, EventEvict, reasons[i])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startJanitor starts the goroutine requested by WithJanitor, if any
+func (c *Cache) startJanitor() {
+	if c.janitor <= 0 {
+		return
+	}
+
+	done, stopped := make(chan struct{}), make(chan struct{})
+	if !c.onClose(func() { close(done); <-stopped }) {
+		return
+	}
+
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(c.janitor)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				err := c.ExpireNow()
+				if err != nil {
+					c.debug("janitor failed to remove expired entries", "err", err)
+				}
+				err = c.evictToWatermark()
+				if err != nil {
+					c.debug("janitor failed to evict", "err", err)
+				}
+				if c.janitorCompacts {
+					_, _, err = c.Compact()
+					if err != nil {
+						c.debug("janitor failed to compact", "err", err)
+					}
+				}
+			}
+		}
+	}()
+}
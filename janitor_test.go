@@ -0,0 +1,76 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpireNow(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.PutWithTTL([]byte("short"), []byte("x"), time.Nanosecond)
+	require.NoError(t, err)
+
+	err = c.PutWithTTL([]byte("long"), []byte("y"), time.Hour)
+	require.NoError(t, err)
+
+	err = c.Namespace("ns").PutWithTTL([]byte("short"), []byte("z"), time.Nanosecond)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+
+	// expired entries stay in the list until something removes them
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	err = c.ExpireNow()
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("long")}, keys)
+
+	keys, err = c.Namespace("ns").Keys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	err = c.Verify()
+	require.NoError(t, err)
+}
+
+func TestJanitor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithJanitor(time.Millisecond))
+	require.NoError(t, err)
+
+	err = c.PutWithTTL([]byte("short"), []byte("x"), time.Nanosecond)
+	require.NoError(t, err)
+
+	var keys [][]byte
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		keys, err = c.Keys()
+		require.NoError(t, err)
+		if len(keys) == 0 {
+			break
+		}
+	}
+	assert.Empty(t, keys)
+
+	err = c.Close()
+	require.NoError(t, err)
+}
@@ -0,0 +1,317 @@
+package lrudir
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// journalFile is the name of the file in the metadata directory that lists the entries
+// being changed by the process holding the lock. It is removed when the lock is
+// released, so finding it on taking the lock means that its owner crashed part way
+// through an operation.
+const journalFile = "journal"
+
+// journal records that the entries for the given identifiers are about to be changed,
+// creating the journal for this acquisition of the lock if necessary. The sentinels,
+// given as nil, need no record. It must be called with the lock held.
+func (c *Cache) journal(ids ...[]byte) error {
+	var buf []byte
+	for _, id := range ids {
+		if len(id) == 0 || string(id) == c.shared.journalLast {
+			continue
+		}
+		c.shared.journalLast = string(id)
+		buf = append(buf, base64.StdEncoding.EncodeToString(id)...)
+		buf = append(buf, '\n')
+	}
+	return c.appendJournal(buf)
+}
+
+// appendJournal adds the given lines to the journal, creating it if necessary
+func (c *Cache) appendJournal(buf []byte) error {
+	if len(buf) == 0 {
+		return nil
+	}
+
+	flag := os.O_WRONLY | os.O_APPEND
+	if !c.shared.journaled {
+		flag |= os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(c.internalPath(journalFile), flag, 0777)
+	if err != nil {
+		return err
+	}
+	c.shared.journaled = true
+
+	_, err = f.Write(buf)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// endJournal removes the journal, if one was created while the lock was held, since the
+// operations it covers are complete. The journal is kept if recovering from it failed,
+// and later operations add to it rather than replacing it, so that it is recovered by
+// the next Open. It must be called just before releasing the lock.
+func (c *Cache) endJournal() error {
+	if !c.shared.journaled {
+		return nil
+	}
+	c.shared.journalLast = ""
+	if c.shared.journalKept {
+		return nil
+	}
+	c.shared.journaled = false
+	err := os.Remove(c.internalPath(journalFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// recoverJournal repairs the cache if a process crashed part way through an operation,
+// as shown by the journal it left behind. The list is rebuilt from its forward and
+// backward pointers, which between them reach every entry that was in the list whatever
+// step the crash interrupted. Journaled entries that are no longer in the list but
+// still have a value were being moved or added, and are rolled forward by attaching
+// them at the head, while entries whose value is gone were being deleted, and their
+// remaining files are removed. A transaction whose record in the journal is complete
+// is then rolled forward, and staged files are rolled back, since there is no telling
+// whether they were complete. Finally the usage is recounted. What was recovered is
+// logged to the logger given to WithLogger. If recovery fails the journal is kept. It
+// must be called with the lock held.
+func (c *Cache) recoverJournal() (err error) {
+	buf, err := ioutil.ReadFile(c.internalPath(journalFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// keep the journal until recovery is complete, in case this process crashes too,
+	// and beyond if recovery fails
+	c.shared.journaled = true
+	defer func() {
+		c.shared.journalKept = err != nil
+	}()
+
+	var journaled [][]byte
+	var pending, committed []txOp
+	for _, line := range strings.Split(string(buf), "\n") {
+		// the last line may have been cut short by the crash
+		if op, ok := parseTxOp(line); ok {
+			pending = append(pending, op)
+			continue
+		}
+		if line == txCommit {
+			committed = pending
+			continue
+		}
+		id, err := base64.StdEncoding.DecodeString(line)
+		if err == nil && len(id) > 0 {
+			journaled = append(journaled, id)
+		}
+	}
+
+	relinked, dropped, err := c.rebuildList(journaled)
+	if err != nil {
+		return err
+	}
+
+	x, err := c.state()
+	if err != nil {
+		return err
+	}
+
+	err = c.replayTx(x, committed)
+	if err != nil {
+		return err
+	}
+
+	staged, err := c.rollBackStaged()
+	if err != nil {
+		return err
+	}
+
+	x.Usage, err = c.count()
+	if err != nil {
+		return err
+	}
+	err = c.setState(x)
+	if err != nil {
+		return err
+	}
+
+	c.debug("recovered from interrupted operation", "dir", c.Dir, "staged", staged,
+		"relinked", relinked, "dropped", len(dropped), "transaction", len(committed))
+	return nil
+}
+
+// rollBackStaged removes files staged by the interrupted operation, returning how many
+// there were. Values staged by Put before taking the lock are left alone because they
+// may belong to a write still in progress, and are removed by Compact instead.
+func (c *Cache) rollBackStaged() (int, error) {
+	dirs := []string{c.internalPath(""), c.internalPath(blobDir)}
+	if c.stagingDir != "" {
+		dirs = append(dirs, c.stagingDir)
+	}
+
+	var n int
+	for _, dir := range dirs {
+		names, err := readDirNames(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		for _, name := range names {
+			if !strings.HasSuffix(name, "~tmp") || strings.HasPrefix(name, "put~") {
+				continue
+			}
+			err = os.RemoveAll(filepath.Join(dir, name))
+			if err != nil {
+				return 0, err
+			}
+			n++
+		}
+	}
+	return n, nil
+}
+
+// rebuildList relinks every entry reachable from the list or named in the journal,
+// returning the number of pointers rewritten and the identifiers of the entries dropped
+// because their value is gone
+func (c *Cache) rebuildList(journaled [][]byte) (int, [][]byte, error) {
+	forward := c.chain(c.nextPtr)
+	backward := c.chain(c.prevPtr)
+
+	// entries reached only going backward are placed after their successor in that
+	// direction, which is the entry more recent than them
+	inForward := make(map[string]bool)
+	for _, id := range forward {
+		inForward[string(id)] = true
+	}
+	after := make(map[string][][]byte)
+	for i := len(backward) - 1; i >= 0; i-- {
+		if inForward[string(backward[i])] {
+			continue
+		}
+		var pred []byte
+		if i+1 < len(backward) {
+			pred = backward[i+1]
+		}
+		after[string(pred)] = append(after[string(pred)], backward[i])
+	}
+
+	var order [][]byte
+	placed := make(map[string]bool)
+	var emit func(id []byte)
+	emit = func(id []byte) {
+		if !placed[string(id)] {
+			placed[string(id)] = true
+			order = append(order, id)
+		}
+		for _, next := range after[string(id)] {
+			emit(next)
+		}
+	}
+	for _, next := range after[""] {
+		emit(next)
+	}
+	for _, id := range forward {
+		emit(id)
+	}
+
+	// journaled entries missing from the list go at the head, most recent first
+	var head [][]byte
+	for i := len(journaled) - 1; i >= 0; i-- {
+		if !placed[string(journaled[i])] {
+			placed[string(journaled[i])] = true
+			head = append(head, journaled[i])
+		}
+	}
+	order = append(head, order...)
+
+	var live [][]byte
+	var dropped [][]byte
+	for _, id := range order {
+		_, err := os.Lstat(c.path(id))
+		if err == nil {
+			live = append(live, id)
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return 0, nil, err
+		}
+		for _, path := range []string{c.nextPtr(id), c.prevPtr(id)} {
+			err = c.removePtr(path)
+			if err != nil && !os.IsNotExist(err) {
+				return 0, nil, err
+			}
+		}
+		for _, path := range []string{c.metaPtr(id), c.keyPtr(id)} {
+			err = os.Remove(path)
+			if err != nil && !os.IsNotExist(err) {
+				return 0, nil, err
+			}
+		}
+		dropped = append(dropped, id)
+	}
+
+	relinked, err := c.relink(live)
+	if err != nil {
+		return 0, nil, err
+	}
+	return relinked, dropped, nil
+}
+
+// relink makes the list consist of the given identifiers, from the head to the tail,
+// rewriting only the pointers that differ and returning how many there were
+func (c *Cache) relink(ids [][]byte) (int, error) {
+	var relinked int
+	var prev []byte
+	for _, id := range append(ids, nil) {
+		for _, p := range []struct {
+			path string
+			to   []byte
+		}{{c.nextPtr(prev), id}, {c.prevPtr(id), prev}} {
+			cur, err := c.readPtr(p.path)
+			if err == nil && bytes.Equal(cur, p.to) {
+				continue
+			}
+			err = c.writePtr(p.path, p.to)
+			if err != nil {
+				return 0, err
+			}
+			relinked++
+		}
+		prev = id
+	}
+	return relinked, nil
+}
+
+// chain follows the pointers given by ptr from the sentinel, returning the identifiers
+// reached in order. Unlike walk, it stops quietly at a missing pointer or a cycle, since
+// it is used to salvage what it can from a damaged list.
+func (c *Cache) chain(ptr func(id []byte) string) [][]byte {
+	var ids [][]byte
+	seen := make(map[string]bool)
+	var id []byte
+	for {
+		next, err := c.readPtr(ptr(id))
+		if err != nil || len(next) == 0 || seen[string(next)] {
+			return ids
+		}
+		seen[string(next)] = true
+		ids = append(ids, next)
+		id = next
+	}
+}
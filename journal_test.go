@@ -0,0 +1,114 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// crash releases the lock without removing the journal, as if the process holding it
+// had died
+func crash(c *Cache) {
+	c.shared.journaled = false
+	c.unlock()
+}
+
+func TestRecoverJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		err = c.Put([]byte(k), []byte("value-"+k))
+		require.NoError(t, err)
+	}
+
+	// crash part way through moving "b" to the head, and part way through deleting "c"
+	require.NoError(t, c.lock())
+	require.NoError(t, c.detach(c.id([]byte("b"))))
+	require.NoError(t, c.detach(c.id([]byte("c"))))
+	require.NoError(t, os.Remove(c.Path([]byte("c"))))
+	require.NoError(t, ioutil.WriteFile(c.tempPath(c.id([]byte("e"))), []byte("partial"), 0777))
+	crash(c)
+
+	_, err = os.Stat(c.internalPath(journalFile))
+	require.NoError(t, err)
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	_, err = os.Stat(c.internalPath(journalFile))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(c.tempPath(c.id([]byte("e"))))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(c.nextPtr(c.id([]byte("c"))))
+	assert.True(t, os.IsNotExist(err))
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("b"), []byte("d"), []byte("a")}, keys)
+
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, s.Entries)
+	require.NoError(t, c.Verify())
+}
+
+func TestJournalRemovedOnUnlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("a"), []byte("1"))
+	require.NoError(t, err)
+	err = c.Delete([]byte("a"))
+	require.NoError(t, err)
+
+	_, err = os.Stat(c.internalPath(journalFile))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestJournalKeptIfRecoveryFails(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+	require.NoError(t, c.Put([]byte("a"), []byte("1")))
+
+	// recovery fails because the state cannot be read
+	state, err := ioutil.ReadFile(c.internalPath("state"))
+	require.NoError(t, err)
+	require.NoError(t, c.lock())
+	require.NoError(t, c.journal(c.id([]byte("a"))))
+	require.NoError(t, ioutil.WriteFile(c.internalPath("state"), []byte("{"), 0777))
+	assert.Error(t, c.recoverJournal())
+	require.NoError(t, ioutil.WriteFile(c.internalPath("state"), state, 0777))
+	require.NoError(t, c.unlock())
+
+	// later operations add to the journal rather than replacing it
+	require.NoError(t, c.Put([]byte("b"), []byte("2")))
+	buf, err := ioutil.ReadFile(c.internalPath(journalFile))
+	require.NoError(t, err)
+	assert.Contains(t, string(buf), "YQ==\n")
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+	_, err = os.Stat(c.internalPath(journalFile))
+	assert.True(t, os.IsNotExist(err))
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("b"), []byte("a")}, keys)
+	require.NoError(t, c.Verify())
+}
@@ -0,0 +1,91 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alexflint/go-filemutex"
+)
+
+// metaDir is the subdirectory that holds all of the cache's internal files: the lock,
+// the state, the list pointers, and per-entry metadata. Keeping them out of the top
+// level means the cache directory contains nothing but values.
+const metaDir = ".lrudir"
+
+// migrateLayout moves the internal files of a cache created before the metadata
+// directory existed, when they lived alongside the values, into the metadata directory.
+// The files are first gathered in a staging directory that is renamed into place at the
+// end, so a migration interrupted by a crash is simply resumed on the next Open.
+// Processes still using the old layout must not access the cache during or after the
+// migration.
+func migrateLayout(path string) error {
+	_, err := os.Stat(filepath.Join(path, metaDir))
+	if err == nil || !os.IsNotExist(err) {
+		return err
+	}
+
+	staging := filepath.Join(path, metaDir+"~migrate")
+	_, serr := os.Stat(staging)
+	_, err = os.Stat(filepath.Join(path, ".lru"))
+	if os.IsNotExist(err) && os.IsNotExist(serr) {
+		// not an old cache either, so let Open report the problem
+		return nil
+	}
+
+	lock, err := filemutex.New(filepath.Join(path, ".lrulock"))
+	if err != nil {
+		return err
+	}
+	err = lock.Lock()
+	if err != nil {
+		return err
+	}
+	defer lock.Unlock()
+
+	// another process may have finished the migration while we waited for the lock
+	_, err = os.Stat(filepath.Join(path, metaDir))
+	if err == nil || !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.MkdirAll(staging, 0777)
+	if err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+		src := filepath.Join(path, name)
+		switch {
+		case strings.HasSuffix(name, "~tmp") || strings.HasPrefix(name, ".lruimport~"):
+			err = os.RemoveAll(src)
+		case name == ".lrublobs":
+			err = os.Rename(src, filepath.Join(staging, blobDir))
+		case strings.Contains(name, "~") && name != metaDir+"~migrate":
+			err = os.Rename(src, filepath.Join(staging, name))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// the state file goes last since its presence marks an old cache
+	err = os.Rename(filepath.Join(path, ".lru"), filepath.Join(staging, "state"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.Rename(staging, filepath.Join(path, metaDir))
+	if err != nil {
+		return err
+	}
+
+	return os.Remove(filepath.Join(path, ".lrulock"))
+}
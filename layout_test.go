@@ -0,0 +1,76 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutOnlyValuesAtTopLevel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.PutWithTags([]byte("foo"), []byte("bar"), "t")
+	require.NoError(t, err)
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.EqualValues(t, []string{filepath.Join(dir, metaDir), c.Path([]byte("foo"))}, matches)
+}
+
+func TestMigrateLayout(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// construct a cache in the old layout, with all internal files at the top level
+	files := map[string]string{
+		".lru":       `{"usage":{"":{"entries":2,"bytes":6}}}`,
+		".lrulock":   "",
+		"~next":      "key2",
+		"~prev":      "key1",
+		"key1":       "one",
+		"key1~next":  "",
+		"key1~prev":  "key2",
+		"key2":       "two",
+		"key2~next":  "key1",
+		"key2~prev":  "",
+		"key2~meta":  `{"tags":["t"]}`,
+		"key2~tmp":   "partial",
+		".lrublobs/": "",
+	}
+	for name, content := range files {
+		if name[len(name)-1] == '/' {
+			err = os.Mkdir(filepath.Join(dir, name), 0777)
+		} else {
+			err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0777)
+		}
+		require.NoError(t, err)
+	}
+
+	c, err := Open(dir, WithStrictOpen())
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("key2"), []byte("key1")}, keys)
+
+	err = c.InvalidateTag("t")
+	require.NoError(t, err)
+
+	val, err := c.Get([]byte("key1"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*"))
+	require.NoError(t, err)
+	assert.EqualValues(t, []string{filepath.Join(dir, metaDir), filepath.Join(dir, "key1")}, matches)
+}
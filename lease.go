@@ -0,0 +1,225 @@
+package lrudir
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Lease keeps an entry in the cache for as long as it is held, across processes. It is
+// held until Release is called, the cache is closed, or the process exits.
+type Lease struct {
+	c    *Cache
+	id   []byte
+	d    time.Duration
+	path string // path to the value
+
+	mu       sync.Mutex // guards the fields below
+	written  []byte     // contents of the lease file as last written by this lease
+	released bool
+
+	done    chan struct{} // closed to stop refreshing
+	stopped chan struct{} // closed once refreshing has stopped
+}
+
+// Lease moves the entry for the given key to the head of the list and keeps it from
+// being evicted, expired by the janitor or ExpireNow, or pruned, until the lease is
+// released. The lease is recorded in a file in the metadata directory that other
+// processes respect. The file says when the lease lapses, and is rewritten well before
+// then by a goroutine, so a lease taken by a process that exits without releasing it
+// lapses within d. Explicit deletion still removes a leased entry.
+func (c *Cache) Lease(key []byte, d time.Duration) (*Lease, error) {
+	if len(key) == 0 {
+		return nil, errors.New("cannot lease the empty key")
+	}
+	if d <= 0 {
+		return nil, errors.New("lease duration must be positive")
+	}
+
+	l := &Lease{
+		c:       c,
+		id:      c.id(key),
+		d:       d,
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	err = c.startLease(l)
+	c.unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.onClose(l.stop) {
+		l.stop()
+		return nil, ErrClosed
+	}
+	go l.refreshLoop()
+	return l, nil
+}
+
+// startLease checks that the entry exists, promotes it, and writes the lease file. It
+// must be called with the lock held.
+func (c *Cache) startLease(l *Lease) error {
+	err := c.checkExpiry(l.id)
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stat(c.path(l.id))
+	if err != nil {
+		return err
+	}
+
+	err = c.access(l.id)
+	if err != nil {
+		return err
+	}
+
+	l.path = c.path(l.id)
+	return l.refresh()
+}
+
+// Path gets the location of the leased value
+func (l *Lease) Path() string {
+	return l.path
+}
+
+// Release ends the lease. Calling it more than once has no effect.
+func (l *Lease) Release() error {
+	l.stop()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.released {
+		return nil
+	}
+	l.released = true
+
+	// another lease on the same entry may have rewritten the file since
+	cur, err := ioutil.ReadFile(l.c.leasePtr(l.id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(cur, l.written) {
+		return nil
+	}
+	err = os.Remove(l.c.leasePtr(l.id))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// stop stops refreshing the lease and waits for the refresh goroutine to finish
+func (l *Lease) stop() {
+	l.mu.Lock()
+	select {
+	case <-l.done:
+	default:
+		close(l.done)
+	}
+	l.mu.Unlock()
+	<-l.stopped
+}
+
+// refreshLoop rewrites the lease file until the lease is stopped
+func (l *Lease) refreshLoop() {
+	defer close(l.stopped)
+	t := time.NewTicker(l.d / 3)
+	defer t.Stop()
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-t.C:
+			l.mu.Lock()
+			err := l.refresh()
+			l.mu.Unlock()
+			if err != nil {
+				l.c.debug("failed to refresh lease", "path", l.path, "err", err)
+			}
+		}
+	}
+}
+
+// refresh writes the time at which the lease lapses to the lease file, unless another
+// lease on the same entry already lasts longer
+func (l *Lease) refresh() error {
+	until := l.c.now().Add(l.d).UnixNano()
+	if cur, ok := l.c.leaseUntil(l.id); ok && cur > until {
+		return nil
+	}
+	buf := []byte(strconv.FormatInt(until, 10))
+	err := l.c.writeLease(l.id, buf)
+	if err != nil {
+		return err
+	}
+	l.written = buf
+	return nil
+}
+
+// writeLease replaces the lease file for the given identifier with the given contents.
+// The file is written under a unique name and then renamed into place, so that other
+// processes never see it part way through being written.
+func (c *Cache) writeLease(id, buf []byte) error {
+	f, err := ioutil.TempFile(c.internalPath(""), "lease~*~tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(buf)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Chmod(0666)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+	return c.renameFile(f.Name(), c.leasePtr(id))
+}
+
+// leasePtr gets the path to the lease file for the given identifier
+func (c *Cache) leasePtr(id []byte) string {
+	return c.internalPath(c.escape(id) + "~lease")
+}
+
+// leaseUntil reads the time in unix nanoseconds at which the lease on the given
+// identifier lapses, reporting false if there is no lease file. Lease files are always
+// written in full, so one that cannot be parsed is damaged and is treated as a lease
+// that has already lapsed.
+func (c *Cache) leaseUntil(id []byte) (int64, bool) {
+	buf, err := ioutil.ReadFile(c.leasePtr(id))
+	if err != nil {
+		return 0, false
+	}
+	until, err := strconv.ParseInt(string(buf), 10, 64)
+	if err != nil {
+		return 0, true
+	}
+	return until, true
+}
+
+// leased returns true if any process holds an unexpired lease on the given identifier
+func (c *Cache) leased(id []byte) bool {
+	until, ok := c.leaseUntil(id)
+	return ok && c.now().UnixNano() < until
+}
@@ -0,0 +1,117 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLease(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := Create(dir, WithMaxEntries(2), WithClock(clk))
+	require.NoError(t, err)
+
+	a := []byte("a")
+	require.NoError(t, c.Put(a, []byte("1")))
+	require.NoError(t, c.Put([]byte("b"), []byte("2")))
+
+	_, err = c.Lease([]byte("missing"), time.Hour)
+	assert.True(t, os.IsNotExist(err))
+
+	l, err := c.Lease(a, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, c.path(c.id(a)), l.Path())
+
+	// the lease is respected by another handle on the same directory
+	other, err := Open(dir, WithMaxEntries(2), WithClock(clk))
+	require.NoError(t, err)
+	for _, k := range []string{"c", "d", "e"} {
+		require.NoError(t, other.Put([]byte(k), []byte(k)))
+	}
+	_, err = c.Get(a)
+	require.NoError(t, err)
+
+	clk.t = clk.t.Add(time.Minute)
+	n, _, err := other.PruneOlderThan(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	_, err = c.Get(a)
+	require.NoError(t, err)
+
+	// the entry can be evicted once the lease is released
+	require.NoError(t, l.Release())
+	require.NoError(t, l.Release())
+	_, err = os.Stat(c.leasePtr(c.id(a)))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, c.Put([]byte("f"), []byte("6")))
+	require.NoError(t, c.Put([]byte("g"), []byte("7")))
+	_, err = c.Get(a)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLeaseLapses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := Create(dir, WithMaxEntries(1), WithClock(clk))
+	require.NoError(t, err)
+
+	a := []byte("a")
+	require.NoError(t, c.Put(a, []byte("1")))
+	l, err := c.Lease(a, time.Hour)
+	require.NoError(t, err)
+	defer l.Release()
+
+	require.NoError(t, c.Put([]byte("b"), []byte("2")))
+	_, err = c.Get(a)
+	require.NoError(t, err)
+
+	// a lease that is not refreshed, as when its holder exits, lapses
+	clk.t = clk.t.Add(2 * time.Hour)
+	require.NoError(t, c.Put([]byte("c"), []byte("3")))
+	_, err = c.Get(a)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLeaseFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxEntries(1))
+	require.NoError(t, err)
+
+	a := []byte("a")
+	require.NoError(t, c.Put(a, []byte("1")))
+	l, err := c.Lease(a, time.Hour)
+	require.NoError(t, err)
+	defer l.Release()
+
+	// the lease file is renamed into place, leaving nothing else behind
+	st, err := os.Stat(c.leasePtr(c.id(a)))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0666), st.Mode().Perm())
+	names, err := readDirNames(c.internalPath(""))
+	require.NoError(t, err)
+	for _, name := range names {
+		assert.NotContains(t, name, "~tmp")
+	}
+
+	// a damaged lease file does not keep the entry forever
+	require.NoError(t, ioutil.WriteFile(c.leasePtr(c.id(a)), []byte("garbage"), 0666))
+	assert.False(t, c.leased(c.id(a)))
+	require.NoError(t, c.Put([]byte("b"), []byte("2")))
+	_, err = c.Get(a)
+	assert.True(t, os.IsNotExist(err))
+}
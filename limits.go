@@ -0,0 +1,191 @@
+package lrudir
+
+import "context"
+
+// limits bounds the number of entries and total size of values in a cache or namespace.
+// Zero means no limit.
+type limits struct {
+	entries int64
+	bytes   int64
+	cost    int64
+	inodes  int64
+}
+
+// usage records the number of entries and total size of values in a namespace
+type usage struct {
+	Entries int64 `json:"entries"`
+	Bytes   int64 `json:"bytes"`
+	Extra   int64 `json:"extraCost,omitempty"` // total cost of entries beyond one each
+	Inodes  int64 `json:"inodes,omitempty"`    // number of files used by the entries, see WithMaxInodes
+}
+
+// cost gets the total cost of the entries, as set by PutWithCost
+func (u usage) cost() int64 {
+	return u.Entries + u.Extra
+}
+
+// exceeds returns true if the usage is over any of the limits
+func (u usage) exceeds(l limits) bool {
+	return (l.entries > 0 && u.Entries > l.entries) ||
+		(l.bytes > 0 && u.Bytes > l.bytes) ||
+		(l.cost > 0 && u.cost() > l.cost) ||
+		(l.inodes > 0 && u.Inodes > l.inodes)
+}
+
+// WithMaxEntries limits the total number of entries in the cache, across all
+// namespaces. The least recently used entries are evicted when a Put exceeds the limit.
+func WithMaxEntries(n int) Option {
+	return func(c *Cache) {
+		c.limits.entries = int64(n)
+	}
+}
+
+// WithMaxBytes limits the total size of values in the cache, across all namespaces. The
+// least recently used entries are evicted when a Put exceeds the limit.
+func WithMaxBytes(n int64) Option {
+	return func(c *Cache) {
+		c.limits.bytes = n
+	}
+}
+
+// WithNamespaceQuota limits the number of entries and total size of values in the given
+// namespace, independently of the limits for the cache as a whole. When a Put to the
+// namespace exceeds its quota, the least recently used entries from that namespace are
+// evicted, leaving other namespaces untouched. Zero means no limit.
+func WithNamespaceQuota(name string, maxEntries int, maxBytes int64) Option {
+	return func(c *Cache) {
+		if c.quotas == nil {
+			c.quotas = make(map[string]limits)
+		}
+		c.quotas[name] = limits{entries: int64(maxEntries), bytes: maxBytes}
+	}
+}
+
+// evict removes least recently used entries until the namespace ns is within its quota
+// and the cache as a whole is within its limits. Leased entries are never evicted.
+func (c *Cache) evict(x *state, ns string) error {
+	return c.evictContext(context.Background(), x, ns)
+}
+
+// evictContext is like evict but traces the evictions, if there are any, as part of ctx.
+// With WithWatermarks it only evicts once usage passes the high watermark, or the limit
+// itself when the janitor is left to enforce the high watermark.
+func (c *Cache) evictContext(ctx context.Context, x *state, ns string) error {
+	high := c.highWater
+	if c.janitor > 0 {
+		high = 1
+	}
+	return c.evictAbove(ctx, x, ns, high)
+}
+
+// evictAbove implements evictContext. If the usage of the namespace ns or the cache as
+// a whole is over the given fraction of its quota or limits, least recently used
+// entries are evicted until it is back within the low watermark.
+func (c *Cache) evictAbove(ctx context.Context, x *state, ns string, high float64) (err error) {
+	before := x.total()
+	q, hasQuota := c.quotas[ns]
+	overQuota := hasQuota && x.usage(ns).exceeds(q.scale(high))
+	overLimits := before.exceeds(c.limits.scale(high))
+	if !overQuota && !overLimits {
+		return nil
+	}
+
+	_, sp := c.startSpan(ctx, "lrudir.evict", nil)
+	defer func() {
+		after := x.total()
+		sp.setEvicted(before.Entries-after.Entries, before.Bytes-after.Bytes)
+		sp.end(err)
+	}()
+
+	if overQuota {
+		low := q.scale(c.lowWater)
+		for x.usage(ns).exceeds(low) {
+			id, err := c.victim(func(id []byte) bool {
+				idns, _ := splitID(id)
+				return idns == ns
+			})
+			if err != nil {
+				return err
+			}
+			if id == nil {
+				break
+			}
+			err = c.discard(x, id, EventEvict, reasonCapacity)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if overLimits {
+		low := c.limits.scale(c.lowWater)
+		for x.total().exceeds(low) {
+			id, err := c.victim(func([]byte) bool { return true })
+			if err != nil {
+				return err
+			}
+			if id == nil {
+				break
+			}
+			err = c.discard(x, id, EventEvict, reasonCapacity)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// victim gets the least recently used entry that satisfies match and is not leased, or
+// nil if there is no such entry
+func (c *Cache) victim(match func(id []byte) bool) ([]byte, error) {
+	var victim []byte
+	err := c.walkBack(func(id []byte) error {
+		if match(id) && !c.leased(id) {
+			victim = id
+			return errStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return victim, nil
+}
+
+// count walks the linked list and computes the usage of each namespace from scratch
+func (c *Cache) count() (map[string]*usage, error) {
+	counts := make(map[string]*usage)
+	err := c.walk(func(id []byte) error {
+		size, err := c.chargedSize(c.path(id))
+		if err != nil {
+			return err
+		}
+
+		ns, _ := splitID(id)
+		u, ok := counts[ns]
+		if !ok {
+			u = new(usage)
+			counts[ns] = u
+		}
+		m, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+
+		inodes, err := c.entryInodes(id, c.path(id))
+		if err != nil {
+			return err
+		}
+
+		u.Entries++
+		u.Bytes += size
+		u.Extra += m.extraCost()
+		u.Inodes += inodes
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return counts, nil
+}
@@ -0,0 +1,98 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxEntries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxEntries(2))
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	err = c.Put(k1, nil)
+	require.NoError(t, err)
+
+	err = c.Put(k2, nil)
+	require.NoError(t, err)
+
+	err = c.Put(k3, nil)
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k3, k2}, keys)
+}
+
+func TestNamespaceQuota(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxBytes(10), WithNamespaceQuota("noisy", 0, 4))
+	require.NoError(t, err)
+
+	quiet, noisy := c.Namespace("quiet"), c.Namespace("noisy")
+
+	err = quiet.Put([]byte("q1"), []byte("abc"))
+	require.NoError(t, err)
+
+	err = noisy.Put([]byte("n1"), []byte("abc"))
+	require.NoError(t, err)
+
+	err = noisy.Put([]byte("n2"), []byte("abc"))
+	require.NoError(t, err)
+
+	// the noisy namespace evicted its own entry rather than the older quiet entry
+	keys, err := quiet.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("q1")}, keys)
+
+	keys, err = noisy.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("n2")}, keys)
+
+	// the global cap still applies across namespaces
+	err = quiet.Put([]byte("q2"), []byte("abcdef"))
+	require.NoError(t, err)
+
+	keys, err = quiet.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("q2")}, keys)
+
+	keys, err = noisy.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("n2")}, keys)
+}
+
+func TestUsageCountedOnOpen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("foo"), []byte("bar"))
+	require.NoError(t, err)
+
+	// simulate a cache created before usage was tracked
+	err = c.setState(&state{})
+	require.NoError(t, err)
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	x, err := c.state()
+	require.NoError(t, err)
+	assert.EqualValues(t, usage{Entries: 1, Bytes: 3, Inodes: 4}, *x.Usage[""])
+}
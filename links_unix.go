@@ -0,0 +1,50 @@
+//go:build !windows
+// +build !windows
+
+package lrudir
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// linkCount gets the number of hardlinks to a file, or zero if it cannot be determined
+func linkCount(st os.FileInfo) int {
+	if sys, ok := st.Sys().(*syscall.Stat_t); ok {
+		return int(sys.Nlink)
+	}
+	return 0
+}
+
+// diskSize gets the space allocated to a file, which is less than its size for sparse
+// files and more for most others
+func diskSize(st os.FileInfo) int64 {
+	if sys, ok := st.Sys().(*syscall.Stat_t); ok {
+		return int64(sys.Blocks) * 512
+	}
+	return st.Size()
+}
+
+// blockSize gets the block size of the filesystem holding the file, as the preferred
+// size for I/O that it reports
+func blockSize(st os.FileInfo) int64 {
+	if sys, ok := st.Sys().(*syscall.Stat_t); ok && sys.Blksize > 0 {
+		return int64(sys.Blksize)
+	}
+	return defaultBlockSize
+}
+
+// fileKey identifies the file underlying st, so that a file with several links can be
+// counted once. It reports false if the file cannot be identified.
+func fileKey(st os.FileInfo) ([2]uint64, bool) {
+	if sys, ok := st.Sys().(*syscall.Stat_t); ok {
+		return [2]uint64{uint64(sys.Dev), uint64(sys.Ino)}, true
+	}
+	return [2]uint64{}, false
+}
+
+// isCrossDevice returns true if the error is from renaming a file to another filesystem
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
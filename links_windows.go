@@ -0,0 +1,39 @@
+package lrudir
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// linkCount gets the number of hardlinks to a file, or zero if it cannot be determined
+func linkCount(st os.FileInfo) int {
+	return 0
+}
+
+// diskSize gets the space allocated to a file, which is taken to be its size since
+// allocation is not reported
+func diskSize(st os.FileInfo) int64 {
+	return st.Size()
+}
+
+// blockSize gets the block size of the filesystem holding the file, which Windows does
+// not report through os.FileInfo, so the usual NTFS cluster size is assumed
+func blockSize(st os.FileInfo) int64 {
+	return defaultBlockSize
+}
+
+// fileKey identifies the file underlying st, so that a file with several links can be
+// counted once. Windows does not report this through os.FileInfo.
+func fileKey(st os.FileInfo) ([2]uint64, bool) {
+	return [2]uint64{}, false
+}
+
+// errorNotSameDevice is ERROR_NOT_SAME_DEVICE, with which Windows rejects moving a file
+// to another volume
+const errorNotSameDevice = syscall.Errno(17)
+
+// isCrossDevice returns true if the error is from renaming a file to another filesystem
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errorNotSameDevice)
+}
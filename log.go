@@ -0,0 +1,49 @@
+package lrudir
+
+import (
+	"log/slog"
+	"time"
+)
+
+const (
+	// contentionThreshold is how long acquiring the lock may take before it is logged
+	contentionThreshold = 10 * time.Millisecond
+
+	// slowThreshold is how long an operation may hold the lock before it is logged
+	slowThreshold = 100 * time.Millisecond
+)
+
+// WithLogger makes the cache log lock contention, repairs, evictions, slow operations,
+// and failed updates to l at debug level. By default nothing is logged.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Cache) {
+		c.logger = l
+	}
+}
+
+// debug logs a message if a logger was configured
+func (c *Cache) debug(msg string, args ...interface{}) {
+	if c.logger != nil {
+		c.logger.Debug(msg, args...)
+	}
+}
+
+// logContention logs if the lock took a long time to acquire, starting at start
+func (c *Cache) logContention(start time.Time) {
+	if c.logger == nil {
+		return
+	}
+	if wait := time.Since(start); wait > contentionThreshold {
+		c.debug("waited for lock", "dir", c.Dir, "wait", wait)
+	}
+}
+
+// logSlow logs if the named operation, starting at start, took a long time
+func (c *Cache) logSlow(op string, start time.Time) {
+	if c.logger == nil {
+		return
+	}
+	if d := time.Since(start); d > slowThreshold {
+		c.debug("slow "+op, "dir", c.Dir, "duration", d)
+	}
+}
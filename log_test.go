@@ -0,0 +1,37 @@
+package lrudir
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	c, err := Create(dir, WithMaxEntries(1), WithLogger(logger))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("k1"), []byte("abc"))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("k2"), nil)
+	require.NoError(t, err)
+
+	err = c.Rename([]byte("missing"), []byte("k3"))
+	require.Error(t, err)
+
+	out := buf.String()
+	assert.Contains(t, out, `msg="evicted entry" namespace="" key="k1" bytes=3 reason=capacity`)
+	assert.Contains(t, out, `msg="update failed"`)
+}
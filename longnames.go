@@ -0,0 +1,63 @@
+package lrudir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// nameMax is the longest filename, in bytes, that common filesystems accept. Every
+// entry has internal files named after its value file plus a suffix such as "~next",
+// so escaped names are kept short enough to leave room for the longest suffix.
+const (
+	nameMax    = 255
+	maxEscaped = nameMax - len("~next")
+	hashedMark = "+"
+	keySidecar = "~key"
+)
+
+// overflow replaces an escaped name that is too long to be stored with a name derived
+// from a hash of the identifier. Such names begin with a "+", which escaping never
+// produces verbatim. No cache could have held an entry under a longer name, so this
+// applies to caches of every escape version.
+func overflow(id []byte, name string) string {
+	if len(name) <= maxEscaped {
+		return name
+	}
+	sum := sha256.Sum256(id)
+	return hashedMark + hex.EncodeToString(sum[:])
+}
+
+// isHashed returns true if the given filename was produced by overflow
+func isHashed(name string) bool {
+	return strings.HasPrefix(name, hashedMark)
+}
+
+// keyPtr gets the path to the file that holds the full identifier for an entry whose
+// filename is hashed
+func (c *Cache) keyPtr(id []byte) string {
+	return c.internalPath(c.escape(id) + keySidecar)
+}
+
+// writeKey records the full identifier for an entry with a hashed filename, so that it
+// can be recovered from the directory listing. Other entries need nothing.
+func (c *Cache) writeKey(id []byte) error {
+	if !isHashed(c.escape(id)) {
+		return nil
+	}
+	return ioutil.WriteFile(c.keyPtr(id), id, 0777)
+}
+
+// removeKey removes the identifier recorded by writeKey, if any
+func (c *Cache) removeKey(id []byte) error {
+	if !isHashed(c.escape(id)) {
+		return nil
+	}
+	err := os.Remove(c.keyPtr(id))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package lrudir
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLongKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	// the second key is short but escapes to far more than NAME_MAX
+	k1 := bytes.Repeat([]byte("a"), 300)
+	k2 := bytes.Repeat([]byte("!"), 100)
+	k3 := append(bytes.Repeat([]byte("a"), 300), 'b')
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	err = c.Put(k3, []byte("three"))
+	require.NoError(t, err)
+
+	val, err := c.Get(k2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("two"), val)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k2, k3, k1}, keys)
+
+	keys, err = c.KeysWithPrefix(k1)
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		require.NoError(t, err)
+		assert.True(t, len(info.Name()) <= nameMax, info.Name())
+		return nil
+	})
+	require.NoError(t, err)
+
+	err = c.Verify()
+	require.NoError(t, err)
+
+	k4 := bytes.Repeat([]byte("b"), 300)
+	err = c.Rename(k1, k4)
+	require.NoError(t, err)
+
+	val, err = c.Get(k4)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	err = c.Delete(k3)
+	require.NoError(t, err)
+
+	err = c.Delete(k2)
+	require.NoError(t, err)
+
+	err = c.Verify()
+	require.NoError(t, err)
+
+	// only the renamed entry still has its key recorded
+	sidecars, err := filepath.Glob(c.internalPath("*" + keySidecar))
+	require.NoError(t, err)
+	assert.Equal(t, []string{c.keyPtr(c.id(k4))}, sidecars)
+}
@@ -1,21 +1,44 @@
 package lrudir
 
 import (
+	"bytes"
+	"context"
 	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/alexflint/go-filemutex"
 )
 
-// These runes can safely appear in filenames on all operaing systems
+// These runes can safely appear in filenames on all operaing systems. The "~" rune must
+// never be added here: escaped keys never contain it, which is what keeps the "~next",
+// "~prev", and other suffixes used for internal files from colliding with user keys.
 const safeChars string = "._-"
 
+// ErrCorrupt is returned when the on-disk structure of the cache is found to be
+// inconsistent. Errors that wrap it can be detected with errors.Is.
+var ErrCorrupt = errors.New("cache is corrupt")
+
+// corrupt constructs an error that wraps ErrCorrupt
+func corrupt(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: "+format, append([]interface{}{ErrCorrupt}, args...)...)
+}
+
+// ErrEmpty is returned when removing the oldest entry from a cache with no entries.
+var ErrEmpty = errors.New("cache is empty")
+
 // These runes can safely appear in filenames on all operaing systems
 var isSafe = make(map[rune]bool)
 
@@ -25,10 +48,232 @@ func init() {
 	}
 }
 
+// Escaping determines how keys are mapped to filenames. It is chosen when a cache is
+// created and persisted in the state file so that the cache is always read back using
+// the same scheme.
+type Escaping int
+
+const (
+	// EscapeDefault keeps letters and numbers as they appear in the key. It is suitable
+	// for case-sensitive filesystems.
+	EscapeDefault Escaping = iota
+	// EscapeCaseInsensitive preserves case while guaranteeing that keys differing only
+	// in case map to distinct filenames, which is needed on case-insensitive filesystems
+	// such as the defaults on macOS and Windows. Uppercase ASCII letters are prefixed
+	// with a "^" and all non-ASCII runes are hex-escaped.
+	EscapeCaseInsensitive
+)
+
+// Option configures a cache.
+type Option func(*Cache)
+
+// WithEscaping selects the scheme used to map keys to filenames. It only has an effect
+// when a cache is created; existing caches always use the scheme they were created with.
+func WithEscaping(e Escaping) Option {
+	return func(c *Cache) {
+		c.escaping = e
+	}
+}
+
 // Cache represents an on-disk LRU cache.
 type Cache struct {
 	Dir  string
-	Lock *filemutex.Mutex
+	Lock *filemutex.Mutex // nil for caches created with WithNFSSafe
+
+	escaping         Escaping
+	escapeVersion    int               // version of the escaping rules, as recorded in the state
+	normalization    Normalization     // normalization form for keys, as recorded in the state
+	maxKeyBytes      int               // longest key that can be written, or zero for no limit
+	maxValueBytes    int64             // largest value that can be written, or zero for no limit
+	truncateValues   bool              // whether values over maxValueBytes are truncated rather than rejected
+	janitor          time.Duration     // interval between sweeps for expired entries, or zero for none
+	janitorCompacts  bool              // whether the janitor also runs Compact
+	sliding          bool              // whether PutWithTTL entries are renewed when read
+	clock            Clock             // source of the current time, or nil for the system clock
+	stagingDir       string            // directory in which new values are staged, or empty for the metadata directory
+	xattrMeta        bool              // whether metadata is kept in extended attributes, see WithXattrMetadata
+	asyncWorkers     int               // number of goroutines performing writes for PutAsync
+	asyncQueue       int               // number of writes each of them buffers
+	batched          bool              // whether reads record accesses rather than promoting
+	batchSize        int               // number of recorded accesses that triggers applying them
+	batchInterval    time.Duration     // interval at which recorded accesses are applied
+	promoteAfter     time.Duration     // minimum time between promotions of an entry by reads
+	hintBytes        int64             // size from which values are read with streaming hints, or zero for none
+	ghostSize        int               // number of evicted keys remembered for GhostHits, or zero for none
+	noPromoteOnPut   bool              // whether overwriting an entry leaves it where it is
+	lockShards       int               // number of lock shards, as recorded in the state
+	nfsSafe          bool              // whether locks are lock files rather than flock, as recorded in the state
+	orderFile        bool              // whether list pointers are kept in one file, as recorded in the state
+	accessLog        string            // file to which a Replica records reads, or empty for none
+	blockAccounting  bool              // whether WithBlockAccounting was given
+	blockSize        int64             // size to which value files are rounded up in the usage, as recorded in the state, or zero for none
+	locker           fileLock          // lock shared with other processes, which is Lock unless nfsSafe
+	mode             openMode          // whether New opens, creates, or either
+	mkdirAll         bool              // whether creating a cache also creates its directory
+	contentAddressed bool              // whether identical values share one file
+	strictOpen       bool              // whether Open runs a full Verify
+	limits           limits            // limits for the cache as a whole
+	quotas           map[string]limits // limits for individual namespaces
+	lowWater         float64           // fraction of the limits that eviction brings usage down to, see WithWatermarks
+	highWater        float64           // fraction of the limits at which eviction starts, see WithWatermarks
+	evictionLog      int64             // size at which the eviction log is rotated, or zero for no log
+	logger           *slog.Logger      // destination for debug logging, or nil for none
+	tracer           Tracer            // source of spans, or nil for no tracing
+	ns               string            // namespace for keys in this cache, or empty for the root namespace
+	shared           *shared           // state shared between a cache and all of its namespaces
+}
+
+// shared holds the state that is common to a cache and all of its namespaces
+type shared struct {
+	mu      sync.RWMutex // guards the file mutex and the fields below
+	rmu     sync.Mutex   // guards readers
+	readers int          // number of goroutines holding the shared file lock
+
+	closed  bool     // whether Close has been called, guarded by mu
+	closers []func() // functions to run on Close, guarded by mu
+
+	journaled   bool   // whether the journal has been created under the current lock, guarded by mu
+	journalLast string // identifier most recently added to the journal, guarded by mu
+	journalKept bool   // whether the journal is kept for Open to recover because recovery failed, guarded by mu
+
+	shards []*shard // locks for the entries whose identifiers hash to each shard, if any
+
+	wmu    sync.Mutex // guards writer
+	writer *writer    // pool performing writes for PutAsync, once started
+
+	amu           sync.Mutex           // guards the fields below
+	accesses      []string             // identifiers read since accesses were last applied, oldest first
+	promoted      map[string]time.Time // when reads last promoted each identifier, for WithPromoteAfter
+	promotedSweep int                  // size of promoted at which old entries are next forgotten
+
+	cmu       sync.Mutex                       // guards the counters below
+	hits      map[string]int64                 // number of successful Gets in this process, by namespace
+	misses    map[string]int64                 // number of Gets in this process that found nothing, by namespace
+	ghostHits map[string]int64                 // number of those misses for keys in the ghost list, by namespace
+	evictions map[string]map[EvictReason]int64 // number of entries evicted by this process, by namespace and reason
+
+	gmu    sync.Mutex // guards ghosts
+	ghosts ghostList  // identifiers recently evicted by this process, for WithGhostList
+
+	handles  map[string]*openHandles // open Entry handles for each identifier, guarded by mu
+	asideSeq int                     // number of value files moved aside by setAside, guarded by mu
+
+	omu        sync.Mutex        // guards the fields below
+	order      map[string][]byte // list pointers read from the order file under the current lock, if any
+	orderDirty bool              // whether order has changed since it was read
+}
+
+// lock acquires exclusive access to the cache, with respect to both other goroutines
+// and other processes
+func (c *Cache) lock() error {
+	start := time.Now()
+	c.shared.mu.Lock()
+	if c.shared.closed {
+		c.shared.mu.Unlock()
+		return ErrClosed
+	}
+	err := c.locker.Lock()
+	if err != nil {
+		c.shared.mu.Unlock()
+		return err
+	}
+	c.logContention(start)
+	return nil
+}
+
+// unlock releases the lock acquired by lock, first saving the order file, if any, and
+// then removing the journal since the operations it covers are complete. The journal is
+// kept if the order file cannot be saved, so that recovery relinks the entries it names.
+func (c *Cache) unlock() error {
+	err := c.saveOrder()
+	if err == nil {
+		err = c.endJournal()
+	}
+	uerr := c.locker.Unlock()
+	if err == nil {
+		err = uerr
+	}
+	c.shared.mu.Unlock()
+	return err
+}
+
+// rlock acquires shared access to the cache, excluding writers but not other readers.
+// The file lock is taken by the first reader and released by the last, since it is
+// held on behalf of the whole process.
+func (c *Cache) rlock() error {
+	start := time.Now()
+	defer c.logContention(start)
+	c.shared.mu.RLock()
+	if c.shared.closed {
+		c.shared.mu.RUnlock()
+		return ErrClosed
+	}
+	c.shared.rmu.Lock()
+	defer c.shared.rmu.Unlock()
+	if c.shared.readers == 0 {
+		err := c.locker.RLock()
+		if err != nil {
+			c.shared.mu.RUnlock()
+			return err
+		}
+	}
+	c.shared.readers++
+	return nil
+}
+
+// runlock releases the lock acquired by rlock
+func (c *Cache) runlock() error {
+	var err error
+	c.shared.rmu.Lock()
+	c.shared.readers--
+	if c.shared.readers == 0 {
+		c.forgetOrder()
+		err = c.locker.RUnlock()
+	}
+	c.shared.rmu.Unlock()
+	c.shared.mu.RUnlock()
+	return err
+}
+
+// view runs f while holding a shared lock
+func (c *Cache) view(f func() error) error {
+	err := c.rlock()
+	if err != nil {
+		return err
+	}
+	defer c.runlock()
+	defer c.logSlow("view", time.Now())
+	return f()
+}
+
+// update runs f while holding the lock, passing it the current state, and then saves
+// the state. The state is not saved if f returns an error.
+func (c *Cache) update(f func(x *state) error) error {
+	err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer c.unlock()
+	defer c.logSlow("update", time.Now())
+
+	// reads that are yet to be applied to the list happened before this write
+	err = c.applyAccesses()
+	if err != nil {
+		return err
+	}
+
+	x, err := c.state()
+	if err != nil {
+		return err
+	}
+
+	err = f(x)
+	if err != nil {
+		c.debug("update failed", "err", err)
+		return err
+	}
+
+	return c.setState(x)
 }
 
 func bytesFromRune(r rune) []byte {
@@ -37,196 +282,952 @@ func bytesFromRune(r rune) []byte {
 	return buf[:n]
 }
 
+// escapeVersion is the version of the escaping rules used for new caches. Each cache
+// records the version it was created with in its state, since changing how existing
+// keys map to filenames would make their entries unreachable.
+//
+//	0: the original rules
+//	1: also avoids names that Windows treats specially (see windowsSafe)
+//	2: also escapes a leading dot, so that no value file is hidden
+const escapeVersion = 2
+
 // escape maps byte slices to unique strings that are valid filenames on all operating
 // systems, while attempting to keep the output as close as possible to the input for
-// human readability
-func escape(key []byte) string {
+// human readability. The version selects between revisions of the rules.
+func escape(key []byte, e Escaping, version int) string {
 	var out string
 	for _, r := range string(key) {
 		switch {
+		case e == EscapeCaseInsensitive && r > unicode.MaxASCII:
+			// case folding of non-ASCII runes differs between filesystems, so
+			// only keep ASCII runes verbatim
+			out += escapeRune(r)
+		case e == EscapeCaseInsensitive && unicode.IsUpper(r):
+			out += "^" + string(r)
 		case unicode.IsLetter(r) || unicode.IsNumber(r) || isSafe[r]:
 			out += string(r)
 		case r == '/':
 			out += "_%_"
 		default:
-			out += "#" + hex.EncodeToString(bytesFromRune(r))
+			out += escapeRune(r)
 		}
 	}
+	if version >= 1 {
+		out = windowsSafe(out)
+	}
+	if version >= 2 && strings.HasPrefix(out, ".") {
+		// hidden files are easily missed, and the cache's own files all begin with a dot
+		out = escapeRune('.') + out[1:]
+	}
 	return out
 }
 
+// escapeRune hex-escapes a single rune
+func escapeRune(r rune) string {
+	return "#" + hex.EncodeToString(bytesFromRune(r))
+}
+
+// windowsReserved holds the basenames that Windows reserves for devices, in any case
+// and with any extension
+var windowsReserved = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true,
+	"COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true,
+	"LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// windowsSafe adjusts an escaped name so that Windows stores it as given: a trailing
+// dot, which Windows would strip, is hex-escaped, as is the first rune of a reserved
+// device name. Spaces and colons never appear in escaped names. Escaping a rune that
+// could have appeared verbatim keeps the mapping unique, since every escaped rune
+// decodes back to the same key.
+func windowsSafe(name string) string {
+	if strings.HasSuffix(name, ".") {
+		name = name[:len(name)-1] + escapeRune('.')
+	}
+
+	stem := name
+	if i := strings.IndexByte(name, '.'); i >= 0 {
+		stem = name[:i]
+	}
+	if windowsReserved[strings.ToUpper(stem)] {
+		r, size := utf8.DecodeRuneInString(name)
+		name = escapeRune(r) + name[size:]
+	}
+	return name
+}
+
+// reservedSafe adjusts an escaped name that would otherwise address the directory
+// itself, its parent, or the files of the cache rather than a value, by hex-escaping its
+// leading dot. This covers the metadata directory and the names used by the layout
+// before it existed, all of which begin with ".lru". It applies to every version of the
+// escaping rules, since such keys never worked, though from version 2 no escaped name
+// begins with a dot anyway.
+func reservedSafe(name string) string {
+	if name == "." || name == ".." || strings.HasPrefix(name, ".lru") {
+		name = escapeRune('.') + name[1:]
+	}
+	return name
+}
+
+// windowsUnsafe undoes the adjustments made by windowsSafe, reservedSafe, and the
+// escaping of leading dots, giving the name as the original rules would have produced
+// it. Letters are never escaped, and dots only by those adjustments, so an escaped
+// letter or dot at the start or an escaped dot at the end can only have come from
+// there.
+func windowsUnsafe(name string) string {
+	dot := escapeRune('.')
+	if strings.HasSuffix(name, dot) {
+		name = name[:len(name)-len(dot)] + "."
+	}
+	if strings.HasPrefix(name, dot) {
+		name = "." + name[len(dot):]
+	}
+	// ASCII letters escape to two varint bytes, so four hex digits
+	if len(name) >= 5 && name[0] == '#' {
+		buf, err := hex.DecodeString(name[1:5])
+		if err == nil {
+			r, n := binary.Varint(buf)
+			if n == 2 && r <= unicode.MaxASCII && unicode.IsLetter(rune(r)) {
+				name = string(rune(r)) + name[5:]
+			}
+		}
+	}
+	return name
+}
+
+// escape maps an identifier to a filename using this cache's escaping scheme, hashing
+// names that would be too long for the filesystem
+func (c *Cache) escape(id []byte) string {
+	return overflow(id, reservedSafe(escape(id, c.escaping, c.escapeVersion)))
+}
+
 // Path gets the path for the entry corresponding to the given key. The path is returned
 // regardless of whether that entry exists.
 func (c *Cache) Path(key []byte) string {
-	return filepath.Join(c.Dir, escape(key))
+	return c.path(c.id(key))
+}
+
+// path gets the path to the value file for the given identifier
+func (c *Cache) path(id []byte) string {
+	return filepath.Join(c.Dir, c.escape(id))
+}
+
+// internalPath gets the path to a file within the metadata directory
+func (c *Cache) internalPath(name string) string {
+	return filepath.Join(c.Dir, metaDir, name)
+}
+
+// tempPath gets the path at which a new value for the given identifier is written
+// before being moved into place
+func (c *Cache) tempPath(id []byte) string {
+	return c.stagingPath(c.escape(id) + "~tmp")
 }
 
 // nextPtr gets the path to the file that contains the key that succeeds the given key.
-func (c *Cache) nextPtr(key []byte) string {
-	return filepath.Join(c.Dir, escape(key)+"~next")
+func (c *Cache) nextPtr(id []byte) string {
+	return c.internalPath(c.escape(id) + "~next")
 }
 
 // nextPtr gets the path to the file that contains the key that succeeds the given key.
-func (c *Cache) prevPtr(key []byte) string {
-	return filepath.Join(c.Dir, escape(key)+"~prev")
+func (c *Cache) prevPtr(id []byte) string {
+	return c.internalPath(c.escape(id) + "~prev")
 }
 
-// Keys gets all keys in the cache, sorted from most to least recently used. This is an
-// O(N) operation.
+// Keys gets all keys in the cache, sorted from most to least recently used. A shared
+// lock is held for the duration, so the result is a consistent snapshot even while other
+// processes are modifying the cache. This is an O(N) operation.
 func (c *Cache) Keys() ([][]byte, error) {
-	var err error
-	var key []byte
 	var keys [][]byte
+	err := c.view(func() error {
+		var err error
+		keys, err = c.keys()
+		return err
+	})
+	return keys, err
+}
+
+// keys gets the keys in this cache's namespace from most to least recently used
+func (c *Cache) keys() ([][]byte, error) {
+	var keys [][]byte
+	err := c.walk(func(id []byte) error {
+		if key, ok := c.key(id); ok {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// errStop is returned by walk callbacks to end the walk early without an error
+var errStop = errors.New("stop walking")
+
+// walk calls f for the identifier of each entry in the linked list, across all
+// namespaces, from most to least recently used. The walk stops at the first error.
+func (c *Cache) walk(f func(id []byte) error) error {
+	return c.walkFrom(c.nextPtr, f)
+}
+
+// walkBack is like walk but goes from least to most recently used
+func (c *Cache) walkBack(f func(id []byte) error) error {
+	return c.walkFrom(c.prevPtr, f)
+}
+
+// walkFrom follows the pointers given by ptr, starting at the sentinel. Corrupted
+// pointers could form a cycle, so every identifier is remembered and the walk fails
+// with ErrCorrupt as soon as one is visited twice.
+func (c *Cache) walkFrom(ptr func(id []byte) string, f func(id []byte) error) error {
+	return c.walkAfter(ptr, nil, f)
+}
+
+// walkAfter is like walkFrom but starts at the entry after the given identifier
+func (c *Cache) walkAfter(ptr func(id []byte) string, id []byte, f func(id []byte) error) error {
+	visited := make(map[string]bool)
 	for {
-		key, err = ioutil.ReadFile(c.nextPtr(key))
+		var err error
+		id, err = c.readPtr(ptr(id))
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if len(id) == 0 {
+			return nil
 		}
-		if len(key) == 0 {
-			break
+		if visited[string(id)] {
+			return corrupt("linked list contains a cycle at %q", id)
+		}
+		visited[string(id)] = true
+
+		err = f(id)
+		if err == errStop {
+			return nil
+		}
+		if err != nil {
+			return err
 		}
-		keys = append(keys, key)
 	}
-	return keys, nil
 }
 
 // Get returns the value for the given key
 func (c *Cache) Get(key []byte) ([]byte, error) {
+	return c.GetContext(context.Background(), key)
+}
+
+// GetContext is like Get but traces the operation as part of ctx
+func (c *Cache) GetContext(ctx context.Context, key []byte) (value []byte, err error) {
 	if len(key) == 0 {
 		return nil, errors.New("cannot get the empty key")
 	}
 
-	buf, err := ioutil.ReadFile(c.Path(key))
+	id := c.id(key)
+	_, sp := c.startSpan(ctx, "lrudir.Get", key)
+	defer func() {
+		c.countGet(id, err)
+		sp.endGet(value, err)
+	}()
+
+	if s := c.shard(id); s != nil && (c.batched || c.recentlyPromoted(id)) {
+		var ok bool
+		value, ok, err = c.getShared(s, id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return value, c.accessShared(id)
+		}
+	}
+
+	err = c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	return c.get(id)
+}
+
+// get reads the value for the given identifier and moves it to the head of the list. It
+// returns ErrNegativeEntry for entries written by PutNegative.
+func (c *Cache) get(id []byte) ([]byte, error) {
+	err := c.checkReadable(id)
 	if err != nil {
 		return nil, err
 	}
 
-	err = c.detach(key)
+	buf, err := c.readValue(c.path(id))
 	if err != nil {
 		return nil, err
 	}
 
-	err = c.attachHead(key)
+	err = c.access(id)
 	if err != nil {
 		return nil, err
 	}
 
+	// only empty values can be negative entries, so only they need the metadata
+	if len(buf) == 0 {
+		m, err := c.readMeta(id)
+		if err != nil {
+			return nil, err
+		}
+		if m.Negative {
+			return nil, ErrNegativeEntry
+		}
+	}
 	return buf, err
 }
 
+// Touch moves the given key to the head of the list without reading its value
+func (c *Cache) Touch(key []byte) error {
+	if len(key) == 0 {
+		return errors.New("cannot touch the empty key")
+	}
+
+	err := c.lock()
+	if err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	id := c.id(key)
+	err = c.checkExpiry(id)
+	if err != nil {
+		return err
+	}
+	return c.promote(id)
+}
+
+// Exists reports whether there is an unexpired entry for the given key. Unlike Get, it
+// neither reads the value nor moves the entry to the head of the list, and it only
+// needs a shared lock, which in a cache with lock shards is on the key's shard alone.
+func (c *Cache) Exists(key []byte) (bool, error) {
+	if len(key) == 0 {
+		return false, errors.New("cannot check for the empty key")
+	}
+
+	id := c.id(key)
+	if s := c.shard(id); s != nil {
+		err := s.rlock()
+		if err != nil {
+			return false, err
+		}
+		defer s.runlock()
+		return c.exists(id)
+	}
+
+	var found bool
+	err := c.view(func() error {
+		var err error
+		found, err = c.exists(id)
+		return err
+	})
+	return found, err
+}
+
+// exists returns true if there is a live value for the given identifier, which is one
+// that has neither expired nor been invalidated by Bump
+func (c *Cache) exists(id []byte) (bool, error) {
+	_, err := os.Stat(c.path(id))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	m, err := c.readMeta(id)
+	if err != nil {
+		return false, err
+	}
+	reason, err := c.invalid(m)
+	return reason == "", err
+}
+
 // Put sets the value for the given key
 func (c *Cache) Put(key, value []byte) error {
-	if len(key) == 0 {
-		return errors.New("cannot put the empty key")
+	return c.PutContext(context.Background(), key, value)
+}
+
+// PutContext is like Put but traces the operation, and any evictions it causes, as
+// part of ctx
+func (c *Cache) PutContext(ctx context.Context, key, value []byte) (err error) {
+	err = c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+
+	ctx, sp := c.startSpan(ctx, "lrudir.Put", key)
+	sp.setBytes(int64(len(value)))
+	defer func() { sp.end(err) }()
+
+	return c.write(ctx, c.id(key), value, nil)
+}
+
+// put writes the value and metadata for the given identifier and moves it to the head
+// of the list
+func (c *Cache) put(x *state, id, value []byte, m *meta) error {
+	n, err := c.fit(int64(len(value)))
+	if err != nil {
+		return err
 	}
+	value = value[:n]
 
-	err := ioutil.WriteFile(c.Path(key), value, 0777)
+	// the journal lets recovery remove the staged value if this process crashes
+	err = c.journal(id)
 	if err != nil {
 		return err
 	}
 
-	err = c.detach(key)
+	m = m.clone()
+	m.Blob = ""
+	if c.contentAddressed {
+		return c.putShared(x, id, value, m)
+	}
+
+	tmp := c.tempPath(id)
+	err = ioutil.WriteFile(tmp, value, 0777)
+	if err != nil {
+		return err
+	}
+	return c.commit(x, id, tmp, m)
+}
+
+// commit moves a fully written value file into place for the given identifier, writes
+// its metadata, and moves it to the head of the list. Values are always replaced by
+// renaming so that other links to the previous value file are left untouched.
+func (c *Cache) commit(x *state, id []byte, tmp string, m *meta) error {
+	err := c.journal(id)
+	if err != nil {
+		return err
+	}
+
+	ns, _ := splitID(id)
+	u := x.usage(ns)
+
+	size, err := c.chargedSize(tmp)
+	if err != nil {
+		return err
+	}
+
+	inodes, err := c.entryInodes(id, tmp)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	prev, err := c.chargedSize(c.path(id))
+	found := err == nil
 	if err != nil && !os.IsNotExist(err) {
-		// ignore file-does-not-exist errors since we are inserting a new entry
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	var prevInodes int64
+	if found {
+		prevInodes, err = c.entryInodes(id, c.path(id))
+		if err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+	}
+
+	old, err := c.readMeta(id)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	gen, err := c.generation()
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+	m = m.clone()
+	m.Gen = gen
+	m.Used = old.Used
+	m.Accesses = old.Accesses
+	if !found || !c.noPromoteOnPut {
+		m.Used = c.now().UnixNano()
+	}
+
+	err = c.setAside(id)
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	err = c.replace(tmp, c.path(id))
+	if err != nil {
+		os.RemoveAll(tmp)
+		return err
+	}
+
+	err = c.releaseBlob(old.Blob)
+	if err != nil {
 		return err
 	}
 
-	return c.attachHead(key)
+	if found {
+		u.Entries--
+		u.Bytes -= prev
+		u.Extra -= old.extraCost()
+		u.Inodes -= prevInodes
+	}
+	u.Entries++
+	u.Bytes += size
+	u.Extra += m.extraCost()
+	u.Inodes += inodes
+
+	err = c.writeMeta(id, m)
+	if err != nil {
+		return err
+	}
+
+	err = c.writeKey(id)
+	if err != nil {
+		return err
+	}
+
+	if !found || !c.noPromoteOnPut {
+		err = c.detach(id)
+		if errors.Is(err, ErrCorrupt) {
+			// the entry is half in the list, so relink everything before moving it
+			err = c.repairList(x)
+			if err == nil {
+				err = c.detach(id)
+			}
+		}
+		if err != nil && !os.IsNotExist(err) {
+			// ignore file-does-not-exist errors since we are inserting a new entry
+			return err
+		}
+
+		err = c.attachHead(id)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.forgetGhost(id)
+	c.record(EventPut, id, 0)
+	return nil
 }
 
 // Delete removes the given key from the cache
 func (c *Cache) Delete(key []byte) error {
+	return c.DeleteContext(context.Background(), key)
+}
+
+// DeleteContext is like Delete but traces the operation as part of ctx
+func (c *Cache) DeleteContext(ctx context.Context, key []byte) (err error) {
 	if len(key) == 0 {
 		return errors.New("cannot delete the empty key")
 	}
 
-	err := c.detach(key)
+	_, sp := c.startSpan(ctx, "lrudir.Delete", key)
+	defer func() { sp.end(err) }()
+
+	return c.update(func(x *state) error {
+		return c.delete(x, c.id(key))
+	})
+}
+
+// delete removes the entry for the given identifier
+func (c *Cache) delete(x *state, id []byte) error {
+	return c.discard(x, id, EventDelete, "")
+}
+
+// discard removes the entry for the given identifier. The op is recorded for watchers,
+// and evictions are logged with the given reason.
+func (c *Cache) discard(x *state, id []byte, op EventOp, reason string) error {
+	err := c.detach(id)
+	if err != nil {
+		return err
+	}
+	return c.remove(x, id, op, reason)
+}
+
+// remove deletes the files for an entry that has already been detached from the list.
+// The op and reason are as for discard.
+func (c *Cache) remove(x *state, id []byte, op EventOp, reason string) error {
+	err := c.journal(id)
+	if err != nil {
+		return err
+	}
+
+	size, err := c.chargedSize(c.path(id))
+	if err != nil {
+		return err
+	}
+
+	inodes, err := c.entryInodes(id, c.path(id))
+	if err != nil {
+		return err
+	}
+
+	m, err := c.readMeta(id)
+	if err != nil {
+		return err
+	}
+
+	err = c.setAside(id)
 	if err != nil {
 		return err
 	}
 
-	err = os.Remove(c.Path(key))
+	err = os.RemoveAll(c.path(id))
 	if err != nil {
 		return err
 	}
 
-	err = os.Remove(c.nextPtr(key))
+	err = c.releaseBlob(m.Blob)
 	if err != nil {
 		return err
 	}
 
-	err = os.Remove(c.prevPtr(key))
+	ns, key := splitID(id)
+	u := x.usage(ns)
+	u.Entries--
+	u.Bytes -= size
+	u.Extra -= m.extraCost()
+	u.Inodes -= inodes
+
+	err = c.removePtr(c.nextPtr(id))
 	if err != nil {
 		return err
 	}
+
+	err = c.removePtr(c.prevPtr(id))
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(c.metaPtr(id))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = c.removeKey(id)
+	if err != nil {
+		return err
+	}
+
+	c.record(op, id, evictReason(reason))
+	if reason == reasonCapacity {
+		c.rememberGhost(id)
+	}
+	if op == EventEvict {
+		c.countEviction(id, evictReason(reason))
+		c.logEviction(id, size, reason)
+		c.debug("evicted entry", "namespace", ns, "key", key, "bytes", size, "reason", reason)
+	}
 	return nil
 }
 
 // Oldest gets the oldest key from the cache
 func (c *Cache) Oldest() ([]byte, error) {
-	return ioutil.ReadFile(c.prevPtr(nil))
+	var id []byte
+	err := c.view(func() error {
+		var err error
+		id, err = c.oldest()
+		return err
+	})
+	if err != nil || id == nil {
+		return nil, err
+	}
+	key, _ := c.key(id)
+	return key, nil
+}
+
+// oldest gets the identifier of the oldest entry in this cache's namespace, or nil if
+// the namespace is empty
+func (c *Cache) oldest() ([]byte, error) {
+	var oldest []byte
+	err := c.walkBack(func(id []byte) error {
+		if _, ok := c.key(id); ok {
+			oldest = id
+			return errStop
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return oldest, nil
+}
+
+// Newest gets the most recently used key from the cache
+func (c *Cache) Newest() ([]byte, error) {
+	keys, err := c.NewestN(1)
+	if err != nil || len(keys) == 0 {
+		return nil, err
+	}
+	return keys[0], nil
+}
+
+// NewestN gets up to n keys from the cache, from most to least recently used. Only
+// the beginning of the list is visited, so this is cheap for small n.
+func (c *Cache) NewestN(n int) ([][]byte, error) {
+	var keys [][]byte
+	err := c.view(func() error {
+		var err error
+		keys, err = c.firstN(c.walk, n)
+		return err
+	})
+	return keys, err
+}
+
+// OldestN gets up to n keys from the cache, from least to most recently used. Only
+// the end of the list is visited, so this is cheap for small n.
+func (c *Cache) OldestN(n int) ([][]byte, error) {
+	var keys [][]byte
+	err := c.view(func() error {
+		var err error
+		keys, err = c.firstN(c.walkBack, n)
+		return err
+	})
+	return keys, err
+}
+
+// firstN collects up to n keys from this cache's namespace in the order given by walk
+func (c *Cache) firstN(walk func(func(id []byte) error) error, n int) ([][]byte, error) {
+	var keys [][]byte
+	if n <= 0 {
+		return keys, nil
+	}
+	err := walk(func(id []byte) error {
+		if key, ok := c.key(id); ok {
+			keys = append(keys, key)
+			if len(keys) == n {
+				return errStop
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
 }
 
 // DeleteOldest removes the oldest key from the cache
 func (c *Cache) DeleteOldest() error {
-	key, err := c.Oldest()
+	return c.update(func(x *state) error {
+		id, err := c.oldest()
+		if err != nil {
+			return err
+		}
+		if id == nil {
+			return ErrEmpty
+		}
+		return c.delete(x, id)
+	})
+}
+
+// Pop removes the oldest entry from the cache and returns its key and value. Reading
+// and removing happen under one acquisition of the lock, so two processes popping
+// concurrently never receive the same entry. It returns ErrEmpty if there are no
+// entries.
+func (c *Cache) Pop() (key, value []byte, err error) {
+	err = c.update(func(x *state) error {
+		id, err := c.oldest()
+		if err != nil {
+			return err
+		}
+		if id == nil {
+			return ErrEmpty
+		}
+
+		value, err = ioutil.ReadFile(c.path(id))
+		if err != nil {
+			return err
+		}
+
+		key, _ = c.key(id)
+		return c.delete(x, id)
+	})
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	return key, value, nil
+}
+
+// Take reads the value for the given key and removes it from the cache under a single
+// acquisition of the lock, so when several processes take the same key exactly one of
+// them gets the value. Taking a negative entry removes it and returns ErrNegativeEntry.
+func (c *Cache) Take(key []byte) ([]byte, error) {
+	if len(key) == 0 {
+		return nil, errors.New("cannot take the empty key")
+	}
+
+	var value []byte
+	var expired, negative bool
+	err := c.update(func(x *state) error {
+		id := c.id(key)
+		m, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+
+		value, err = ioutil.ReadFile(c.path(id))
+		if err != nil {
+			return err
+		}
+
+		reason, err := c.invalid(m)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			expired = true
+			return c.discard(x, id, EventEvict, reason)
+		}
+		err = checkComplete(m)
+		if err != nil {
+			return err
+		}
+		negative = m.Negative
+		return c.delete(x, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if expired {
+		return nil, &os.PathError{Op: "take", Path: c.Path(key), Err: os.ErrNotExist}
 	}
-	return c.Delete(key)
+	if negative {
+		return nil, ErrNegativeEntry
+	}
+	return value, nil
 }
 
-// attachHead attaches the given key at the head of the linked list
-func (c *Cache) attachHead(key []byte) error {
-	headkey, err := ioutil.ReadFile(c.nextPtr(nil))
+// attachHead attaches the given identifier at the head of the linked list
+func (c *Cache) attachHead(id []byte) error {
+	err := c.journal(id)
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(c.nextPtr(nil), key, 0777)
+	headkey, err := c.readPtr(c.nextPtr(nil))
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(headkey, id) {
+		return nil
+	}
+
+	// linking an entry that is still in the list, because detaching it failed, would
+	// leave it in the list twice
+	linked, err := c.linked(id)
+	if err != nil {
+		return err
+	}
+	if linked {
+		c.debug("squashing duplicate entry", "dir", c.Dir, "id", string(id))
+		_, _, err = c.rebuildList(nil)
+		if err != nil {
+			return err
+		}
+		err = c.detach(id)
+		if err != nil {
+			return err
+		}
+		headkey, err = c.readPtr(c.nextPtr(nil))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.writePtr(c.nextPtr(nil), id)
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(c.prevPtr(key), nil, 0777)
+	err = c.writePtr(c.prevPtr(id), nil)
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(c.nextPtr(key), headkey, 0777)
+	err = c.writePtr(c.nextPtr(id), headkey)
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(c.prevPtr(headkey), key, 0777)
+	err = c.writePtr(c.prevPtr(headkey), id)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// detach removes the given key from the linked list but does not delete the file itself
-func (c *Cache) detach(key []byte) error {
-	if len(key) == 0 {
+// linked returns true if either neighbor named by the pointers of the given identifier
+// still points back to it, meaning that it is in the list. Detaching an entry leaves its
+// own pointers in place, but rewrites those of its neighbors.
+func (c *Cache) linked(id []byte) (bool, error) {
+	for _, p := range []struct{ ptr, back func(id []byte) string }{
+		{c.nextPtr, c.prevPtr},
+		{c.prevPtr, c.nextPtr},
+	} {
+		neighbor, err := c.readPtr(p.ptr(id))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		back, err := c.readPtr(p.back(neighbor))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+		if bytes.Equal(back, id) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// detach removes the given identifier from the linked list but does not delete the file
+// itself. It returns an error satisfying os.IsNotExist if the entry has neither pointer,
+// as for an entry not in the list, and one wrapping ErrCorrupt if it has only one.
+func (c *Cache) detach(id []byte) error {
+	if len(id) == 0 {
 		panic(errors.New("cannot detach the empty key"))
 	}
 
-	nextkey, err := ioutil.ReadFile(c.nextPtr(key))
+	err := c.journal(id)
 	if err != nil {
 		return err
 	}
 
-	prevkey, err := ioutil.ReadFile(c.prevPtr(key))
-	if err != nil {
-		return err
+	nextkey, nerr := c.readPtr(c.nextPtr(id))
+	if nerr != nil && !os.IsNotExist(nerr) {
+		return nerr
+	}
+	prevkey, perr := c.readPtr(c.prevPtr(id))
+	if perr != nil && !os.IsNotExist(perr) {
+		return perr
+	}
+	switch {
+	case nerr != nil && perr != nil:
+		return nerr
+	case nerr != nil:
+		return corrupt("entry %q has a prev pointer but no next pointer", id)
+	case perr != nil:
+		return corrupt("entry %q has a next pointer but no prev pointer", id)
 	}
 
-	err = ioutil.WriteFile(c.prevPtr(nextkey), prevkey, 0777)
+	err = c.writePtr(c.prevPtr(nextkey), prevkey)
 	if err != nil {
 		return err
 	}
 
-	err = ioutil.WriteFile(c.nextPtr(prevkey), nextkey, 0777)
+	err = c.writePtr(c.nextPtr(prevkey), nextkey)
 	if err != nil {
 		return err
 	}
@@ -234,86 +1235,314 @@ func (c *Cache) detach(key []byte) error {
 	return nil
 }
 
-// Create initializes an LRU cache in the given directory. The directory
-// must already exist.
-func Create(path string) (*Cache, error) {
-	// Create the lock
-	lock, err := filemutex.New(filepath.Join(path, ".lrulock"))
-	if err != nil {
-		os.RemoveAll(path)
-		return nil, err
+// Create initializes an LRU cache in the given directory. The directory must already
+// exist unless WithMkdirAll is given. It is equivalent to New with WithMustNotExist.
+func Create(path string, opts ...Option) (*Cache, error) {
+	return New(path, append(opts, WithMustNotExist())...)
+}
+
+// create implements New for a directory that does not yet hold a cache. The metadata
+// directory is assembled under a temporary name and renamed into place, so other
+// processes never see a partially initialized cache, and if another process creates
+// the cache first this fails with an error satisfying os.IsExist. Nothing outside the
+// metadata directory is touched, so on failure any other files in the directory are
+// left alone.
+func create(path string, opts []Option) (c *Cache, err error) {
+	c = &Cache{
+		Dir:    path,
+		shared: new(shared),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
 
-	// Construct the cache
-	c := &Cache{
-		Dir:  path,
-		Lock: lock,
+	// Create the directory itself if requested
+	if c.mkdirAll {
+		err = os.MkdirAll(path, 0777)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	internal := filepath.Join(path, metaDir)
+	_, err = os.Stat(internal)
+	if err == nil {
+		return nil, &os.PathError{Op: "create", Path: internal, Err: os.ErrExist}
+	}
+
+	if c.stagingDir != "" {
+		err = os.MkdirAll(c.stagingDir, 0777)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	// Set the head to nil
-	err = ioutil.WriteFile(c.nextPtr(nil), nil, 0777)
+	// Assemble the metadata directory under a temporary name
+	staging, err := ioutil.TempDir(path, metaDir+"~create")
 	if err != nil {
 		return nil, err
 	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(staging)
+		}
+	}()
 
-	// Set the tail to nil
-	err = ioutil.WriteFile(c.prevPtr(nil), nil, 0777)
+	// Create the lock. Renaming the directory does not affect the open lock file, and
+	// the file for an NFS-safe lock is not created until the lock is taken, by which
+	// time the directory is in place.
+	lockDir := staging
+	if c.nfsSafe {
+		lockDir = internal
+	}
+	c.locker, err = c.newFileLock(filepath.Join(lockDir, "lock"))
 	if err != nil {
 		return nil, err
 	}
+	c.Lock, _ = c.locker.(*filemutex.Mutex)
+	defer func() {
+		if err != nil {
+			c.locker.Close()
+		}
+	}()
+
+	// Set the head and tail to nil
+	if c.orderFile {
+		sentinels := map[string][]byte{
+			filepath.Base(c.nextPtr(nil)): nil,
+			filepath.Base(c.prevPtr(nil)): nil,
+		}
+		err = c.writeOrder(filepath.Join(staging, orderFile), sentinels)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		for _, ptr := range []string{c.nextPtr(nil), c.prevPtr(nil)} {
+			err = ioutil.WriteFile(filepath.Join(staging, filepath.Base(ptr)), nil, 0777)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Fall back to metadata files where extended attributes are not supported
+	if c.xattrMeta && !xattrSupported(staging) {
+		c.xattrMeta = false
+	}
+
+	// Find the block size for WithBlockAccounting if it was not given
+	if c.blockAccounting && c.blockSize <= 0 {
+		st, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		c.blockSize = blockSize(st)
+	}
 
 	// Set the initial state
-	var x state
-	err = c.setState(&x)
+	c.escapeVersion = escapeVersion
+	x := state{
+		Escaping:      c.escaping,
+		EscapeVersion: escapeVersion,
+		Normalization: c.normalization,
+		LockShards:    c.lockShards,
+		NFSSafe:       c.nfsSafe,
+		OrderFile:     c.orderFile,
+		BlockSize:     c.blockSize,
+		XattrMeta:     c.xattrMeta,
+		Usage:         make(map[string]*usage),
+	}
+	err = c.writeState(filepath.Join(staging, "state"), &x)
 	if err != nil {
-		os.RemoveAll(path)
+		return nil, err
+	}
+
+	// Move the metadata directory into place, unless another process got there first
+	err = os.Rename(staging, internal)
+	if err != nil {
+		if _, serr := os.Stat(internal); serr == nil {
+			return nil, &os.PathError{Op: "create", Path: internal, Err: os.ErrExist}
+		}
 		return nil, err
 	}
 
 	return c, nil
 }
 
+// CreateExclusive is like Create but refuses to initialize a directory that already
+// contains anything, so that a cache is never mixed in with unrelated files
+func CreateExclusive(path string, opts ...Option) (*Cache, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	names, err := f.Readdirnames(1)
+	f.Close()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(names) > 0 {
+		return nil, errors.New("cannot create a cache in a non-empty directory: " + path)
+	}
+	return Create(path, opts...)
+}
+
 // Open opens the given directory as an LRU cache. It returns an error if the directory
-// does not exist, or if it is not an LRU cache.
-func Open(path string) (*Cache, error) {
-	// Open the lock
-	lock, err := filemutex.New(filepath.Join(path, ".lrulock"))
+// does not exist, or if it is not an LRU cache. If a process crashed part way through
+// an operation, the cache is repaired before it is returned. It is equivalent to New
+// with WithMustExist.
+func Open(path string, opts ...Option) (*Cache, error) {
+	return New(path, append(opts, WithMustExist())...)
+}
+
+// open implements New for a directory that already holds a cache
+func open(path string, opts []Option) (*Cache, error) {
+	// Bring caches created with the old layout up to date
+	err := migrateLayout(path)
 	if err != nil {
 		return nil, err
 	}
 
 	// Construct the cache
 	c := &Cache{
-		Dir:  path,
-		Lock: lock,
+		Dir:    path,
+		shared: new(shared),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.stagingDir != "" {
+		err = os.MkdirAll(c.stagingDir, 0777)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Check that we can read the state
-	_, err = c.state()
+	x, err := c.state()
+	if err != nil {
+		return nil, err
+	}
+	c.escaping = x.Escaping
+	c.escapeVersion = x.EscapeVersion
+	c.normalization = x.Normalization
+	c.lockShards = x.LockShards
+	c.nfsSafe = x.NFSSafe
+	c.orderFile = x.OrderFile
+	c.blockSize = x.BlockSize
+	c.xattrMeta = x.XattrMeta
+
+	// Open the lock, whose kind is also recorded in the state
+	c.locker, err = c.newFileLock(c.internalPath("lock"))
+	if err != nil {
+		return nil, err
+	}
+	c.Lock, _ = c.locker.(*filemutex.Mutex)
+
+	err = c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	// Repair the cache if a process crashed part way through an operation
+	err = c.recoverJournal()
+	if err != nil {
+		return nil, err
+	}
+
+	// Check that the ends of the list are intact
+	err = c.checkSentinels()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read the state again now that no other process can be changing it
+	x, err = c.state()
 	if err != nil {
 		return nil, err
 	}
 
+	// Caches created before usage was tracked need to be counted once
+	dirty := x.Closed
+	if x.Usage == nil || (x.total().Entries > 0 && x.total().Inodes == 0) {
+		c.debug("recounting usage", "dir", path)
+		x.Usage, err = c.count()
+		if err != nil {
+			return nil, err
+		}
+		dirty = true
+	}
+
+	// The cache is open again until this handle is closed
+	x.Closed = false
+	if dirty {
+		err = c.setState(x)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Check the whole structure if requested
+	if c.strictOpen {
+		err = c.verify()
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return c, nil
 }
 
 // OpenOrCreate opens the given directory as an LRU cache, or creates an LRU cache at that
-// location if it does not exist. It returns an error if the directory exists but is not
-// an LRU cache.
-func OpenOrCreate(path string) (*Cache, error) {
-	_, err := os.Stat(path)
-	if err != nil && os.IsNotExist(err) {
-		return Create(path)
+// location if there is not one already. It is equivalent to New.
+func OpenOrCreate(path string, opts ...Option) (*Cache, error) {
+	return New(path, opts...)
+}
+
+// state represents information stored in the state file
+type state struct {
+	Escaping      Escaping          `json:"escaping,omitempty"`
+	EscapeVersion int               `json:"escapeVersion,omitempty"` // see escapeVersion
+	Normalization Normalization     `json:"normalization,omitempty"` // see WithKeyNormalization
+	LockShards    int               `json:"lockShards,omitempty"`    // see WithLockShards
+	NFSSafe       bool              `json:"nfsSafe,omitempty"`       // see WithNFSSafe
+	OrderFile     bool              `json:"orderFile,omitempty"`     // see WithOrderFile
+	BlockSize     int64             `json:"blockSize,omitempty"`     // see WithBlockAccounting
+	XattrMeta     bool              `json:"xattrMeta,omitempty"`     // see WithXattrMetadata
+	Usage         map[string]*usage `json:"usage"`                   // keyed by namespace
+	Closed        bool              `json:"closed,omitempty"`        // whether the last handle to open the cache was closed cleanly
+}
+
+// usage gets the usage record for the given namespace, creating it if necessary
+func (x *state) usage(ns string) *usage {
+	u, ok := x.Usage[ns]
+	if !ok {
+		if x.Usage == nil {
+			x.Usage = make(map[string]*usage)
+		}
+		u = new(usage)
+		x.Usage[ns] = u
 	}
-	return Open(path)
+	return u
 }
 
-// state represents information stored in the .lru file
-type state struct{}
+// total gets the usage summed over all namespaces
+func (x *state) total() usage {
+	var t usage
+	for _, u := range x.Usage {
+		t.Entries += u.Entries
+		t.Bytes += u.Bytes
+		t.Extra += u.Extra
+		t.Inodes += u.Inodes
+	}
+	return t
+}
 
 // load state for an LRU directory
 func (c *Cache) state() (*state, error) {
-	r, err := os.Open(filepath.Join(c.Dir, ".lru"))
+	r, err := os.Open(c.internalPath("state"))
 	if err != nil {
 		return nil, err
 	}
@@ -328,18 +1557,30 @@ func (c *Cache) state() (*state, error) {
 	return &x, nil
 }
 
-// set state for an LRU directory
+// set state for an LRU directory. The state is written to a temporary file and then
+// renamed into place so that readers never observe a partially written state.
 func (c *Cache) setState(s *state) error {
-	w, err := os.Create(filepath.Join(c.Dir, ".lru"))
+	return c.writeState(c.internalPath("state"), s)
+}
+
+// writeState writes the state to the given path via a temporary file
+func (c *Cache) writeState(path string, s *state) error {
+	w, err := os.Create(path + "~tmp")
 	if err != nil {
 		return err
 	}
-	defer w.Close()
 
 	enc := json.NewEncoder(w)
 	err = enc.Encode(s)
 	if err != nil {
+		w.Close()
 		return err
 	}
-	return nil
+
+	err = w.Close()
+	if err != nil {
+		return err
+	}
+
+	return c.renameFile(path+"~tmp", path)
 }
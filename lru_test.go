@@ -1,10 +1,15 @@
 package lrudir
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -131,3 +136,419 @@ func TestReorder(t *testing.T) {
 	require.NoError(t, err)
 	assert.EqualValues(t, [][]byte{k3, k1, k2}, keys)
 }
+
+func TestEscapeCaseInsensitive(t *testing.T) {
+	keys := []string{"foo", "Foo", "FOO", "fOo", "ÿ", "Ÿ"}
+	seen := make(map[string]string)
+	for _, key := range keys {
+		name := strings.ToLower(escape([]byte(key), EscapeCaseInsensitive, escapeVersion))
+		if other, found := seen[name]; found {
+			t.Errorf("%q and %q both escape to %q", key, other, name)
+		}
+		seen[name] = key
+	}
+	assert.Equal(t, "^Foo", escape([]byte("Foo"), EscapeCaseInsensitive, escapeVersion))
+}
+
+func TestEscapingPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithEscaping(EscapeCaseInsensitive))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("Foo"), []byte("upper"))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("foo"), []byte("lower"))
+	require.NoError(t, err)
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	val, err := c.Get([]byte("Foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("upper"), val)
+
+	val, err = c.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("lower"), val)
+}
+
+func TestPop(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	key, val, err := c.Pop()
+	require.NoError(t, err)
+	assert.EqualValues(t, k1, key)
+	assert.Equal(t, []byte("one"), val)
+
+	key, val, err = c.Pop()
+	require.NoError(t, err)
+	assert.EqualValues(t, k2, key)
+	assert.Equal(t, []byte("two"), val)
+
+	_, _, err = c.Pop()
+	assert.Equal(t, ErrEmpty, err)
+
+	err = c.DeleteOldest()
+	assert.Equal(t, ErrEmpty, err)
+}
+
+func TestExists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	found, err := c.Exists(k1)
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	found, err = c.Exists(k3)
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	// checking does not change the order
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k2, k1}, keys)
+}
+
+func TestTake(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	err = c.PutWithTTL(k3, []byte("three"), time.Nanosecond)
+	require.NoError(t, err)
+
+	val, err := c.Take(k1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	_, err = c.Take(k1)
+	assert.True(t, os.IsNotExist(err))
+
+	time.Sleep(time.Millisecond)
+
+	// expired entries are removed but not returned
+	_, err = c.Take(k3)
+	assert.True(t, os.IsNotExist(err))
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k2}, keys)
+
+	err = c.Verify()
+	require.NoError(t, err)
+}
+
+func TestNewestOldestN(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	err = c.Put(k1, nil)
+	require.NoError(t, err)
+
+	err = c.Put(k2, nil)
+	require.NoError(t, err)
+
+	err = c.Put(k3, nil)
+	require.NoError(t, err)
+
+	newest, err := c.Newest()
+	require.NoError(t, err)
+	assert.EqualValues(t, k3, newest)
+
+	keys, err := c.NewestN(2)
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k3, k2}, keys)
+
+	keys, err = c.OldestN(2)
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1, k2}, keys)
+
+	keys, err = c.OldestN(10)
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1, k2, k3}, keys)
+}
+
+func TestKeysCycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.Put(k1, nil)
+	require.NoError(t, err)
+
+	err = c.Put(k2, nil)
+	require.NoError(t, err)
+
+	// corrupt the list so that key1 points back to key2
+	err = ioutil.WriteFile(c.nextPtr(k1), k2, 0777)
+	require.NoError(t, err)
+
+	_, err = c.Keys()
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	_, err = c.NewestN(5)
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	err = c.DeleteMatching(func(key []byte) bool { return false })
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestPointerSuffixCollision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	for _, e := range []Escaping{EscapeDefault, EscapeCaseInsensitive} {
+		assert.NotContains(t, escape([]byte("foo~next"), e, escapeVersion), "~")
+	}
+
+	k1, k2, k3 := []byte("foo"), []byte("foo~next"), []byte("foo~prev")
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	err = c.Put(k3, []byte("three"))
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k3, k2, k1}, keys)
+
+	err = c.Verify()
+	require.NoError(t, err)
+}
+
+func TestStringKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.PutString("foo", []byte("bar"))
+	require.NoError(t, err)
+
+	val, err := c.GetString("foo")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bar"), val)
+
+	err = c.DeleteString("foo")
+	require.NoError(t, err)
+
+	_, err = c.GetString("foo")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWindowsSafeEscaping(t *testing.T) {
+	for _, key := range []string{"CON", "con", "Aux.txt", "lpt9.tar.gz", "foo.", ".", ".."} {
+		name := escape([]byte(key), EscapeDefault, escapeVersion)
+		assert.NotEqual(t, key, name)
+		assert.False(t, strings.HasSuffix(name, "."), name)
+		assert.False(t, windowsReserved[strings.ToUpper(strings.SplitN(name, ".", 2)[0])], name)
+
+		// the original rules are kept for caches created with them
+		assert.Equal(t, key, escape([]byte(key), EscapeDefault, 0))
+	}
+
+	// names that merely contain a reserved name are left alone
+	assert.Equal(t, "CONSOLE", escape([]byte("CONSOLE"), EscapeDefault, escapeVersion))
+	assert.Equal(t, "COM10", escape([]byte("COM10"), EscapeDefault, escapeVersion))
+	assert.Equal(t, "a.b", escape([]byte("a.b"), EscapeDefault, escapeVersion))
+	assert.Equal(t, "#74", escape([]byte(":"), EscapeDefault, escapeVersion))
+}
+
+func TestNoHiddenValueFiles(t *testing.T) {
+	keys := []string{".", "..", "...", ".hidden", ".lrudir", "..x", "a.", ".CON", "./x", ".~next"}
+	for i := 0; i < 256; i++ {
+		keys = append(keys, "."+string(rune(i)), string(rune(i))+".")
+	}
+	for _, key := range keys {
+		for _, e := range []Escaping{EscapeDefault, EscapeCaseInsensitive} {
+			name := escape([]byte(key), e, escapeVersion)
+			assert.False(t, strings.HasPrefix(name, "."), "%q escapes to %q", key, name)
+			assert.True(t, isValueFile(name), "%q escapes to %q", key, name)
+		}
+	}
+
+	// prefixes still match keys whose leading dot was escaped
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+	for _, key := range []string{".a", ".ab", "..", "b"} {
+		require.NoError(t, c.Put([]byte(key), nil))
+	}
+	found, err := c.KeysWithPrefix([]byte(".a"))
+	require.NoError(t, err)
+	assert.Len(t, found, 2)
+	found, err = c.KeysWithPrefix([]byte("."))
+	require.NoError(t, err)
+	assert.Len(t, found, 3)
+}
+
+func TestEscapeVersionPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	// simulate a cache created with the original escaping rules
+	x, err := c.state()
+	require.NoError(t, err)
+	x.EscapeVersion = 0
+	err = c.setState(x)
+	require.NoError(t, err)
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("CON"), []byte("legacy"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "CON"), c.Path([]byte("CON")))
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	val, err := c.Get([]byte("CON"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("legacy"), val)
+}
+
+func TestConcurrentPutSameKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c1, err := Create(dir)
+	require.NoError(t, err)
+	c2, err := Open(dir)
+	require.NoError(t, err)
+
+	// each writer puts values made of a single repeated byte, so that a value mixing
+	// the data of several writes is easy to spot
+	key := []byte("shared")
+	var wg sync.WaitGroup
+	for i, c := range []*Cache{c1, c2, c1, c2} {
+		wg.Add(1)
+		go func(i int, c *Cache) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				value := bytes.Repeat([]byte{byte('a' + i)}, 1<<16)
+				assert.NoError(t, c.Put(key, value))
+				assert.NoError(t, c.Put([]byte{byte('a' + i)}, value[:1]))
+			}
+		}(i, c)
+	}
+	wg.Wait()
+
+	val, err := c1.Get(key)
+	require.NoError(t, err)
+	assert.Len(t, val, 1<<16)
+	assert.Equal(t, bytes.Repeat(val[:1], len(val)), val)
+
+	keys, err := c2.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 5)
+	require.NoError(t, c1.Verify())
+}
+
+func TestReservedNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	keys := [][]byte{[]byte("."), []byte(".."), []byte(metaDir), []byte(".lru"), []byte(".lrulock")}
+	for _, key := range keys {
+		name := c.escape(key)
+		assert.True(t, isValueFile(name), name)
+		assert.NotEqual(t, string(key), name)
+		assert.NotContains(t, []string{".", "..", metaDir}, name)
+
+		require.NoError(t, c.Put(key, key))
+	}
+
+	for _, key := range keys {
+		val, err := c.Get(key)
+		require.NoError(t, err)
+		assert.Equal(t, key, val)
+	}
+
+	found, err := c.KeysWithPrefix([]byte("."))
+	require.NoError(t, err)
+	assert.Len(t, found, len(keys))
+
+	// deleting the keys leaves the cache and its directory intact
+	for _, key := range keys {
+		require.NoError(t, c.Delete(key))
+	}
+	require.NoError(t, c.Verify())
+	require.NoError(t, c.Put([]byte("a"), []byte("1")))
+	_, err = os.Stat(c.internalPath("state"))
+	require.NoError(t, err)
+}
@@ -0,0 +1,46 @@
+// Package lrugroupcache lets an lrudir cache act as the persistent layer behind
+// groupcache. It lives in its own package so that lrudir itself does not depend on
+// groupcache.
+package lrugroupcache
+
+import (
+	"context"
+	"os"
+
+	"github.com/alexflint/go-lrudir"
+	"github.com/golang/groupcache"
+)
+
+// Getter serves groupcache loads from a directory cache, calling the inner getter only
+// for keys that are not on disk and storing whatever it produces
+type Getter struct {
+	Cache *lrudir.Cache
+	Inner groupcache.Getter
+}
+
+// NewGetter creates a getter that consults c before inner
+func NewGetter(c *lrudir.Cache, inner groupcache.Getter) *Getter {
+	return &Getter{Cache: c, Inner: inner}
+}
+
+// Get implements groupcache.Getter
+func (g *Getter) Get(ctx context.Context, key string, dest groupcache.Sink) error {
+	value, err := g.Cache.GetString(key)
+	if err == nil {
+		return dest.SetBytes(value)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	err = g.Inner.Get(ctx, key, groupcache.AllocatingByteSliceSink(&value))
+	if err != nil {
+		return err
+	}
+
+	err = g.Cache.PutString(key, value)
+	if err != nil {
+		return err
+	}
+	return dest.SetBytes(value)
+}
@@ -0,0 +1,40 @@
+package lrugroupcache
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/alexflint/go-lrudir"
+	"github.com/golang/groupcache"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := lrudir.Create(dir)
+	require.NoError(t, err)
+
+	var loads int
+	g := NewGetter(c, groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		loads++
+		return dest.SetString("value of " + key)
+	}))
+
+	for i := 0; i < 2; i++ {
+		var buf []byte
+		err = g.Get(context.Background(), "foo", groupcache.AllocatingByteSliceSink(&buf))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("value of foo"), buf)
+	}
+	assert.Equal(t, 1, loads)
+
+	val, err := c.GetString("foo")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value of foo"), val)
+}
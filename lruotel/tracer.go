@@ -0,0 +1,75 @@
+// Package lruotel traces lrudir caches with OpenTelemetry. It lives in its own package
+// so that lrudir itself does not depend on OpenTelemetry.
+package lruotel
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alexflint/go-lrudir"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies lrudir as the source of spans
+const tracerName = "github.com/alexflint/go-lrudir"
+
+// WithTracerProvider makes the cache emit a span through tp for each Get, Put, and
+// Delete, and for each round of evictions, as described for lrudir.WithTracer
+func WithTracerProvider(tp trace.TracerProvider) lrudir.Option {
+	return lrudir.WithTracer(NewTracer(tp))
+}
+
+// NewTracer creates a tracer for lrudir.WithTracer that starts its spans from tp
+func NewTracer(tp trace.TracerProvider) lrudir.Tracer {
+	return tracer{tp.Tracer(tracerName)}
+}
+
+// tracer adapts an OpenTelemetry tracer to lrudir.Tracer
+type tracer struct {
+	t trace.Tracer
+}
+
+// Start implements lrudir.Tracer
+func (t tracer) Start(ctx context.Context, name string, attrs ...lrudir.Attribute) (context.Context, lrudir.Span) {
+	ctx, s := t.t.Start(ctx, name, trace.WithAttributes(convert(attrs)...))
+	return ctx, span{s}
+}
+
+// span adapts an OpenTelemetry span to lrudir.Span
+type span struct {
+	s trace.Span
+}
+
+// SetAttributes implements lrudir.Span
+func (s span) SetAttributes(attrs ...lrudir.Attribute) {
+	s.s.SetAttributes(convert(attrs)...)
+}
+
+// End implements lrudir.Span
+func (s span) End(err error) {
+	if err != nil {
+		s.s.RecordError(err)
+		s.s.SetStatus(codes.Error, err.Error())
+	}
+	s.s.End()
+}
+
+// convert translates attributes into their OpenTelemetry form
+func convert(attrs []lrudir.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		switch v := a.Value.(type) {
+		case string:
+			kvs = append(kvs, attribute.String(a.Key, v))
+		case int64:
+			kvs = append(kvs, attribute.Int64(a.Key, v))
+		case bool:
+			kvs = append(kvs, attribute.Bool(a.Key, v))
+		default:
+			kvs = append(kvs, attribute.String(a.Key, fmt.Sprint(v)))
+		}
+	}
+	return kvs
+}
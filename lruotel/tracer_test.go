@@ -0,0 +1,97 @@
+package lruotel
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/alexflint/go-lrudir"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// recordedSpan keeps the name and attributes of a span
+type recordedSpan struct {
+	noop.Span
+	name  string
+	attrs map[string]interface{}
+	rec   *recordingProvider
+}
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[string(a.Key)] = a.Value.AsInterface()
+	}
+}
+
+func (s *recordedSpan) End(...trace.SpanEndOption) {
+	s.rec.mu.Lock()
+	defer s.rec.mu.Unlock()
+	s.rec.spans = append(s.rec.spans, s)
+}
+
+// recordingProvider collects ended spans
+type recordingProvider struct {
+	noop.TracerProvider
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (p *recordingProvider) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{p: p}
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	p *recordingProvider
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &recordedSpan{name: name, attrs: make(map[string]interface{}), rec: t.p}
+	cfg := trace.NewSpanStartConfig(opts...)
+	s.SetAttributes(cfg.Attributes()...)
+	return ctx, s
+}
+
+func TestTracing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tp := new(recordingProvider)
+	c, err := lrudir.Create(dir, lrudir.WithMaxEntries(1), WithTracerProvider(tp))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("k1"), []byte("abc"))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("k2"), []byte("de"))
+	require.NoError(t, err)
+
+	_, err = c.Get([]byte("k2"))
+	require.NoError(t, err)
+
+	_, err = c.Get([]byte("k1"))
+	require.Error(t, err)
+
+	err = c.Delete([]byte("k2"))
+	require.NoError(t, err)
+
+	var names []string
+	for _, s := range tp.spans {
+		names = append(names, s.name)
+	}
+	assert.Equal(t, []string{"lrudir.Put", "lrudir.evict", "lrudir.Put", "lrudir.Get", "lrudir.Get", "lrudir.Delete"}, names)
+
+	assert.EqualValues(t, 3, tp.spans[0].attrs["lrudir.bytes"])
+	assert.NotEqual(t, "k1", tp.spans[0].attrs["lrudir.key_hash"])
+	assert.EqualValues(t, 1, tp.spans[1].attrs["lrudir.evicted_entries"])
+	assert.EqualValues(t, 3, tp.spans[1].attrs["lrudir.evicted_bytes"])
+	assert.Equal(t, true, tp.spans[3].attrs["lrudir.hit"])
+	assert.Equal(t, false, tp.spans[4].attrs["lrudir.hit"])
+}
@@ -0,0 +1,137 @@
+package lrudir
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ExportManifest writes the recency order and metadata of every entry in this cache's
+// namespace and any namespaces nested within it to w as JSON, without the values. The
+// manifest names the value file for each entry, so copying the value files to another
+// directory, for example with rsync, and passing the manifest to ImportManifest there
+// reproduces the cache. It holds a shared lock while reading.
+func (c *Cache) ExportManifest(w io.Writer) error {
+	var m manifest
+	err := c.view(func() error {
+		return c.walkBack(func(id []byte) error {
+			if !c.contains(id) {
+				return nil
+			}
+
+			md, err := c.readMeta(id)
+			if err != nil {
+				return err
+			}
+			md.Blob = ""
+
+			ns, key := c.relative(id)
+			m.Entries = append(m.Entries, manifestEntry{Namespace: ns, Key: key, Meta: md, File: c.escape(id)})
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return json.NewEncoder(w).Encode(&m)
+}
+
+// ImportManifest reads a manifest written by ExportManifest and registers the value
+// files already in the cache directory as the entries it describes, with their
+// metadata, replacing any existing entries with the same keys. Imported entries keep
+// their relative recency order and become more recent than all existing entries. A
+// value file is looked for first under the name this cache would give it and then
+// under the name given in the manifest, which differs if the caches escape keys
+// differently, and is renamed if necessary. Entries whose value file is in neither
+// place are skipped.
+func (c *Cache) ImportManifest(r io.Reader) error {
+	var m manifest
+	err := json.NewDecoder(r).Decode(&m)
+	if err != nil {
+		return err
+	}
+
+	return c.update(func(x *state) error {
+		gen, err := c.generation()
+		if err != nil {
+			return err
+		}
+
+		var skipped int
+		for _, e := range m.Entries {
+			ns, err := c.nested(e.Namespace)
+			if err != nil {
+				return err
+			}
+			err = ns.ValidateKey(e.Key)
+			if err != nil {
+				return err
+			}
+			id := ns.id(e.Key)
+
+			ok, err := c.findValue(id, e.File)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				skipped++
+				continue
+			}
+
+			md := e.Meta.clone()
+			md.Blob = ""
+			md.Gen = gen
+			err = c.register(x, id, md)
+			if err != nil {
+				return err
+			}
+		}
+
+		x.Usage, err = c.count()
+		if err != nil {
+			return err
+		}
+		c.debug("imported manifest", "dir", c.Dir, "entries", len(m.Entries)-skipped, "skipped", skipped)
+		return c.evict(x, c.ns)
+	})
+}
+
+// findValue checks that the value file for the given identifier is in place, moving it
+// there from the given name in the cache directory if necessary. It returns false if
+// the value is in neither place. It must be called with the lock held.
+func (c *Cache) findValue(id []byte, name string) (bool, error) {
+	_, err := os.Lstat(c.path(id))
+	if err == nil {
+		return true, nil
+	}
+	if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	// only names that could be values are accepted, since the manifest may come from
+	// anywhere
+	if name == "" {
+		return false, nil
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) || !isValueFile(name) {
+		return false, fmt.Errorf("manifest names %q as a value file", name)
+	}
+
+	// a file under that name that is in the list belongs to another entry
+	_, err = c.readPtr(c.internalPath(name + "~prev"))
+	if err == nil {
+		return false, nil
+	}
+	if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	err = os.Rename(filepath.Join(c.Dir, name), c.path(id))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
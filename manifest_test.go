@@ -0,0 +1,96 @@
+package lrudir
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	src, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(src)
+	dst, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dst)
+
+	c, err := Create(src)
+	require.NoError(t, err)
+	require.NoError(t, c.Put([]byte("a"), []byte("1")))
+	require.NoError(t, c.PutWithTags([]byte("B"), []byte("22"), "blue"))
+	require.NoError(t, c.Namespace("ns").Put([]byte("c"), []byte("333")))
+	require.NoError(t, c.Put([]byte("gone"), []byte("x")))
+
+	var buf bytes.Buffer
+	require.NoError(t, c.ExportManifest(&buf))
+
+	// copy the value files but leave one behind, as rsync would copy everything but
+	// the metadata directory
+	names, err := readDirNames(src)
+	require.NoError(t, err)
+	for _, name := range names {
+		if name == metaDir || name == c.escape([]byte("gone")) {
+			continue
+		}
+		value, err := ioutil.ReadFile(filepath.Join(src, name))
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dst, name), value, 0777))
+	}
+
+	// the destination escapes "B" differently, so its value must be renamed
+	d, err := Create(dst, WithEscaping(EscapeCaseInsensitive))
+	require.NoError(t, err)
+	require.NoError(t, d.Put([]byte("existing"), []byte("e")))
+	require.NoError(t, d.ImportManifest(&buf))
+
+	keys, err := d.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("B"), []byte("a"), []byte("existing")}, keys)
+
+	val, err := d.Namespace("ns").Get([]byte("c"))
+	require.NoError(t, err)
+	assert.Equal(t, "333", string(val))
+
+	val, err = d.Get([]byte("B"))
+	require.NoError(t, err)
+	assert.Equal(t, "22", string(val))
+
+	require.NoError(t, d.InvalidateTag("blue"))
+	_, err = d.Get([]byte("B"))
+	assert.True(t, os.IsNotExist(err))
+
+	// "a" and "existing" remain in the root namespace
+	s, err := d.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, s.Entries)
+	require.NoError(t, d.Verify())
+}
+
+func TestManifestRejectsPaths(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	m := `{"entries":[{"key":"YQ==","file":"../a"}]}`
+	assert.Error(t, c.ImportManifest(bytes.NewBufferString(m)))
+}
+
+func TestManifestRejectsNamespaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	m := `{"entries":[{"namespace":"a\u0000b","key":"YQ==","file":"a"}]}`
+	assert.Error(t, c.ImportManifest(bytes.NewBufferString(m)))
+}
@@ -0,0 +1,95 @@
+package lrudir
+
+import (
+	"path"
+)
+
+// DeleteMatching removes every key in this cache's namespace for which match returns
+// true. The whole operation happens under a single acquisition of the lock, and the list
+// pointers around each run of removed entries are rewritten once rather than once per
+// entry. This is an O(N) operation.
+func (c *Cache) DeleteMatching(match func(key []byte) bool) error {
+	return c.update(func(x *state) error {
+		return c.deleteMatching(x, func(id []byte) (bool, error) {
+			key, ok := c.key(id)
+			return ok && match(key), nil
+		})
+	})
+}
+
+// DeleteGlob removes every key in this cache's namespace that matches the given
+// pattern, using the syntax of path.Match.
+func (c *Cache) DeleteGlob(pattern string) error {
+	// check the pattern up front since path.Match only reports bad patterns when it
+	// gets far enough to notice
+	_, err := path.Match(pattern, "")
+	if err != nil {
+		return err
+	}
+	return c.DeleteMatching(func(key []byte) bool {
+		ok, _ := path.Match(pattern, string(key))
+		return ok
+	})
+}
+
+// deleteMatching removes every entry whose identifier satisfies match, relinking the
+// survivors in a single pass over the list. It returns ErrCorrupt if the list contains
+// a cycle.
+func (c *Cache) deleteMatching(x *state, match func(id []byte) (bool, error)) error {
+	var last []byte  // most recent entry that was kept, or nil for the head sentinel
+	var dropped bool // whether any entries were removed since last
+
+	cur, err := c.readPtr(c.nextPtr(nil))
+	if err != nil {
+		return err
+	}
+	visited := make(map[string]bool)
+	for len(cur) > 0 {
+		if visited[string(cur)] {
+			return corrupt("linked list contains a cycle at %q", cur)
+		}
+		visited[string(cur)] = true
+
+		next, err := c.readPtr(c.nextPtr(cur))
+		if err != nil {
+			return err
+		}
+
+		drop, err := match(cur)
+		if err != nil {
+			return err
+		}
+
+		if drop {
+			err = c.remove(x, cur, EventDelete, "")
+			if err != nil {
+				return err
+			}
+			dropped = true
+		} else {
+			if dropped {
+				err = c.link(last, cur)
+				if err != nil {
+					return err
+				}
+				dropped = false
+			}
+			last = cur
+		}
+		cur = next
+	}
+
+	if dropped {
+		return c.link(last, nil)
+	}
+	return nil
+}
+
+// link makes b the successor of a, where nil denotes the head or tail sentinel
+func (c *Cache) link(a, b []byte) error {
+	err := c.writePtr(c.nextPtr(a), b)
+	if err != nil {
+		return err
+	}
+	return c.writePtr(c.prevPtr(b), a)
+}
@@ -0,0 +1,69 @@
+package lrudir
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteMatching(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	for _, key := range []string{"a1", "b1", "b2", "a2", "b3"} {
+		err = c.Put([]byte(key), nil)
+		require.NoError(t, err)
+	}
+
+	err = c.DeleteMatching(func(key []byte) bool {
+		return bytes.HasPrefix(key, []byte("b"))
+	})
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("a2"), []byte("a1")}, keys)
+
+	oldest, err := c.Oldest()
+	require.NoError(t, err)
+	assert.EqualValues(t, []byte("a1"), oldest)
+
+	err = c.Put([]byte("b4"), nil)
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("b4"), []byte("a2"), []byte("a1")}, keys)
+}
+
+func TestDeleteGlob(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	for _, key := range []string{"img/1.png", "img/2.jpg", "img/3.png"} {
+		err = c.Put([]byte(key), nil)
+		require.NoError(t, err)
+	}
+
+	err = c.DeleteGlob("img/*.png")
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("img/2.jpg")}, keys)
+
+	err = c.DeleteGlob("[")
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package lrudir
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrValueTooLarge is returned when writing a value larger than the limit set by
+// WithMaxValueBytes. Errors that wrap it can be detected with errors.Is.
+var ErrValueTooLarge = errors.New("value too large")
+
+// WithMaxValueBytes limits the size of any single value to n bytes, so that one large
+// value cannot evict everything else. Writes of larger values fail with an error
+// wrapping ErrValueTooLarge, unless WithTruncateValues is also given. Zero means no
+// limit.
+func WithMaxValueBytes(n int64) Option {
+	return func(c *Cache) {
+		c.maxValueBytes = n
+	}
+}
+
+// WithTruncateValues makes writes of values larger than the limit set by
+// WithMaxValueBytes store the first part of the value that fits within the limit,
+// rather than failing. Directories stored with PutDir are never truncated.
+func WithTruncateValues() Option {
+	return func(c *Cache) {
+		c.truncateValues = true
+	}
+}
+
+// fit applies the value size limit to a value of the given size that is about to be
+// stored, returning how many bytes of it to keep
+func (c *Cache) fit(size int64) (int64, error) {
+	if c.maxValueBytes <= 0 || size <= c.maxValueBytes {
+		return size, nil
+	}
+	if c.truncateValues {
+		return c.maxValueBytes, nil
+	}
+	return 0, c.tooLarge(size)
+}
+
+// tooLarge constructs the error for a value of the given size that exceeds the limit
+func (c *Cache) tooLarge(size int64) error {
+	return fmt.Errorf("%w: value is %d bytes but the limit is %d", ErrValueTooLarge, size, c.maxValueBytes)
+}
@@ -0,0 +1,83 @@
+package lrudir
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxValueBytes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxValueBytes(4))
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("three"))
+	assert.True(t, errors.Is(err, ErrValueTooLarge))
+
+	err = c.Append(k1, []byte("23"))
+	assert.True(t, errors.Is(err, ErrValueTooLarge))
+
+	val, err := c.Get(k1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	_, err = c.Get(k2)
+	assert.True(t, os.IsNotExist(err))
+
+	src, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(src)
+
+	err = ioutil.WriteFile(filepath.Join(src, "file"), []byte("hello"), 0777)
+	require.NoError(t, err)
+
+	// the source directory is left alone
+	err = c.PutDir(k2, src)
+	assert.True(t, errors.Is(err, ErrValueTooLarge))
+	_, err = os.Stat(filepath.Join(src, "file"))
+	assert.NoError(t, err)
+}
+
+func TestTruncateValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxValueBytes(4), WithTruncateValues())
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.Put(k1, []byte("three"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("on"))
+	require.NoError(t, err)
+
+	err = c.Append(k2, []byte("ion"))
+	require.NoError(t, err)
+
+	val, err := c.Get(k1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("thre"), val)
+
+	val, err = c.Get(k2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("onio"), val)
+
+	err = c.Verify()
+	require.NoError(t, err)
+}
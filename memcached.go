@@ -0,0 +1,367 @@
+package lrudir
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRelativeExptime is the largest memcached expiry time that is interpreted as a
+// number of seconds from now rather than as a unix timestamp
+const maxRelativeExptime = 60 * 60 * 24 * 30
+
+// maxMemcachedValue is the largest data block accepted by a storage command when the
+// cache has no limit on the size of values, which is the default item size limit of
+// memcached itself
+const maxMemcachedValue = 1 << 20
+
+// errMemcachedTooLarge is reported when a storage command announces a data block larger
+// than the cache will accept
+var errMemcachedTooLarge = errors.New("object too large for cache")
+
+// ServeMemcached accepts connections on l and serves the memcached text protocol
+// backed by this cache, so that existing memcached clients can use it as a persistent
+// bounded cache. It supports get, set, add, replace, append, prepend, delete, touch,
+// flush_all, stats, version, and quit. Client flags are kept in the entry metadata so
+// values are stored exactly as sent. Compare-and-swap (gets and cas) is not supported.
+// ServeMemcached returns when l.Accept fails, such as when l is closed.
+func (c *Cache) ServeMemcached(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go c.serveMemcachedConn(conn)
+	}
+}
+
+// serveMemcachedConn handles commands on a single connection until it is closed
+func (c *Cache) serveMemcachedConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "quit" {
+			w.Flush()
+			return
+		}
+
+		reply, err := c.memcachedCommand(fields, r)
+		if err != nil {
+			// the connection can no longer be parsed reliably
+			fmt.Fprintf(w, "SERVER_ERROR %v\r\n", err)
+			w.Flush()
+			return
+		}
+		w.WriteString(reply)
+		err = w.Flush()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// memcachedCommand executes a single command and returns the reply, which may be empty
+// if the client asked for noreply. Data blocks for storage commands are read from r.
+// Errors from the cache are returned as SERVER_ERROR replies; the error result is
+// reserved for failures to read from the connection.
+func (c *Cache) memcachedCommand(fields []string, r *bufio.Reader) (string, error) {
+	cmd, args := fields[0], fields[1:]
+	noreply := len(args) > 0 && args[len(args)-1] == "noreply"
+	if noreply {
+		args = args[:len(args)-1]
+	}
+	quiet := func(reply string) string {
+		if noreply {
+			return ""
+		}
+		return reply
+	}
+
+	switch cmd {
+	case "get":
+		if len(args) == 0 {
+			return "ERROR\r\n", nil
+		}
+		var b strings.Builder
+		for _, key := range args {
+			value, m, err := c.getWithMeta([]byte(key))
+			if os.IsNotExist(err) {
+				continue
+			}
+			if err != nil {
+				return serverErrorReply(err), nil
+			}
+			fmt.Fprintf(&b, "VALUE %s %d %d\r\n", key, m.Flags, len(value))
+			b.Write(value)
+			b.WriteString("\r\n")
+		}
+		b.WriteString("END\r\n")
+		return b.String(), nil
+
+	case "set", "add", "replace", "append", "prepend":
+		if len(args) != 4 {
+			return "ERROR\r\n", nil
+		}
+		flags, err1 := strconv.ParseUint(args[1], 10, 32)
+		exptime, err2 := strconv.ParseInt(args[2], 10, 64)
+		n, err3 := strconv.Atoi(args[3])
+		if err1 != nil || err2 != nil || err3 != nil || n < 0 {
+			return "CLIENT_ERROR bad command line format\r\n", nil
+		}
+		limit := int64(maxMemcachedValue)
+		if c.maxValueBytes > 0 && (!c.truncateValues || c.maxValueBytes > limit) {
+			// values over the limit are truncated rather than rejected if so configured
+			limit = c.maxValueBytes
+		}
+		if int64(n) > limit {
+			// the data block is not read, so the connection cannot continue
+			return "", errMemcachedTooLarge
+		}
+
+		data := make([]byte, n+2)
+		_, err := io.ReadFull(r, data)
+		if err != nil {
+			return "", err
+		}
+		if string(data[n:]) != "\r\n" {
+			return "CLIENT_ERROR bad data chunk\r\n", nil
+		}
+		err = c.ValidateKey([]byte(args[0]))
+		if err != nil {
+			return "CLIENT_ERROR " + err.Error() + "\r\n", nil
+		}
+
+		m := &meta{Flags: uint32(flags), Expires: c.memcachedExpiry(exptime)}
+		stored, err := c.memcachedStore(cmd, []byte(args[0]), data[:n], m)
+		if err != nil {
+			return serverErrorReply(err), nil
+		}
+		if !stored {
+			return quiet("NOT_STORED\r\n"), nil
+		}
+		return quiet("STORED\r\n"), nil
+
+	case "delete":
+		if len(args) != 1 {
+			return "ERROR\r\n", nil
+		}
+		found, err := c.memcachedDelete([]byte(args[0]))
+		if err != nil {
+			return serverErrorReply(err), nil
+		}
+		if !found {
+			return quiet("NOT_FOUND\r\n"), nil
+		}
+		return quiet("DELETED\r\n"), nil
+
+	case "touch":
+		if len(args) != 2 {
+			return "ERROR\r\n", nil
+		}
+		exptime, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return "CLIENT_ERROR bad command line format\r\n", nil
+		}
+		found, err := c.memcachedTouch([]byte(args[0]), c.memcachedExpiry(exptime))
+		if err != nil {
+			return serverErrorReply(err), nil
+		}
+		if !found {
+			return quiet("NOT_FOUND\r\n"), nil
+		}
+		return quiet("TOUCHED\r\n"), nil
+
+	case "flush_all":
+		err := c.DeleteMatching(func([]byte) bool { return true })
+		if err != nil {
+			return serverErrorReply(err), nil
+		}
+		return quiet("OK\r\n"), nil
+
+	case "stats":
+		s, err := c.Stats()
+		if err != nil {
+			return serverErrorReply(err), nil
+		}
+		return fmt.Sprintf("STAT curr_items %d\r\nSTAT bytes %d\r\nEND\r\n", s.Entries, s.Bytes), nil
+
+	case "version":
+		return "VERSION lrudir\r\n", nil
+	}
+	return "ERROR\r\n", nil
+}
+
+// serverErrorReply formats a cache error for a memcached client
+func serverErrorReply(err error) string {
+	return "SERVER_ERROR " + strings.ReplaceAll(err.Error(), "\n", " ") + "\r\n"
+}
+
+// memcachedExpiry converts a memcached expiry time to unix nanoseconds. Zero means
+// never, values up to thirty days are relative to now, larger values are unix
+// timestamps, and negative values mean the item is already expired.
+func (c *Cache) memcachedExpiry(exptime int64) int64 {
+	switch {
+	case exptime == 0:
+		return 0
+	case exptime < 0:
+		return c.now().Add(-time.Second).UnixNano()
+	case exptime <= maxRelativeExptime:
+		return c.now().Add(time.Duration(exptime) * time.Second).UnixNano()
+	default:
+		return time.Unix(exptime, 0).UnixNano()
+	}
+}
+
+// getWithMeta reads the value and metadata for the given key and moves it to the head
+// of the list
+func (c *Cache) getWithMeta(key []byte) (value []byte, m *meta, err error) {
+	id := c.id(key)
+	defer func() { c.countGet(id, err) }()
+
+	err = c.lock()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.unlock()
+
+	err = c.checkExpiry(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, err = c.readMeta(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = checkComplete(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m.Negative {
+		// memcached has no way to express a negative entry
+		return nil, nil, &os.PathError{Op: "get", Path: c.path(id), Err: os.ErrNotExist}
+	}
+
+	value, err = c.readValue(c.path(id))
+	if err != nil {
+		return nil, nil, err
+	}
+	return value, m, c.access(id)
+}
+
+// memcachedStore implements the memcached storage commands and reports whether the
+// value was stored
+func (c *Cache) memcachedStore(cmd string, key, value []byte, m *meta) (bool, error) {
+	var stored bool
+	err := c.update(func(x *state) error {
+		id := c.id(key)
+		found, err := c.exists(id)
+		if err != nil {
+			return err
+		}
+
+		switch cmd {
+		case "add":
+			if found {
+				return nil
+			}
+		case "replace":
+			if !found {
+				return nil
+			}
+		case "append", "prepend":
+			if !found {
+				return nil
+			}
+			cur, err := ioutil.ReadFile(c.path(id))
+			if err != nil {
+				return err
+			}
+			if cmd == "append" {
+				value = append(cur, value...)
+			} else {
+				value = append(value, cur...)
+			}
+			// flags and expiry are left as they were
+			m, err = c.readMeta(id)
+			if err != nil {
+				return err
+			}
+		}
+
+		stored = true
+		if m.expired(c.now()) {
+			// memcached accepts items that expire immediately and then never returns them
+			if found {
+				return c.delete(x, id)
+			}
+			return nil
+		}
+
+		err = c.put(x, id, value, m)
+		if err != nil {
+			return err
+		}
+		return c.evict(x, c.ns)
+	})
+	return stored, err
+}
+
+// memcachedDelete removes the given key and reports whether it was present
+func (c *Cache) memcachedDelete(key []byte) (bool, error) {
+	var found bool
+	err := c.update(func(x *state) error {
+		id := c.id(key)
+		var err error
+		found, err = c.exists(id)
+		if err != nil || !found {
+			return err
+		}
+		return c.delete(x, id)
+	})
+	return found, err
+}
+
+// memcachedTouch sets a new expiry time for the given key and reports whether it was
+// present
+func (c *Cache) memcachedTouch(key []byte, expires int64) (bool, error) {
+	var found bool
+	err := c.update(func(x *state) error {
+		id := c.id(key)
+		var err error
+		found, err = c.exists(id)
+		if err != nil || !found {
+			return err
+		}
+		m, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+		m.Expires = expires
+		if m.expired(c.now()) {
+			return c.delete(x, id)
+		}
+		err = c.writeMeta(id, m)
+		if err != nil {
+			return err
+		}
+		return c.promote(id)
+	})
+	return found, err
+}
@@ -0,0 +1,98 @@
+package lrudir
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemcached(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go c.ServeMemcached(l)
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	send := func(cmd string, replyLines int) string {
+		_, err := conn.Write([]byte(cmd))
+		require.NoError(t, err)
+		var reply string
+		for i := 0; i < replyLines; i++ {
+			line, err := r.ReadString('\n')
+			require.NoError(t, err)
+			reply += line
+		}
+		return reply
+	}
+
+	assert.Equal(t, "STORED\r\n", send("set foo 42 0 3\r\nbar\r\n", 1))
+	assert.Equal(t, "VALUE foo 42 3\r\nbar\r\nEND\r\n", send("get foo missing\r\n", 3))
+	assert.Equal(t, "NOT_STORED\r\n", send("add foo 0 0 1\r\nx\r\n", 1))
+	assert.Equal(t, "NOT_STORED\r\n", send("replace missing 0 0 1\r\nx\r\n", 1))
+	assert.Equal(t, "STORED\r\n", send("append foo 0 0 3\r\nbaz\r\n", 1))
+	assert.Equal(t, "STORED\r\n", send("prepend foo 0 0 1\r\n>\r\n", 1))
+	assert.Equal(t, "VALUE foo 42 7\r\n>barbaz\r\nEND\r\n", send("get foo\r\n", 3))
+
+	// the value is stored as sent, with the flags kept separately
+	val, err := c.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte(">barbaz"), val)
+
+	assert.Equal(t, "TOUCHED\r\n", send("touch foo -1\r\n", 1))
+	assert.Equal(t, "END\r\n", send("get foo\r\n", 1))
+
+	assert.Equal(t, "STORED\r\n", send("set a 0 0 1\r\n1\r\n", 1))
+	assert.Equal(t, "DELETED\r\n", send("delete a\r\n", 1))
+	assert.Equal(t, "NOT_FOUND\r\n", send("delete a\r\n", 1))
+
+	// noreply suppresses the response entirely
+	send("set b 0 0 1 noreply\r\n2\r\n", 0)
+	assert.Equal(t, "STAT curr_items 1\r\nSTAT bytes 1\r\nEND\r\n", send("stats\r\n", 3))
+	assert.Equal(t, "OK\r\n", send("flush_all\r\n", 1))
+	assert.Equal(t, "END\r\n", send("get b\r\n", 1))
+	assert.Equal(t, "ERROR\r\n", send("bogus\r\n", 1))
+}
+
+func TestMemcachedTooLarge(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxValueBytes(4))
+	require.NoError(t, err)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	go c.ServeMemcached(l)
+
+	for _, n := range []string{"5", "9223372036854775807"} {
+		conn, err := net.Dial("tcp", l.Addr().String())
+		require.NoError(t, err)
+		_, err = conn.Write([]byte("set k 0 0 " + n + "\r\n"))
+		require.NoError(t, err)
+		reply, err := bufio.NewReader(conn).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "SERVER_ERROR object too large for cache\r\n", reply)
+		conn.Close()
+	}
+
+	_, err = c.Get([]byte("k"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,244 @@
+package lrudir
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// ConflictPolicy decides which entry Merge keeps when both caches have the same key
+type ConflictPolicy int
+
+// The policies supported by Merge
+const (
+	KeepNewest   ConflictPolicy = iota // keep the entry that was used most recently
+	KeepLargest                        // keep the entry with the larger value
+	KeepExisting                       // keep the entry already in the destination
+)
+
+// keepIncoming is the policy used by Partition, which always replaces existing entries
+const keepIncoming ConflictPolicy = -1
+
+// mergeEntry is an entry in the source of a merge
+type mergeEntry struct {
+	src   []byte // identifier in the source
+	id    []byte // identifier in the destination
+	m     *meta
+	size  int64
+	value string // path at which the value is staged
+}
+
+// Merge adds every live entry in src's namespace, and any namespaces nested within it,
+// to this cache, as Import does for an archive. Where both caches have an entry for
+// the same key, conflict decides which is kept. Entries from the two caches are
+// interleaved by when they were last used, so the recency order of each is kept and
+// the combined order reflects both. Values are hardlinked when the caches are on the
+// same filesystem and copied otherwise. A shared lock on src is held while its values
+// are staged, and then the lock on this cache while they are added, but never both at
+// once, so two caches can be merged into each other concurrently.
+func (c *Cache) Merge(src *Cache, conflict ConflictPolicy) error {
+	same, err := sameDir(c.Dir, src.Dir)
+	if err != nil {
+		return err
+	}
+	if same {
+		return errors.New("cannot merge a cache into itself")
+	}
+
+	// stage the values inside the cache directory so they can be renamed into place
+	staging, err := ioutil.TempDir(c.internalPath(""), "merge~")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	var entries []mergeEntry
+	err = src.view(func() error {
+		var err error
+		entries, err = src.stageEntries(c, staging, func(key []byte) bool { return true })
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.update(func(x *state) error {
+		err := c.merge(x, entries, conflict)
+		if err != nil {
+			return err
+		}
+		return c.evict(x, c.ns)
+	})
+}
+
+// stageEntries copies the values of the live entries in this cache's namespace, and any
+// namespaces nested within it, whose keys satisfy pred into the staging directory for
+// adding to dst, from most to least recently used. It must be called with the lock or
+// a shared lock held.
+func (c *Cache) stageEntries(dst *Cache, staging string, pred func(key []byte) bool) ([]mergeEntry, error) {
+	var entries []mergeEntry
+	err := c.walk(func(id []byte) error {
+		if !c.contains(id) {
+			return nil
+		}
+		ns, key := c.relative(id)
+		if !pred(key) {
+			return nil
+		}
+
+		m, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+		reason, err := c.invalid(m)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			return nil
+		}
+		m.Blob = ""
+
+		to, err := dst.nested(ns)
+		if err != nil {
+			return err
+		}
+		err = to.ValidateKey(key)
+		if err != nil {
+			return err
+		}
+
+		path := filepath.Join(staging, strconv.Itoa(len(entries)))
+		st, err := os.Lstat(c.path(id))
+		if err != nil {
+			return err
+		}
+		if st.IsDir() {
+			err = copyTree(c.path(id), path)
+		} else {
+			err = copyFile(c.path(id), path)
+		}
+		if err != nil {
+			return err
+		}
+
+		size, err := valueSize(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, mergeEntry{src: id, id: to.id(key), m: m, size: size, value: path})
+		return nil
+	})
+	return entries, err
+}
+
+// merge adds the given entries, which are ordered from most to least recently used,
+// resolving conflicts with existing entries and then interleaving the two by when they
+// were last used. It must be called with the lock held.
+func (c *Cache) merge(x *state, entries []mergeEntry, conflict ConflictPolicy) error {
+	var existing [][]byte
+	used := make(map[string]int64)
+	err := c.walk(func(id []byte) error {
+		m, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+		existing = append(existing, id)
+		used[string(id)] = m.Used
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var added [][]byte
+	replaced := make(map[string]bool)
+	for _, e := range entries {
+		if _, found := used[string(e.id)]; found {
+			keep, err := c.keepExisting(e, used[string(e.id)], conflict)
+			if err != nil {
+				return err
+			}
+			if keep {
+				continue
+			}
+			replaced[string(e.id)] = true
+		}
+
+		err = c.commit(x, e.id, e.value, e.m)
+		if err != nil {
+			return err
+		}
+
+		// commit records the entry as used now, but it keeps its place in the order
+		m, err := c.readMeta(e.id)
+		if err != nil {
+			return err
+		}
+		m.Used = e.m.Used
+		err = c.writeMeta(e.id, m)
+		if err != nil {
+			return err
+		}
+		added = append(added, e.id)
+		used[string(e.id)] = e.m.Used
+	}
+
+	// interleave the two lists, each of which goes from most to least recently used
+	var order [][]byte
+	var i int
+	for _, id := range existing {
+		if replaced[string(id)] {
+			continue
+		}
+		for i < len(added) && used[string(added[i])] > used[string(id)] {
+			order = append(order, added[i])
+			i++
+		}
+		order = append(order, id)
+	}
+	order = append(order, added[i:]...)
+
+	_, err = c.relink(order)
+	if err != nil {
+		return err
+	}
+	c.debug("merged cache", "dir", c.Dir, "added", len(added), "replaced", len(replaced),
+		"skipped", len(entries)-len(added))
+	return nil
+}
+
+// keepExisting returns true if conflict prefers the existing entry for an identifier,
+// last used at the given time, over the entry from the source of a merge
+func (c *Cache) keepExisting(e mergeEntry, used int64, conflict ConflictPolicy) (bool, error) {
+	switch conflict {
+	case KeepNewest:
+		return used >= e.m.Used, nil
+	case KeepLargest:
+		size, err := valueSize(c.path(e.id))
+		if err != nil {
+			return false, err
+		}
+		return size >= e.size, nil
+	case KeepExisting:
+		return true, nil
+	case keepIncoming:
+		return false, nil
+	}
+	return false, errors.New("unknown conflict policy " + strconv.Itoa(int(conflict)))
+}
+
+// sameDir returns true if the two paths refer to the same directory
+func sameDir(a, b string) (bool, error) {
+	sa, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	sb, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	return os.SameFile(sa, sb), nil
+}
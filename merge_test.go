@@ -0,0 +1,91 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mergeCaches creates two caches sharing a clock, writing the given keys to each in
+// turn with the clock advancing a minute before every write
+func mergeCaches(t *testing.T, writes []string) (dst, src *Cache, cleanup func()) {
+	dstDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	srcDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	dst, err = Create(dstDir, WithClock(clk))
+	require.NoError(t, err)
+	src, err = Create(srcDir, WithClock(clk))
+	require.NoError(t, err)
+
+	// writes are given as "d:key=value" or "s:key=value"
+	for _, w := range writes {
+		clk.t = clk.t.Add(time.Minute)
+		c := dst
+		if w[0] == 's' {
+			c = src
+		}
+		kv := strings.SplitN(w[2:], "=", 2)
+		require.NoError(t, c.Put([]byte(kv[0]), []byte(kv[1])))
+	}
+	return dst, src, func() {
+		os.RemoveAll(dstDir)
+		os.RemoveAll(srcDir)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dst, src, cleanup := mergeCaches(t, []string{"d:a=1", "s:b=2", "d:c=3", "s:x=new-but-small", "d:x=old-but-larger", "s:e=5"})
+	defer cleanup()
+
+	require.NoError(t, dst.Merge(src, KeepNewest))
+
+	keys, err := dst.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("e"), []byte("x"), []byte("c"), []byte("b"), []byte("a")}, keys)
+
+	val, err := dst.Get([]byte("x"))
+	require.NoError(t, err)
+	assert.Equal(t, "old-but-larger", string(val))
+
+	// the source is left alone
+	keys, err = src.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 3)
+	require.NoError(t, dst.Verify())
+
+	assert.Error(t, dst.Merge(dst, KeepNewest))
+}
+
+func TestMergeConflicts(t *testing.T) {
+	for _, tc := range []struct {
+		policy ConflictPolicy
+		want   string
+	}{
+		{KeepNewest, "newer"},
+		{KeepLargest, "larger!"},
+		{KeepExisting, "larger!"},
+	} {
+		dst, src, cleanup := mergeCaches(t, []string{"d:k=larger!", "s:k=newer"})
+		defer cleanup()
+
+		require.NoError(t, dst.Merge(src, tc.policy))
+
+		val, err := dst.Get([]byte("k"))
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, string(val))
+
+		s, err := dst.Stats()
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, s.Entries)
+		assert.EqualValues(t, len(tc.want), s.Bytes)
+		require.NoError(t, dst.Verify())
+	}
+}
@@ -0,0 +1,119 @@
+package lrudir
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// meta represents the information stored alongside an entry. It is kept in a separate
+// file that only exists when at least one field is set, or when it must override a
+// stale attribute, see WithXattrMetadata.
+type meta struct {
+	Tags     []string `json:"tags,omitempty"`
+	Blob     string   `json:"blob,omitempty"`     // content hash of the shared blob, if any
+	Expires  int64    `json:"expires,omitempty"`  // expiry time in unix nanoseconds, if any
+	Flags    uint32   `json:"flags,omitempty"`    // opaque client flags, as used by memcached
+	Cost     int64    `json:"cost,omitempty"`     // cost set by PutWithCost, if not the default of one
+	Sliding  int64    `json:"sliding,omitempty"`  // ttl in nanoseconds to renew on each read, if any
+	Gen      int64    `json:"gen,omitempty"`      // generation the entry was written in, see Bump
+	Negative bool     `json:"negative,omitempty"` // whether the entry was written by PutNegative
+	Used     int64    `json:"used,omitempty"`     // time the entry last moved to the head of the list, in unix nanoseconds
+	Accesses int64    `json:"accesses,omitempty"` // number of reads that have moved the entry to the head of the list
+	Partial  []byte   `json:"partial,omitempty"`  // bitmap of the blocks written by PutAt, for entries that are still incomplete
+}
+
+// isZero returns true if there is nothing worth storing in the metadata
+func (m *meta) isZero() bool {
+	return m == nil || (len(m.Tags) == 0 && m.Blob == "" && m.Expires == 0 && m.Flags == 0 && m.Cost == 0 && m.Sliding == 0 && m.Gen == 0 && !m.Negative && m.Used == 0 && m.Accesses == 0 && len(m.Partial) == 0)
+}
+
+// expired returns true if the entry has an expiry time that is not after now
+func (m *meta) expired(now time.Time) bool {
+	return m.Expires != 0 && now.UnixNano() >= m.Expires
+}
+
+// clone returns a copy of the metadata that can be modified without affecting the
+// original. It never returns nil.
+func (m *meta) clone() *meta {
+	if m == nil {
+		return new(meta)
+	}
+	cp := *m
+	return &cp
+}
+
+// metaPtr gets the path to the file that contains the metadata for the given identifier
+func (c *Cache) metaPtr(id []byte) string {
+	return c.internalPath(c.escape(id) + "~meta")
+}
+
+// readMeta loads the metadata for the given identifier, from its metadata file or, for
+// caches created with WithXattrMetadata, from the attribute of its value file if there
+// is no metadata file. Entries with neither get the zero value.
+func (c *Cache) readMeta(id []byte) (*meta, error) {
+	buf, err := ioutil.ReadFile(c.metaPtr(id))
+	if os.IsNotExist(err) && c.xattrMeta {
+		m, ok, err := c.readMetaXattr(id)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return m, nil
+		}
+	}
+	if os.IsNotExist(err) {
+		return new(meta), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var m meta
+	err = json.Unmarshal(buf, &m)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// writeMeta stores the metadata for the given identifier, removing the metadata file
+// entirely if there is nothing to store. The file is replaced by renaming so that
+// readers that do not hold the lock never see it partially written. It must be called
+// with the lock held, which also guards the staging file. For caches created with
+// WithXattrMetadata the metadata is stored in an attribute of the value file instead
+// where possible.
+func (c *Cache) writeMeta(id []byte, m *meta) error {
+	var shadowed bool
+	if c.xattrMeta {
+		ok, sh, err := c.writeMetaXattr(id, m)
+		if err != nil {
+			return err
+		}
+		if ok {
+			m = nil
+		}
+		shadowed = sh
+	}
+
+	if m.isZero() && !shadowed {
+		err := os.Remove(c.metaPtr(id))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	buf, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	tmp := c.internalPath("meta~tmp")
+	err = ioutil.WriteFile(tmp, buf, 0777)
+	if err != nil {
+		return err
+	}
+	return c.renameFile(tmp, c.metaPtr(id))
+}
@@ -0,0 +1,86 @@
+package lrudir
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+// Namespace returns a view of the cache in which keys live in a separate key space
+// identified by name. All namespaces share the same directory, lock, and eviction
+// order, so the least recently used entry across all namespaces is still the first to
+// go. Calling Namespace on a namespaced cache creates a nested namespace. Names cannot
+// be empty or contain slashes or NUL bytes.
+func (c *Cache) Namespace(name string) *Cache {
+	err := checkNamespace(name)
+	if err != nil {
+		panic(err)
+	}
+
+	ns := *c
+	if c.ns == "" {
+		ns.ns = name
+	} else {
+		ns.ns = c.ns + "/" + name
+	}
+	return &ns
+}
+
+// checkNamespace returns an error if the given string cannot be used as a namespace name
+func checkNamespace(name string) error {
+	if name == "" {
+		return errors.New("namespace names cannot be empty")
+	}
+	if strings.IndexByte(name, 0) != -1 {
+		return errors.New("namespace names cannot contain NUL bytes")
+	}
+	if strings.IndexByte(name, '/') != -1 {
+		// a slash separates the names of nested namespaces
+		return errors.New("namespace names cannot contain slashes")
+	}
+	return nil
+}
+
+// id maps a key in this cache's namespace to the identifier under which it is stored in
+// the linked list. Keys in the root namespace are stored as-is, except that a leading NUL
+// byte is doubled. Keys in other namespaces are stored as NUL, namespace, NUL, key. Keys
+// are normalized first if the cache was created with a normalization form.
+func (c *Cache) id(key []byte) []byte {
+	key = c.normalize(key)
+	if c.ns != "" {
+		id := make([]byte, 0, len(c.ns)+len(key)+2)
+		id = append(id, 0)
+		id = append(id, c.ns...)
+		id = append(id, 0)
+		return append(id, key...)
+	}
+	if len(key) > 0 && key[0] == 0 {
+		return append([]byte{0, 0}, key...)
+	}
+	return key
+}
+
+// splitID maps an identifier from the linked list back to a namespace and key
+func splitID(id []byte) (ns string, key []byte) {
+	if len(id) < 2 || id[0] != 0 {
+		return "", id
+	}
+	if id[1] == 0 {
+		return "", id[2:]
+	}
+	n := bytes.IndexByte(id[1:], 0)
+	if n == -1 {
+		return "", id
+	}
+	return string(id[1 : n+1]), id[n+2:]
+}
+
+// key maps an identifier from the linked list to a key in this cache's namespace. It
+// returns false if the identifier belongs to a different namespace.
+func (c *Cache) key(id []byte) ([]byte, bool) {
+	ns, key := splitID(id)
+	if ns != c.ns {
+		return nil, false
+	}
+	return key, true
+}
@@ -0,0 +1,131 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNamespaceSeparateKeySpaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	a, b := c.Namespace("a"), c.Namespace("b")
+
+	err = a.Put([]byte("foo"), []byte("from a"))
+	require.NoError(t, err)
+
+	err = b.Put([]byte("foo"), []byte("from b"))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("foo"), []byte("from root"))
+	require.NoError(t, err)
+
+	val, err := a.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from a"), val)
+
+	val, err = b.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from b"), val)
+
+	val, err = c.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from root"), val)
+
+	keys, err := a.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("foo")}, keys)
+
+	err = a.Delete([]byte("foo"))
+	require.NoError(t, err)
+
+	_, err = a.Get([]byte("foo"))
+	require.Error(t, err)
+
+	val, err = b.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from b"), val)
+}
+
+func TestNamespaceSharedOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	a, b := c.Namespace("a"), c.Namespace("b")
+
+	err = a.Put([]byte("k1"), nil)
+	require.NoError(t, err)
+
+	err = b.Put([]byte("k2"), nil)
+	require.NoError(t, err)
+
+	err = a.Put([]byte("k3"), nil)
+	require.NoError(t, err)
+
+	oldest, err := b.Oldest()
+	require.NoError(t, err)
+	assert.EqualValues(t, []byte("k2"), oldest)
+
+	err = a.DeleteOldest()
+	require.NoError(t, err)
+
+	keys, err := a.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("k3")}, keys)
+}
+
+func TestNamespaceIDs(t *testing.T) {
+	c := &Cache{}
+	for _, key := range []string{"foo", "\x00foo", "\x00", "\x00\x00bar"} {
+		ns, k := splitID(c.id([]byte(key)))
+		assert.Equal(t, "", ns)
+		assert.Equal(t, []byte(key), k)
+	}
+
+	a := c.Namespace("a")
+	for _, key := range []string{"foo", "\x00foo", "a\x00b"} {
+		ns, k := splitID(a.id([]byte(key)))
+		assert.Equal(t, "a", ns)
+		assert.Equal(t, []byte(key), k)
+	}
+}
+
+func TestNamespaceNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	// a name containing a slash would collide with a nested namespace
+	for _, name := range []string{"", "a/b", "/", "a\x00b"} {
+		assert.Error(t, checkNamespace(name), "%q", name)
+	}
+
+	nested := c.Namespace("a").Namespace("b")
+	require.NoError(t, nested.Put([]byte("k"), []byte("nested")))
+
+	ns, err := c.nested("a/b")
+	require.NoError(t, err)
+	val, err := ns.Get([]byte("k"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("nested"), val)
+
+	for _, name := range []string{"a//b", "a/", "a/\x00"} {
+		_, err = c.nested(name)
+		assert.Error(t, err, "%q", name)
+	}
+}
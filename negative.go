@@ -0,0 +1,22 @@
+package lrudir
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNegativeEntry is returned when reading a key that was stored with PutNegative,
+// meaning that it is known not to exist upstream.
+var ErrNegativeEntry = errors.New("key is cached as missing")
+
+// PutNegative records that the given key is known to be missing, so that Get returns
+// ErrNegativeEntry rather than the caller looking it up again. The entry expires after
+// ttl, and otherwise behaves like any other entry with an empty value: it is evicted
+// in order, counts towards limits, and is replaced by a later Put.
+func (c *Cache) PutNegative(key []byte, ttl time.Duration) error {
+	m := meta{Negative: true}
+	if ttl > 0 {
+		m.Expires = c.now().Add(ttl).UnixNano()
+	}
+	return c.putWithMeta(key, nil, &m)
+}
@@ -0,0 +1,64 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutNegative(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	err = c.PutNegative(k1, time.Hour)
+	require.NoError(t, err)
+
+	err = c.PutNegative(k2, time.Nanosecond)
+	require.NoError(t, err)
+
+	err = c.Put(k3, nil)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+
+	_, err = c.Get(k1)
+	assert.Equal(t, ErrNegativeEntry, err)
+
+	_, err = c.Get(k2)
+	assert.True(t, os.IsNotExist(err))
+
+	// an ordinary empty value is not negative
+	val, err := c.Get(k3)
+	require.NoError(t, err)
+	assert.Empty(t, val)
+
+	_, _, err = c.GetOrPut(k1, []byte("one"))
+	assert.Equal(t, ErrNegativeEntry, err)
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	val, err = c.Get(k1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	err = c.PutNegative(k3, 0)
+	require.NoError(t, err)
+
+	_, err = c.Take(k3)
+	assert.Equal(t, ErrNegativeEntry, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1}, keys)
+}
@@ -0,0 +1,103 @@
+package lrudir
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// openMode controls whether New opens an existing cache, creates a new one, or either
+type openMode int
+
+const (
+	openOrCreate openMode = iota
+	mustExist
+	mustNotExist
+)
+
+// WithMustExist makes New fail if the directory does not already hold a cache, rather
+// than creating one
+func WithMustExist() Option {
+	return func(c *Cache) {
+		c.mode = mustExist
+	}
+}
+
+// WithMustNotExist makes New fail if the directory already holds a cache, rather than
+// opening it
+func WithMustNotExist() Option {
+	return func(c *Cache) {
+		c.mode = mustNotExist
+	}
+}
+
+// WithMkdirAll makes creating a cache also create its directory and any missing
+// parents, rather than requiring the directory to exist already
+func WithMkdirAll() Option {
+	return func(c *Cache) {
+		c.mkdirAll = true
+	}
+}
+
+// New opens the LRU cache in the given directory, or initializes one there if the
+// directory does not hold a cache yet. The directory itself must already exist unless
+// WithMkdirAll is given. If several processes race to create the same cache, one
+// creates it and the others open it. All configuration is given as options; settings
+// that are fixed when a cache is created, such as the escaping scheme, are ignored
+// when an existing cache is opened.
+func New(path string, opts ...Option) (*Cache, error) {
+	c, err := newCache(path, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = c.openShards()
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.startJanitor()
+	c.startBatching()
+	return c, nil
+}
+
+// newCache implements New other than starting background work
+func newCache(path string, opts []Option) (*Cache, error) {
+	var cfg Cache
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	switch cfg.mode {
+	case mustExist:
+		return open(path, opts)
+	case mustNotExist:
+		return create(path, opts)
+	}
+
+	initialized, err := isCache(path)
+	if err != nil && !(cfg.mkdirAll && os.IsNotExist(err)) {
+		return nil, err
+	}
+	if !initialized {
+		c, err := create(path, opts)
+		if !os.IsExist(err) {
+			return c, err
+		}
+		// another process created the cache since we looked
+	}
+	return open(path, opts)
+}
+
+// isCache returns true if the directory holds a cache, in either the current or the
+// old layout
+func isCache(path string) (bool, error) {
+	for _, name := range []string{metaDir, metaDir + "~migrate", ".lru"} {
+		_, err := os.Stat(filepath.Join(path, name))
+		if err == nil {
+			return true, nil
+		}
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,101 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	_, err = New(dir, WithMustExist())
+	assert.Error(t, err)
+
+	// an empty directory is initialized
+	c, err := New(dir, WithMaxEntries(1))
+	require.NoError(t, err)
+
+	err = c.Put([]byte("foo"), []byte("bar"))
+	require.NoError(t, err)
+
+	// and an existing cache is opened
+	c, err = New(dir)
+	require.NoError(t, err)
+
+	val, err := c.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bar"), val)
+
+	_, err = New(dir, WithMustNotExist())
+	assert.Error(t, err)
+
+	_, err = New(dir, WithMustExist())
+	assert.NoError(t, err)
+}
+
+func TestCreateFailureKeepsExistingFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(filepath.Join(dir, "precious"), []byte("data"), 0777)
+	require.NoError(t, err)
+
+	// another process wins the race to move its metadata directory into place
+	sabotage := func(c *Cache) {
+		os.MkdirAll(filepath.Join(dir, metaDir, "other"), 0777)
+	}
+	_, err = Create(dir, sabotage)
+	assert.True(t, os.IsExist(err))
+
+	buf, err := ioutil.ReadFile(filepath.Join(dir, "precious"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("data"), buf)
+
+	// the staging directory was cleaned up
+	names, err := filepath.Glob(filepath.Join(dir, metaDir+"~create*"))
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	err = os.RemoveAll(filepath.Join(dir, metaDir))
+	require.NoError(t, err)
+
+	// the directory is not empty, so an exclusive create is refused
+	_, err = CreateExclusive(dir)
+	assert.Error(t, err)
+
+	empty, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(empty)
+
+	_, err = CreateExclusive(empty)
+	assert.NoError(t, err)
+}
+
+func TestNewMkdirAll(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "a", "b")
+	_, err = New(path)
+	assert.Error(t, err)
+
+	_, err = New(path, WithMkdirAll())
+	require.NoError(t, err)
+
+	// creating a cache that already exists reports it as existing, which New treats as
+	// losing a race and opens instead
+	_, err = create(path, nil)
+	assert.True(t, os.IsExist(err))
+
+	_, err = New(path)
+	require.NoError(t, err)
+}
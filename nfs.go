@@ -0,0 +1,268 @@
+package lrudir
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/alexflint/go-filemutex"
+)
+
+// ErrLockLost is returned when releasing a lock created by WithNFSSafe that another
+// process broke because it appeared to have been abandoned, which means that the
+// operation holding it may have overlapped with another process
+var ErrLockLost = errors.New("lock was broken by another process")
+
+const (
+	nfsHeartbeat  = time.Second           // interval at which a held lock file is touched
+	nfsStaleAfter = 10 * time.Second      // time untouched after which a lock file is broken
+	nfsMaxPoll    = 50 * time.Millisecond // longest wait between attempts to take a lock
+)
+
+// WithNFSSafe makes the cache safe to share between machines over NFS, where flock is
+// unreliable. Locks are taken by creating a lock file with O_EXCL, whose owner touches
+// it regularly while it is held, and a lock file left untouched for ten seconds is
+// assumed to belong to a crashed process and is broken. Locks are always exclusive, so
+// readers no longer run concurrently. Renames that NFS reports as failing because a
+// retransmitted request found its work already done are treated as having succeeded.
+// Like the escaping scheme, this is chosen when a cache is created and persisted in the
+// state file, since processes using different kinds of lock would not exclude each
+// other.
+func WithNFSSafe() Option {
+	return func(c *Cache) {
+		c.nfsSafe = true
+	}
+}
+
+// fileLock is a lock shared between processes, which is either a *filemutex.Mutex or,
+// for caches created with WithNFSSafe, an *nfsLock
+type fileLock interface {
+	Lock() error
+	Unlock() error
+	RLock() error
+	RUnlock() error
+	Close() error
+}
+
+// newFileLock opens the lock at the given path in the metadata directory, using the
+// kind of lock this cache was created with
+func (c *Cache) newFileLock(path string) (fileLock, error) {
+	if c.nfsSafe {
+		return newNFSLock(path+".nfs", nfsHeartbeat, nfsStaleAfter), nil
+	}
+	return filemutex.New(path)
+}
+
+// nfsLock is a lock held by the existence of a file, which is created with O_EXCL since
+// that is atomic over NFS when flock is not. Shared locks are exclusive.
+type nfsLock struct {
+	path       string
+	heartbeat  time.Duration
+	staleAfter time.Duration
+
+	mu      sync.Mutex    // guards the fields below
+	token   []byte        // contents of the lock file while it is held by this process
+	done    chan struct{} // closed to stop the heartbeat
+	stopped chan struct{} // closed once the heartbeat has stopped
+}
+
+// newNFSLock constructs a lock held by the existence of the given file, whose owner
+// touches it every heartbeat and which is broken once it is untouched for staleAfter
+func newNFSLock(path string, heartbeat, staleAfter time.Duration) *nfsLock {
+	return &nfsLock{path: path, heartbeat: heartbeat, staleAfter: staleAfter}
+}
+
+// Lock creates the lock file, waiting for any other owner to remove it or abandon it
+func (l *nfsLock) Lock() error {
+	token, err := lockToken()
+	if err != nil {
+		return err
+	}
+
+	var seen []byte       // contents of the lock file when last checked
+	var seenMod time.Time // modification time of the lock file when last checked
+	var since time.Time   // when the lock file was first seen in its current form
+	poll := time.Millisecond
+	for {
+		f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			_, err = f.Write(token)
+			if err != nil {
+				f.Close()
+				os.Remove(l.path)
+				return err
+			}
+			err = f.Close()
+			if err != nil {
+				os.Remove(l.path)
+				return err
+			}
+			l.hold(token)
+			return nil
+		}
+		if !os.IsExist(err) {
+			return err
+		}
+
+		// clocks on different machines disagree, so staleness is judged by how long the
+		// file has gone unchanged according to this machine's clock
+		owner, mod, err := readLockFile(l.path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(owner, seen) || !mod.Equal(seenMod) {
+			seen, seenMod, since = owner, mod, time.Now()
+		} else if time.Since(since) >= l.staleAfter {
+			err = l.breakStale(owner, mod)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		time.Sleep(poll)
+		if poll *= 2; poll > nfsMaxPoll {
+			poll = nfsMaxPoll
+		}
+	}
+}
+
+// breakStale removes the lock file if it still has the given contents and modification
+// time. Another process may break the same file concurrently, so this narrows rather
+// than closes the window in which a fresh lock file could be removed.
+func (l *nfsLock) breakStale(owner []byte, mod time.Time) error {
+	now, nowMod, err := readLockFile(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(now, owner) || !nowMod.Equal(mod) {
+		return nil
+	}
+	err = os.Remove(l.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// hold records that the lock is held with the given token and starts touching the lock
+// file so that other processes can tell that its owner is alive
+func (l *nfsLock) hold(token []byte) {
+	done, stopped := make(chan struct{}), make(chan struct{})
+	l.mu.Lock()
+	l.token, l.done, l.stopped = token, done, stopped
+	l.mu.Unlock()
+
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(l.heartbeat)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				now := time.Now()
+				os.Chtimes(l.path, now, now)
+			}
+		}
+	}()
+}
+
+// Unlock stops the heartbeat and removes the lock file, unless another process has
+// broken it in the meantime, in which case it returns ErrLockLost and leaves the file
+// belonging to the new owner alone
+func (l *nfsLock) Unlock() error {
+	l.mu.Lock()
+	token, done, stopped := l.token, l.done, l.stopped
+	l.token, l.done, l.stopped = nil, nil, nil
+	l.mu.Unlock()
+	if token == nil {
+		return errors.New("unlock of unlocked lock: " + l.path)
+	}
+	close(done)
+	<-stopped
+
+	owner, _, err := readLockFile(l.path)
+	if os.IsNotExist(err) {
+		return ErrLockLost
+	}
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(owner, token) {
+		return ErrLockLost
+	}
+	return os.Remove(l.path)
+}
+
+// RLock is the same as Lock
+func (l *nfsLock) RLock() error {
+	return l.Lock()
+}
+
+// RUnlock is the same as Unlock
+func (l *nfsLock) RUnlock() error {
+	return l.Unlock()
+}
+
+// Close does nothing, since the lock holds no resources while it is not held
+func (l *nfsLock) Close() error {
+	return nil
+}
+
+// readLockFile gets the contents and modification time of a lock file
+func readLockFile(path string) ([]byte, time.Time, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return buf, st.ModTime(), nil
+}
+
+// lockToken generates contents for a lock file that identify this acquisition uniquely
+// and say where it came from, for anyone investigating a lock that is not released
+func lockToken() ([]byte, error) {
+	host, _ := os.Hostname()
+	var nonce [8]byte
+	_, err := rand.Read(nonce[:])
+	if err != nil {
+		return nil, err
+	}
+	token := fmt.Sprintf("%s %d %s\n", host, os.Getpid(), hex.EncodeToString(nonce[:]))
+	return []byte(token), nil
+}
+
+// renameFile is like os.Rename except that, for caches created with WithNFSSafe, a
+// rename reported as failing because the source does not exist is treated as having
+// succeeded if the destination exists. That happens when a retransmitted request finds
+// that the first one already did the work.
+func (c *Cache) renameFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !c.nfsSafe || !os.IsNotExist(err) {
+		return err
+	}
+	if _, serr := os.Lstat(src); !os.IsNotExist(serr) {
+		return err
+	}
+	if _, serr := os.Lstat(dst); serr != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNFSSafe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithNFSSafe())
+	require.NoError(t, err)
+	assert.Nil(t, c.Lock)
+
+	err = c.Put([]byte("key"), []byte("value"))
+	require.NoError(t, err)
+
+	val, err := c.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(val))
+
+	// the lock file only exists while the lock is held
+	_, err = os.Stat(filepath.Join(dir, metaDir, "lock.nfs"))
+	assert.True(t, os.IsNotExist(err))
+
+	// the kind of lock is kept when reopening
+	require.NoError(t, c.Close())
+	c, err = Open(dir)
+	require.NoError(t, err)
+	assert.Nil(t, c.Lock)
+
+	val, err = c.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(val))
+	require.NoError(t, c.Close())
+}
+
+func TestNFSLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "lock")
+	l1 := newNFSLock(path, 5*time.Millisecond, 50*time.Millisecond)
+	l2 := newNFSLock(path, 5*time.Millisecond, 50*time.Millisecond)
+
+	// a held lock is not broken while its owner is alive
+	require.NoError(t, l1.Lock())
+	acquired := make(chan error)
+	go func() { acquired <- l2.Lock() }()
+	select {
+	case <-acquired:
+		t.Fatal("lock was taken while held")
+	case <-time.After(200 * time.Millisecond):
+	}
+	require.NoError(t, l1.Unlock())
+	require.NoError(t, <-acquired)
+	require.NoError(t, l2.Unlock())
+
+	// an abandoned lock is broken, and its owner finds out when unlocking
+	err = ioutil.WriteFile(path, []byte("crashed"), 0666)
+	require.NoError(t, err)
+	require.NoError(t, l1.Lock())
+
+	err = ioutil.WriteFile(path, []byte("someone else"), 0666)
+	require.NoError(t, err)
+	assert.Equal(t, ErrLockLost, l1.Unlock())
+
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+}
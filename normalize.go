@@ -0,0 +1,44 @@
+package lrudir
+
+import (
+	"golang.org/x/text/unicode/norm"
+)
+
+// Normalization determines which Unicode normalization form keys are converted to before
+// they are stored or looked up. Like the escaping scheme, it is chosen when a cache is
+// created and persisted in the state file.
+type Normalization int
+
+const (
+	// NormalizeNone uses keys exactly as given, so keys that look identical but are
+	// encoded differently are distinct entries.
+	NormalizeNone Normalization = iota
+	// NormalizeNFC converts keys to the composed form, which is what most input
+	// methods produce.
+	NormalizeNFC
+	// NormalizeNFD converts keys to the decomposed form, which is what macOS uses for
+	// filenames.
+	NormalizeNFD
+)
+
+// WithKeyNormalization makes keys that differ only in their Unicode normalization map
+// to the same entry. It only has an effect when a cache is created; existing caches
+// always use the normalization they were created with. Keys that are not valid UTF-8
+// are normalized as far as possible and otherwise used as given.
+func WithKeyNormalization(n Normalization) Option {
+	return func(c *Cache) {
+		c.normalization = n
+	}
+}
+
+// normalize converts a key to this cache's normalization form
+func (c *Cache) normalize(key []byte) []byte {
+	switch c.normalization {
+	case NormalizeNFC:
+		return norm.NFC.Bytes(key)
+	case NormalizeNFD:
+		return norm.NFD.Bytes(key)
+	default:
+		return key
+	}
+}
@@ -0,0 +1,64 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyNormalization(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithKeyNormalization(NormalizeNFC))
+	require.NoError(t, err)
+
+	composed, decomposed := []byte("caf\u00e9"), []byte("cafe\u0301")
+
+	err = c.Put(decomposed, []byte("one"))
+	require.NoError(t, err)
+
+	val, err := c.Get(composed)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{composed}, keys)
+
+	// the normalization is persisted rather than taken from the options
+	c, err = Open(dir, WithKeyNormalization(NormalizeNFD))
+	require.NoError(t, err)
+
+	err = c.Put(composed, []byte("two"))
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{composed}, keys)
+
+	err = c.Delete(decomposed)
+	require.NoError(t, err)
+
+	_, err = c.Get(composed)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNoNormalization(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("cafe\u0301"), []byte("one"))
+	require.NoError(t, err)
+
+	_, err = c.Get([]byte("caf\u00e9"))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,205 @@
+package lrudir
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// orderFile is the name of the file in the metadata directory that holds every list
+// pointer for a cache created with WithOrderFile
+const orderFile = "order"
+
+// WithOrderFile keeps the list pointers for every entry in a single file in the metadata
+// directory, rather than in two small files per entry, so that backing up or rsyncing
+// the cache directory transfers one file for the recency order instead of thousands.
+// Values are never renamed once committed in either layout, so unchanged values are
+// not transferred again. The file is read when the lock is taken and rewritten when
+// it is released, if anything changed, so every write costs time proportional to the
+// number of entries. Caches with millions of entries should keep the default layout,
+// in which opening the cache and changing an entry cost the same however many entries
+// there are. Like the escaping scheme, this is chosen when a cache is created and
+// persisted in the state file.
+func WithOrderFile() Option {
+	return func(c *Cache) {
+		c.orderFile = true
+	}
+}
+
+// readPtr reads the list pointer at the given path, as given by nextPtr or prevPtr,
+// returning an error satisfying os.IsNotExist if there is none. It must be called with
+// the lock or a shared lock held.
+func (c *Cache) readPtr(path string) ([]byte, error) {
+	if !c.orderFile {
+		return ioutil.ReadFile(path)
+	}
+
+	c.shared.omu.Lock()
+	defer c.shared.omu.Unlock()
+	err := c.loadOrder()
+	if err != nil {
+		return nil, err
+	}
+	id, ok := c.shared.order[filepath.Base(path)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return append([]byte{}, id...), nil
+}
+
+// writePtr sets the list pointer at the given path. It must be called with the lock
+// held.
+func (c *Cache) writePtr(path string, id []byte) error {
+	if !c.orderFile {
+		return ioutil.WriteFile(path, id, 0777)
+	}
+
+	c.shared.omu.Lock()
+	defer c.shared.omu.Unlock()
+	err := c.loadOrder()
+	if err != nil {
+		return err
+	}
+	c.shared.order[filepath.Base(path)] = append([]byte{}, id...)
+	c.shared.orderDirty = true
+	return nil
+}
+
+// removePtr removes the list pointer at the given path, returning an error satisfying
+// os.IsNotExist if there is none. It must be called with the lock held.
+func (c *Cache) removePtr(path string) error {
+	if !c.orderFile {
+		return os.Remove(path)
+	}
+
+	c.shared.omu.Lock()
+	defer c.shared.omu.Unlock()
+	err := c.loadOrder()
+	if err != nil {
+		return err
+	}
+	if _, ok := c.shared.order[filepath.Base(path)]; !ok {
+		return &os.PathError{Op: "remove", Path: path, Err: os.ErrNotExist}
+	}
+	delete(c.shared.order, filepath.Base(path))
+	c.shared.orderDirty = true
+	return nil
+}
+
+// renamePtr moves the list pointer at one path to another. It must be called with the
+// lock held.
+func (c *Cache) renamePtr(src, dst string) error {
+	if !c.orderFile {
+		return os.Rename(src, dst)
+	}
+
+	id, err := c.readPtr(src)
+	if err != nil {
+		return err
+	}
+	err = c.writePtr(dst, id)
+	if err != nil {
+		return err
+	}
+	return c.removePtr(src)
+}
+
+// loadOrder reads the order file, unless it has been read since the lock was taken. It
+// must be called with omu held.
+func (c *Cache) loadOrder() error {
+	if c.shared.order != nil {
+		return nil
+	}
+	buf, err := ioutil.ReadFile(c.internalPath(orderFile))
+	if err != nil {
+		return err
+	}
+	order := make(map[string][]byte)
+	err = json.Unmarshal(buf, &order)
+	if err != nil {
+		return corrupt("cannot parse order file: %v", err)
+	}
+	c.shared.order = order
+	return nil
+}
+
+// saveOrder writes the order file back if any pointer has changed since it was read,
+// and forgets the pointers, since other processes may change them once the lock is
+// released. It must be called with the lock held, just before releasing it.
+func (c *Cache) saveOrder() error {
+	if !c.orderFile {
+		return nil
+	}
+
+	c.shared.omu.Lock()
+	defer c.shared.omu.Unlock()
+	order, dirty := c.shared.order, c.shared.orderDirty
+	c.shared.order, c.shared.orderDirty = nil, false
+	if !dirty {
+		return nil
+	}
+	return c.writeOrder(c.internalPath(orderFile), order)
+}
+
+// forgetOrder discards pointers read under a shared lock that is being released
+func (c *Cache) forgetOrder() {
+	if !c.orderFile {
+		return
+	}
+	c.shared.omu.Lock()
+	c.shared.order = nil
+	c.shared.omu.Unlock()
+}
+
+// writeOrder replaces the order file at the given path with the given pointers. Each
+// pointer is on a line of its own, in a stable order, so that rsync transfers little
+// more than the lines that changed.
+func (c *Cache) writeOrder(path string, order map[string][]byte) error {
+	buf, err := json.MarshalIndent(order, "", "\t")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(path+"~tmp", buf, 0777)
+	if err != nil {
+		return err
+	}
+	return c.renameFile(path+"~tmp", path)
+}
+
+// compactOrder removes the pointers of entries whose value files no longer exist from
+// the order file, as Compact removes pointer files, returning how many were removed. It
+// must be called with the lock held.
+func (c *Cache) compactOrder() (int, error) {
+	if !c.orderFile {
+		return 0, nil
+	}
+
+	c.shared.omu.Lock()
+	defer c.shared.omu.Unlock()
+	err := c.loadOrder()
+	if err != nil {
+		return 0, err
+	}
+
+	var n int
+	for name := range c.shared.order {
+		value := strings.TrimSuffix(strings.TrimSuffix(name, "~next"), "~prev")
+		if value == "" {
+			// the sentinels at the ends of the list
+			continue
+		}
+		_, err := os.Lstat(filepath.Join(c.Dir, value))
+		if err == nil {
+			continue
+		}
+		if !os.IsNotExist(err) {
+			return 0, err
+		}
+		delete(c.shared.order, name)
+		c.shared.orderDirty = true
+		n++
+	}
+	return n, nil
+}
@@ -0,0 +1,92 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithOrderFile())
+	require.NoError(t, err)
+
+	for _, k := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, c.Put([]byte(k), []byte("value-"+k)))
+	}
+	before, err := os.Stat(c.path([]byte("a")))
+	require.NoError(t, err)
+
+	_, err = c.Get([]byte("a"))
+	require.NoError(t, err)
+	require.NoError(t, c.Delete([]byte("c")))
+
+	// promoting an entry leaves its value file alone
+	after, err := os.Stat(c.path([]byte("a")))
+	require.NoError(t, err)
+	assert.True(t, os.SameFile(before, after))
+
+	names, err := readDirNames(c.internalPath(""))
+	require.NoError(t, err)
+	assert.Contains(t, names, orderFile)
+	for _, name := range names {
+		assert.False(t, strings.HasSuffix(name, "~next") || strings.HasSuffix(name, "~prev"), name)
+	}
+
+	// the setting is persisted and the order is seen by other handles
+	d, err := Open(dir)
+	require.NoError(t, err)
+	keys, err := d.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("a"), []byte("d"), []byte("b")}, keys)
+	require.NoError(t, d.Verify())
+
+	// pointers for entries whose values vanished are compacted away
+	require.NoError(t, os.Remove(c.path([]byte("b"))))
+	require.NoError(t, c.Repair())
+	require.NoError(t, c.lock())
+	require.NoError(t, c.writePtr(c.nextPtr([]byte("b")), nil))
+	require.NoError(t, c.unlock())
+	_, _, err = c.Compact()
+	require.NoError(t, err)
+	_, err = c.readPtr(c.nextPtr([]byte("b")))
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, c.Verify())
+}
+
+func TestOrderFileRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithOrderFile())
+	require.NoError(t, err)
+	require.NoError(t, c.Put([]byte("a"), []byte("1")))
+
+	// crash after committing a value but before saving the order file
+	require.NoError(t, c.lock())
+	x, err := c.state()
+	require.NoError(t, err)
+	require.NoError(t, c.put(x, []byte("b"), []byte("2"), nil))
+	c.shared.order, c.shared.orderDirty = nil, false
+	crash(c)
+
+	_, err = os.Stat(filepath.Join(dir, "b"))
+	require.NoError(t, err)
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("b"), []byte("a")}, keys)
+	require.NoError(t, c.Verify())
+}
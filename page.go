@@ -0,0 +1,53 @@
+package lrudir
+
+import (
+	"errors"
+)
+
+// KeysPage gets up to limit keys from the cache, from most to least recently used,
+// starting after the given key, or at the most recently used key if afterKey is nil.
+// The continuation returned with each page is the afterKey for the next page, and is
+// nil once there are no more keys. Only the part of the list being returned is visited,
+// so paging through a large cache never holds all of its keys at once. Entries that
+// move between pages may be skipped or seen twice, and if the continuation key has
+// been removed in the meantime an error satisfying os.IsNotExist is returned.
+func (c *Cache) KeysPage(afterKey []byte, limit int) (keys [][]byte, next []byte, err error) {
+	if afterKey != nil && len(afterKey) == 0 {
+		return nil, nil, errors.New("cannot page after the empty key")
+	}
+	if limit <= 0 {
+		return nil, nil, nil
+	}
+
+	var after []byte
+	if afterKey != nil {
+		after = c.id(afterKey)
+	}
+
+	err = c.view(func() error {
+		if after != nil {
+			_, err := c.readPtr(c.nextPtr(after))
+			if err != nil {
+				return err
+			}
+		}
+
+		return c.walkAfter(c.nextPtr, after, func(id []byte) error {
+			key, ok := c.key(id)
+			if !ok {
+				return nil
+			}
+			if len(keys) == limit {
+				// there is at least one more key, so the page needs a continuation
+				next = keys[len(keys)-1]
+				return errStop
+			}
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return keys, next, nil
+}
@@ -0,0 +1,52 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeysPage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		err = c.Put([]byte(k), []byte("x"))
+		require.NoError(t, err)
+	}
+	err = c.Namespace("other").Put([]byte("z"), []byte("x"))
+	require.NoError(t, err)
+
+	keys, next, err := c.KeysPage(nil, 2)
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("e"), []byte("d")}, keys)
+	assert.Equal(t, []byte("d"), next)
+
+	keys, next, err = c.KeysPage(next, 2)
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("c"), []byte("b")}, keys)
+	assert.Equal(t, []byte("b"), next)
+
+	keys, next, err = c.KeysPage(next, 2)
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("a")}, keys)
+	assert.Nil(t, next)
+
+	// a page that exactly fills the remainder has no continuation
+	keys, next, err = c.KeysPage([]byte("b"), 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("a")}, keys)
+	assert.Nil(t, next)
+
+	err = c.Delete([]byte("b"))
+	require.NoError(t, err)
+	_, _, err = c.KeysPage([]byte("b"), 2)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,238 @@
+package lrudir
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// partialBlock is the granularity in bytes at which the parts of an incomplete entry
+// that have been written are tracked
+const partialBlock = 1 << 16
+
+// ErrIncomplete is returned when reading a key whose value is still being assembled by
+// PutAt
+var ErrIncomplete = errors.New("value is incomplete")
+
+// Allocate creates an incomplete entry for the given key whose value is size bytes
+// long, replacing any existing entry, so that the value can be assembled in place by
+// PutAt in any order, as for a resumable download. The value file is sparse where the
+// filesystem allows, and counts as size bytes towards the limits from the start. Until
+// every byte has been written, reading the entry fails with ErrIncomplete.
+func (c *Cache) Allocate(key []byte, size int64) error {
+	err := c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+	if size < 0 {
+		return errors.New("size must not be negative")
+	}
+	n, err := c.fit(size)
+	if err != nil {
+		return err
+	}
+	if n < size {
+		return c.tooLarge(size)
+	}
+
+	return c.update(func(x *state) error {
+		id := c.id(key)
+		tmp := c.tempPath(id)
+		f, err := os.Create(tmp)
+		if err != nil {
+			return err
+		}
+		err = f.Truncate(size)
+		if err != nil {
+			f.Close()
+			os.Remove(tmp)
+			return err
+		}
+		err = f.Close()
+		if err != nil {
+			os.Remove(tmp)
+			return err
+		}
+
+		// a value with no blocks to write is complete from the start
+		var m meta
+		if blocks := (size + partialBlock - 1) / partialBlock; blocks > 0 {
+			m.Partial = make([]byte, (blocks+7)/8)
+		}
+		err = c.commit(x, id, tmp, &m)
+		if err != nil {
+			return err
+		}
+		return c.evict(x, c.ns)
+	})
+}
+
+// PutAt writes data into the value for the given key at the given offset, which must
+// lie within the size given to Allocate, and moves the entry to the head of the list.
+// The data is written in place. Once every byte of the value has been written, the
+// entry is complete and can be read like any other. Data that only partly covers a
+// block of the value is written but does not count towards completing it, so writes
+// should start and end on the boundaries of the ranges reported by MissingRanges.
+func (c *Cache) PutAt(key []byte, off int64, data []byte) error {
+	if len(key) == 0 {
+		return errors.New("cannot write to the empty key")
+	}
+	if off < 0 {
+		return errors.New("offset must not be negative")
+	}
+
+	// the value changes in place, so readers holding only its shard must be kept out
+	id := c.id(key)
+	if s := c.shard(id); s != nil {
+		err := s.lock()
+		if err != nil {
+			return err
+		}
+		defer s.unlock()
+	}
+
+	return c.update(func(x *state) error {
+		return c.putAt(id, off, data)
+	})
+}
+
+// putAt implements PutAt. It must be called with the lock held.
+func (c *Cache) putAt(id []byte, off int64, data []byte) error {
+	path := c.path(id)
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	m, err := c.readMeta(id)
+	if err != nil {
+		return err
+	}
+	if m.Partial == nil {
+		return fmt.Errorf("cannot write into %s, which is not an incomplete entry created by Allocate", path)
+	}
+	end := off + int64(len(data))
+	if end > st.Size() {
+		return fmt.Errorf("cannot write %d bytes at offset %d into a value of %d bytes", len(data), off, st.Size())
+	}
+
+	if linkCount(st) != 1 {
+		// break the link so that other entries sharing this file are not modified
+		tmp := c.tempPath(id)
+		err = copyContents(path, tmp)
+		if err != nil {
+			return err
+		}
+		err = c.replace(tmp, path)
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	_, err = f.WriteAt(data, off)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	// only blocks covered in full are marked, where the last block ends with the value
+	first := (off + partialBlock - 1) / partialBlock
+	last := end / partialBlock
+	if end == st.Size() {
+		last = (end + partialBlock - 1) / partialBlock
+	}
+	m = m.clone()
+	m.Partial = append([]byte(nil), m.Partial...)
+	for b := first; b < last; b++ {
+		m.Partial[b/8] |= 1 << uint(b%8)
+	}
+	if len(missingBlocks(m.Partial, st.Size())) == 0 {
+		m.Partial = nil
+	}
+
+	err = c.writeMeta(id, m)
+	if err != nil {
+		return err
+	}
+	return c.promote(id)
+}
+
+// MissingRanges gets the byte ranges of the value for the given key that are yet to be
+// written by PutAt, as pairs of start and end offsets, or nil if the value is complete
+func (c *Cache) MissingRanges(key []byte) ([][2]int64, error) {
+	if len(key) == 0 {
+		return nil, errors.New("cannot get the empty key")
+	}
+
+	var ranges [][2]int64
+	err := c.view(func() error {
+		id := c.id(key)
+		st, err := os.Stat(c.path(id))
+		if err != nil {
+			return err
+		}
+		m, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+		for _, b := range missingBlocks(m.Partial, st.Size()) {
+			end := (b + 1) * partialBlock
+			if end > st.Size() {
+				end = st.Size()
+			}
+			if n := len(ranges); n > 0 && ranges[n-1][1] == b*partialBlock {
+				ranges[n-1][1] = end
+			} else {
+				ranges = append(ranges, [2]int64{b * partialBlock, end})
+			}
+		}
+		return nil
+	})
+	return ranges, err
+}
+
+// missingBlocks lists the blocks of a value of the given size not yet marked in the
+// bitmap of an incomplete entry
+func missingBlocks(bitmap []byte, size int64) []int64 {
+	if bitmap == nil {
+		return nil
+	}
+	var missing []int64
+	for b := int64(0); b*partialBlock < size; b++ {
+		if bitmap[b/8]&(1<<uint(b%8)) == 0 {
+			missing = append(missing, b)
+		}
+	}
+	return missing
+}
+
+// checkReadable is like checkExpiry but also returns ErrIncomplete if the entry for the
+// given identifier is still being assembled by PutAt. It must be called with the lock
+// held.
+func (c *Cache) checkReadable(id []byte) error {
+	err := c.checkExpiry(id)
+	if err != nil {
+		return err
+	}
+	m, err := c.readMeta(id)
+	if err != nil {
+		return err
+	}
+	return checkComplete(m)
+}
+
+// checkComplete returns ErrIncomplete if the metadata belongs to an entry that is still
+// being assembled by PutAt
+func checkComplete(m *meta) error {
+	if m.Partial != nil {
+		return ErrIncomplete
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package lrudir
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutAt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	key := []byte("download")
+	size := int64(2*partialBlock + 100)
+	value := bytes.Repeat([]byte("abcdefgh"), int(size/8)+1)[:size]
+
+	err = c.PutAt(key, 0, value[:10])
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, c.Allocate(key, size))
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, size, s.Bytes)
+
+	ranges, err := c.MissingRanges(key)
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int64{{0, size}}, ranges)
+
+	// write the last block, then the first, then part of the middle one
+	require.NoError(t, c.PutAt(key, 2*partialBlock, value[2*partialBlock:]))
+	require.NoError(t, c.PutAt(key, 0, value[:partialBlock]))
+	require.NoError(t, c.PutAt(key, partialBlock, value[partialBlock:partialBlock+10]))
+	assert.Error(t, c.PutAt(key, size-1, value[:2]))
+
+	ranges, err = c.MissingRanges(key)
+	require.NoError(t, err)
+	assert.Equal(t, [][2]int64{{partialBlock, 2 * partialBlock}}, ranges)
+
+	_, err = c.Get(key)
+	assert.Equal(t, ErrIncomplete, err)
+	_, err = c.OpenReader(key)
+	assert.Equal(t, ErrIncomplete, err)
+
+	require.NoError(t, c.PutAt(key, partialBlock, value[partialBlock:2*partialBlock]))
+	ranges, err = c.MissingRanges(key)
+	require.NoError(t, err)
+	assert.Nil(t, ranges)
+
+	val, err := c.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, value, val)
+
+	// a complete entry cannot be written into
+	assert.Error(t, c.PutAt(key, 0, value[:10]))
+	require.NoError(t, c.Verify())
+}
@@ -0,0 +1,114 @@
+package lrudir
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Partition moves every live entry in this cache's namespace, and any namespaces
+// nested within it, whose key satisfies pred into dst, along with its metadata,
+// replacing any entry in dst with the same key. The moved entries keep their relative
+// order and are interleaved with those already in dst by when they were last used, as
+// for Merge. Values are hardlinked when the caches are on the same filesystem and
+// copied otherwise, and each is removed from this cache only once it is in dst, so an
+// interrupted Partition may leave an entry in both caches but never in neither. The
+// locks on both caches are held throughout, taken in an order fixed by their paths so
+// that concurrent partitions in opposite directions do not deadlock.
+func (c *Cache) Partition(dst *Cache, pred func(key []byte) bool) error {
+	same, err := sameDir(c.Dir, dst.Dir)
+	if err != nil {
+		return err
+	}
+	if same {
+		return errors.New("cannot partition a cache into itself")
+	}
+
+	first, second, err := lockOrder(c, dst)
+	if err != nil {
+		return err
+	}
+	err = first.lock()
+	if err != nil {
+		return err
+	}
+	defer first.unlock()
+	err = second.lock()
+	if err != nil {
+		return err
+	}
+	defer second.unlock()
+
+	// reads that are yet to be applied to either list happened before this move
+	for _, cache := range []*Cache{c, dst} {
+		err = cache.applyAccesses()
+		if err != nil {
+			return err
+		}
+	}
+
+	x, err := c.state()
+	if err != nil {
+		return err
+	}
+	y, err := dst.state()
+	if err != nil {
+		return err
+	}
+
+	// the merge staging prefix keeps the values out of the way of Compact and Snapshot
+	staging, err := ioutil.TempDir(dst.internalPath(""), "merge~")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	entries, err := c.stageEntries(dst, staging, pred)
+	if err != nil {
+		return err
+	}
+
+	err = dst.merge(y, entries, keepIncoming)
+	if err != nil {
+		return err
+	}
+	err = dst.evict(y, dst.ns)
+	if err != nil {
+		return err
+	}
+	err = dst.setState(y)
+	if err != nil {
+		return err
+	}
+
+	moved := make(map[string]bool)
+	for _, e := range entries {
+		moved[string(e.src)] = true
+	}
+	err = c.deleteMatching(x, func(id []byte) (bool, error) {
+		return moved[string(id)], nil
+	})
+	if err != nil {
+		return err
+	}
+	c.debug("partitioned cache", "dir", c.Dir, "to", dst.Dir, "moved", len(entries))
+	return c.setState(x)
+}
+
+// lockOrder returns the two caches in the order in which their locks should be taken
+// when both are needed at once
+func lockOrder(a, b *Cache) (*Cache, *Cache, error) {
+	pa, err := filepath.Abs(a.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	pb, err := filepath.Abs(b.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pb < pa {
+		return b, a, nil
+	}
+	return a, b, nil
+}
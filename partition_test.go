@@ -0,0 +1,48 @@
+package lrudir
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartition(t *testing.T) {
+	dst, src, cleanup := mergeCaches(t, []string{"s:big-a=1", "d:x=2", "s:small-b=3", "s:big-c=4", "d:big-c=old", "s:small-d=5"})
+	defer cleanup()
+
+	err := src.Partition(dst, func(key []byte) bool {
+		return bytes.HasPrefix(key, []byte("big-"))
+	})
+	require.NoError(t, err)
+
+	keys, err := src.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("small-d"), []byte("small-b")}, keys)
+
+	// moved entries are interleaved with existing ones and replace them
+	keys, err = dst.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("big-c"), []byte("x"), []byte("big-a")}, keys)
+
+	val, err := dst.Get([]byte("big-c"))
+	require.NoError(t, err)
+	assert.Equal(t, "4", string(val))
+
+	_, err = src.Get([]byte("big-a"))
+	assert.True(t, os.IsNotExist(err))
+
+	s, err := src.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, s.Entries)
+	s, err = dst.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, s.Entries)
+
+	require.NoError(t, src.Verify())
+	require.NoError(t, dst.Verify())
+
+	assert.Error(t, src.Partition(src, func([]byte) bool { return true }))
+}
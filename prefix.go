@@ -0,0 +1,176 @@
+package lrudir
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// KeysWithPrefix gets all keys in this cache's namespace that begin with the given
+// prefix, in no particular order. Rather than walking the whole list, it scans the
+// directory for filenames that begin with the escaped prefix, so its cost is
+// proportional to the number of files in the directory plus the number of matches.
+func (c *Cache) KeysWithPrefix(prefix []byte) ([][]byte, error) {
+	var ids [][]byte
+	err := c.view(func() error {
+		var err error
+		ids, err = c.idsWithPrefix(prefix)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var keys [][]byte
+	for _, id := range ids {
+		if key, ok := c.key(id); ok {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+// DeletePrefix removes all keys in this cache's namespace that begin with the given
+// prefix, under a single acquisition of the lock.
+func (c *Cache) DeletePrefix(prefix []byte) error {
+	return c.update(func(x *state) error {
+		ids, err := c.idsWithPrefix(prefix)
+		if err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if _, ok := c.key(id); !ok {
+				continue
+			}
+			err = c.delete(x, id)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// idsWithPrefix gets the identifiers of all entries whose key begins with the given
+// prefix. The results may include entries from nested namespaces of the root namespace,
+// which callers filter out.
+func (c *Cache) idsWithPrefix(prefix []byte) ([][]byte, error) {
+	// escaping works rune by rune, so a prefix that ends part way through a rune does
+	// not escape to a prefix of the escaped key
+	if r, _ := utf8.DecodeLastRune(prefix); len(prefix) > 0 && r == utf8.RuneError {
+		return c.idsWithPrefixSlow(prefix)
+	}
+
+	// the adjustments made for Windows depend on the whole name, so compare against
+	// names with those adjustments undone
+	pid := c.id(prefix)
+	escaped := escape(pid, c.escaping, 0)
+
+	names, err := c.valueNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var ids [][]byte
+	for _, name := range names {
+		// hashed names say nothing about the key, so every one is a candidate
+		if !isHashed(name) && !strings.HasPrefix(windowsUnsafe(name), escaped) {
+			continue
+		}
+
+		id, err := c.idFromFilename(name)
+		if err != nil {
+			return nil, err
+		}
+		if id != nil && bytes.HasPrefix(id, pid) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// idsWithPrefixSlow gets the identifiers of all entries whose key begins with the given
+// prefix by walking the entire list
+func (c *Cache) idsWithPrefixSlow(prefix []byte) ([][]byte, error) {
+	pid := c.id(prefix)
+	var ids [][]byte
+	err := c.walk(func(id []byte) error {
+		if bytes.HasPrefix(id, pid) {
+			ids = append(ids, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// valueNames gets the names of all files in the directory that could be value files
+func (c *Cache) valueNames() ([]string, error) {
+	f, err := os.Open(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, name := range names {
+		if isValueFile(name) {
+			values = append(values, name)
+		}
+	}
+	return values, nil
+}
+
+// isValueFile returns true if the given filename in the top level of the cache
+// directory could be the value file for an entry
+func isValueFile(name string) bool {
+	return !strings.Contains(name, "~") && name != metaDir
+}
+
+// idFromFilename recovers the identifier for an entry from the name of its value file.
+// Escaping is not reversible in general, so this follows the entry's prev pointer and
+// then reads back the next pointer of its predecessor. Hashed names have the identifier
+// recorded alongside them instead. It returns nil if the name has neither, since files
+// other than values may have been left in the directory.
+func (c *Cache) idFromFilename(name string) ([]byte, error) {
+	if isHashed(name) {
+		id, err := ioutil.ReadFile(c.internalPath(name + keySidecar))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c.escape(id) != name {
+			return nil, corrupt("key recorded for %s does not hash to it", name)
+		}
+		return id, nil
+	}
+
+	prev, err := c.readPtr(c.internalPath(name + "~prev"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := c.readPtr(c.nextPtr(prev))
+	if err != nil {
+		return nil, err
+	}
+
+	if c.escape(id) != name {
+		return nil, corrupt("prev pointer for %s does not lead back to it", name)
+	}
+	return id, nil
+}
@@ -0,0 +1,104 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	for _, key := range []string{"user/123/a", "user/123/b", "user/124/a", "other"} {
+		err = c.Put([]byte(key), nil)
+		require.NoError(t, err)
+	}
+
+	err = c.Namespace("ns").Put([]byte("user/123/c"), nil)
+	require.NoError(t, err)
+
+	keys, err := c.KeysWithPrefix([]byte("user/123/"))
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Contains(t, keys, []byte("user/123/a"))
+	assert.Contains(t, keys, []byte("user/123/b"))
+
+	err = c.DeletePrefix([]byte("user/123/"))
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("other"), []byte("user/124/a")}, keys)
+
+	keys, err = c.Namespace("ns").Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("user/123/c")}, keys)
+
+	// a prefix that ends part way through a rune
+	err = c.Put([]byte("héllo"), nil)
+	require.NoError(t, err)
+
+	keys, err = c.KeysWithPrefix([]byte("héllo")[:2])
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("héllo")}, keys)
+}
+
+func TestKeysWithPrefixWindowsNames(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	for _, key := range []string{"CON.x", "con", "foo.", "foo.bar", "other"} {
+		err = c.PutString(key, nil)
+		require.NoError(t, err)
+	}
+
+	for prefix, expected := range map[string][]string{
+		"CO":   {"CON.x"},
+		"co":   {"con"},
+		"foo.": {"foo.", "foo.bar"},
+	} {
+		keys, err := c.KeysWithPrefix([]byte(prefix))
+		require.NoError(t, err)
+		var got []string
+		for _, k := range keys {
+			got = append(got, string(k))
+		}
+		sort.Strings(got)
+		assert.Equal(t, expected, got, prefix)
+	}
+}
+
+func TestPrefixIgnoresStrayFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+	require.NoError(t, c.Put([]byte("user/a"), nil))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "user%2Fstray"), nil, 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "user"), nil, 0644))
+
+	keys, err := c.KeysWithPrefix([]byte("user"))
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("user/a")}, keys)
+
+	require.NoError(t, c.DeletePrefix([]byte("user")))
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
@@ -0,0 +1,76 @@
+package lrudir
+
+import "time"
+
+// minPromotedSweep is the number of remembered promotions at which old ones are first
+// forgotten
+const minPromotedSweep = 1024
+
+// WithPromoteAfter makes reads move an entry to the head of the list only if this
+// process has not done so within the last d, since moving an entry that is already
+// near the head costs several file writes and changes little. Recency is tracked per
+// process, so other processes reading the same entry may still move it.
+func WithPromoteAfter(d time.Duration) Option {
+	return func(c *Cache) {
+		c.promoteAfter = d
+	}
+}
+
+// WithNoPromoteOnPut makes writes to a key that is already in the cache replace the
+// value while leaving the entry where it is in the list, so that refreshing many
+// entries does not disturb the recency order. New keys are still added at the head.
+// This applies to every operation that overwrites or appends to a value.
+func WithNoPromoteOnPut() Option {
+	return func(c *Cache) {
+		c.noPromoteOnPut = true
+	}
+}
+
+// recentlyPromoted returns true if reads should leave the entry for the given
+// identifier where it is
+func (c *Cache) recentlyPromoted(id []byte) bool {
+	if c.promoteAfter <= 0 {
+		return false
+	}
+
+	c.shared.amu.Lock()
+	defer c.shared.amu.Unlock()
+	t, ok := c.shared.promoted[string(id)]
+	return ok && c.now().Sub(t) < c.promoteAfter
+}
+
+// promotedRecently is like recentlyPromoted but also remembers that the entry is being
+// promoted now if it returns false
+func (c *Cache) promotedRecently(id []byte) bool {
+	if c.promoteAfter <= 0 {
+		return false
+	}
+
+	now := c.now()
+	c.shared.amu.Lock()
+	defer c.shared.amu.Unlock()
+
+	if t, ok := c.shared.promoted[string(id)]; ok && now.Sub(t) < c.promoteAfter {
+		return true
+	}
+
+	if c.shared.promoted == nil {
+		c.shared.promoted = make(map[string]time.Time)
+	}
+	c.shared.promoted[string(id)] = now
+
+	// forget promotions that no longer matter, doubling the threshold each time so
+	// that the cost stays proportional to the number of reads
+	if len(c.shared.promoted) >= c.shared.promotedSweep {
+		for k, t := range c.shared.promoted {
+			if now.Sub(t) >= c.promoteAfter {
+				delete(c.shared.promoted, k)
+			}
+		}
+		c.shared.promotedSweep = 2 * len(c.shared.promoted)
+		if c.shared.promotedSweep < minPromotedSweep {
+			c.shared.promotedSweep = minPromotedSweep
+		}
+	}
+	return false
+}
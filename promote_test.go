@@ -0,0 +1,89 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromoteAfter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := Create(dir, WithClock(clk), WithPromoteAfter(time.Minute))
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	_, err = c.Get(k1)
+	require.NoError(t, err)
+
+	_, err = c.Get(k2)
+	require.NoError(t, err)
+
+	_, err = c.Get(k1)
+	require.NoError(t, err)
+
+	// the second read of k1 was too soon after the first to move it
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k2, k1}, keys)
+
+	clk.t = clk.t.Add(time.Minute)
+
+	_, err = c.Get(k1)
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1, k2}, keys)
+}
+
+func TestNoPromoteOnPut(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithNoPromoteOnPut())
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	err = c.Put(k1, []byte("uno"))
+	require.NoError(t, err)
+
+	err = c.Append(k1, []byte("!"))
+	require.NoError(t, err)
+
+	err = c.Put(k3, []byte("three"))
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k3, k2, k1}, keys)
+
+	val, err := c.Get(k1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("uno!"), val)
+
+	err = c.Verify()
+	require.NoError(t, err)
+}
@@ -0,0 +1,135 @@
+package lrudir
+
+import (
+	"sort"
+	"time"
+)
+
+// reasonPruned is the eviction reason for entries removed by PruneOlderThan
+const reasonPruned = "pruned"
+
+// KeysBySize gets up to limit keys from this cache's namespace ordered by the size of
+// their values, largest first if descending is true and smallest first otherwise. All
+// keys are returned if limit is not positive. Keys with values of the same size are
+// ordered from most to least recently used. This is an O(N) operation that stats every
+// value.
+func (c *Cache) KeysBySize(descending bool, limit int) ([][]byte, error) {
+	type sized struct {
+		key  []byte
+		size int64
+	}
+
+	var entries []sized
+	err := c.view(func() error {
+		return c.walk(func(id []byte) error {
+			key, ok := c.key(id)
+			if !ok {
+				return nil
+			}
+			size, err := valueSize(c.path(id))
+			if err != nil {
+				return err
+			}
+			entries = append(entries, sized{key, size})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if descending {
+			return entries[i].size > entries[j].size
+		}
+		return entries[i].size < entries[j].size
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	keys := make([][]byte, len(entries))
+	for i, e := range entries {
+		keys[i] = e.key
+	}
+	return keys, nil
+}
+
+// KeysOlderThan gets the keys in this cache's namespace that have not been written or
+// moved to the head of the list since t, from least to most recently used. Since the
+// list is in order of use, only the entries being returned are visited, along with
+// entries in other namespaces that are interleaved with them. Entries written before
+// the time of use was recorded are treated as older than any t.
+func (c *Cache) KeysOlderThan(t time.Time) ([][]byte, error) {
+	var keys [][]byte
+	err := c.view(func() error {
+		return c.walkBack(func(id []byte) error {
+			key, ok := c.key(id)
+			if !ok {
+				return nil
+			}
+			m, err := c.readMeta(id)
+			if err != nil {
+				return err
+			}
+			if m.Used >= t.UnixNano() {
+				return errStop
+			}
+			keys = append(keys, key)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// PruneOlderThan removes the entries in this cache's namespace that have not been
+// written or moved to the head of the list within d, under a single acquisition of the
+// lock, and returns how many were removed and the size of their values. Leased entries
+// are left in place. Entries are visited from least recently used as for KeysOlderThan.
+func (c *Cache) PruneOlderThan(d time.Duration) (removed int, bytes int64, err error) {
+	err = c.update(func(x *state) error {
+		cutoff := c.now().Add(-d).UnixNano()
+
+		var stale [][]byte
+		err := c.walkBack(func(id []byte) error {
+			if _, ok := c.key(id); !ok {
+				return nil
+			}
+			m, err := c.readMeta(id)
+			if err != nil {
+				return err
+			}
+			if m.Used >= cutoff {
+				return errStop
+			}
+			if !c.leased(id) {
+				stale = append(stale, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range stale {
+			size, err := valueSize(c.path(id))
+			if err != nil {
+				return err
+			}
+			err = c.discard(x, id, EventEvict, reasonPruned)
+			if err != nil {
+				return err
+			}
+			removed++
+			bytes += size
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return removed, bytes, nil
+}
@@ -0,0 +1,91 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeysBySize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	for _, k := range []string{"bb", "a", "cccc", "ddd"} {
+		err = c.Put([]byte(k), []byte(k))
+		require.NoError(t, err)
+	}
+
+	keys, err := c.KeysBySize(true, 2)
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("cccc"), []byte("ddd")}, keys)
+
+	keys, err = c.KeysBySize(false, 0)
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("a"), []byte("bb"), []byte("ddd"), []byte("cccc")}, keys)
+}
+
+func TestKeysOlderThan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := Create(dir, WithClock(clk))
+	require.NoError(t, err)
+
+	for _, k := range []string{"a", "b", "c"} {
+		err = c.Put([]byte(k), []byte("x"))
+		require.NoError(t, err)
+		clk.t = clk.t.Add(time.Hour)
+	}
+
+	// reading an entry makes it recent again
+	_, err = c.Get([]byte("a"))
+	require.NoError(t, err)
+
+	keys, err := c.KeysOlderThan(clk.t.Add(-90 * time.Minute))
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("b")}, keys)
+
+	keys, err = c.KeysOlderThan(clk.t)
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("b"), []byte("c")}, keys)
+}
+
+func TestPruneOlderThan(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := Create(dir, WithClock(clk))
+	require.NoError(t, err)
+
+	for _, k := range []string{"a", "bb", "c"} {
+		err = c.Put([]byte(k), []byte(k))
+		require.NoError(t, err)
+		clk.t = clk.t.Add(time.Hour)
+	}
+
+	removed, bytes, err := c.PruneOlderThan(90 * time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.EqualValues(t, 3, bytes)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("c")}, keys)
+
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, s.Entries)
+	require.NoError(t, c.Verify())
+}
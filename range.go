@@ -0,0 +1,78 @@
+package lrudir
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+// GetRange returns up to length bytes of the value for the given key, starting at
+// offset, and moves the entry to the head of the list. Only the requested range is read
+// from disk. The result is shorter than length if the value ends first.
+func (c *Cache) GetRange(key []byte, offset, length int64) ([]byte, error) {
+	if offset < 0 || length < 0 {
+		return nil, errors.New("offset and length must not be negative")
+	}
+
+	f, err := c.OpenReaderAt(key)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, length)
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// OpenReaderAt opens the value for the given key for random access and moves the entry
+// to the head of the list. The caller must close the returned file. On POSIX systems
+// the file remains readable even if the entry is deleted while it is open.
+func (c *Cache) OpenReaderAt(key []byte) (*os.File, error) {
+	return c.open(key)
+}
+
+// OpenReader opens the value for the given key for reading from start to end and moves
+// the entry to the head of the list. The caller must close the returned reader. Large
+// values are read with the hints configured by WithStreamingHints.
+func (c *Cache) OpenReader(key []byte) (io.ReadCloser, error) {
+	f, err := c.open(key)
+	if err != nil {
+		return nil, err
+	}
+	return c.hinted(f)
+}
+
+// open implements OpenReaderAt
+func (c *Cache) open(key []byte) (*os.File, error) {
+	if len(key) == 0 {
+		return nil, errors.New("cannot get the empty key")
+	}
+
+	err := c.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer c.unlock()
+
+	id := c.id(key)
+	err = c.checkReadable(id)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(c.path(id))
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.access(id)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,42 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.Put(k1, []byte("0123456789"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, nil)
+	require.NoError(t, err)
+
+	val, err := c.GetRange(k1, 2, 3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("234"), val)
+
+	val, err = c.GetRange(k1, 8, 5)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("89"), val)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1, k2}, keys)
+
+	_, err = c.GetRange([]byte("missing"), 0, 1)
+	assert.True(t, os.IsNotExist(err))
+}
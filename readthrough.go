@@ -0,0 +1,76 @@
+package lrudir
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Loader fetches the value for a key that is not in the cache. It also returns how long
+// the value should be cached for, where a non-positive duration means forever.
+type Loader func(key []byte) ([]byte, time.Duration, error)
+
+// ReadThrough is a cache that fetches missing values from a loader and stores them
+// before returning them, making it a drop-in memoization layer for slow lookups.
+type ReadThrough struct {
+	cache  *Cache
+	loader Loader
+
+	mu       sync.Mutex
+	inflight map[string]*load // loads in progress, so concurrent misses share one
+}
+
+// load is a call to the loader that other goroutines may be waiting on
+type load struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// NewReadThrough creates a read-through cache on top of c that calls loader on a miss
+func NewReadThrough(c *Cache, loader Loader) *ReadThrough {
+	return &ReadThrough{
+		cache:    c,
+		loader:   loader,
+		inflight: make(map[string]*load),
+	}
+}
+
+// Get returns the value for the given key, calling the loader and storing the result
+// with the TTL it returns if the key is missing or expired. Concurrent misses for the
+// same key within this process share a single call to the loader. Errors from the
+// loader are returned as-is and nothing is stored.
+func (r *ReadThrough) Get(key []byte) ([]byte, error) {
+	value, err := r.cache.Get(key)
+	if err == nil || !os.IsNotExist(err) {
+		return value, err
+	}
+
+	r.mu.Lock()
+	if l, ok := r.inflight[string(key)]; ok {
+		r.mu.Unlock()
+		<-l.done
+		return l.value, l.err
+	}
+	l := &load{done: make(chan struct{})}
+	r.inflight[string(key)] = l
+	r.mu.Unlock()
+
+	var ttl time.Duration
+	l.value, ttl, l.err = r.loader(key)
+	if l.err == nil {
+		l.err = r.cache.PutWithTTL(key, l.value, ttl)
+	}
+
+	r.mu.Lock()
+	delete(r.inflight, string(key))
+	r.mu.Unlock()
+	close(l.done)
+
+	return l.value, l.err
+}
+
+// Cache gets the underlying cache
+func (r *ReadThrough) Cache() *Cache {
+	return r.cache
+}
@@ -0,0 +1,46 @@
+package lrudir
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadThrough(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	var calls int
+	r := NewReadThrough(c, func(key []byte) ([]byte, time.Duration, error) {
+		calls++
+		if string(key) == "bad" {
+			return nil, 0, errors.New("not found upstream")
+		}
+		return append([]byte("value of "), key...), 0, nil
+	})
+
+	val, err := r.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value of foo"), val)
+
+	val, err = r.Get([]byte("foo"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value of foo"), val)
+	assert.Equal(t, 1, calls)
+
+	_, err = r.Get([]byte("bad"))
+	assert.Error(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("foo")}, keys)
+}
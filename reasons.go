@@ -0,0 +1,84 @@
+package lrudir
+
+import "fmt"
+
+// EvictReason gives the cause of an eviction, as reported in eviction events and
+// counted in Stats
+type EvictReason int
+
+// The causes of eviction. The eviction log records finer distinctions, such as whether
+// an entry was invalidated by Bump or pruned. Entries are only evicted as corrupt by
+// Repair, which drops those whose value is missing. Nothing in this package evicts
+// entries for disk pressure yet; that reason is reserved so that callers can handle
+// every cause now.
+const (
+	EvictCapacity     EvictReason = iota + 1 // the cache or a namespace was over its limits
+	EvictTTL                                 // the entry outlived its TTL
+	EvictManual                              // the entry was invalidated by Bump or pruned by PruneOlderThan
+	EvictCorrupt                             // the entry was found to be damaged, see Repair
+	EvictDiskPressure                        // the volume holding the cache was running out of space
+)
+
+var evictReasonNames = map[EvictReason]string{
+	EvictCapacity:     "capacity",
+	EvictTTL:          "ttl",
+	EvictManual:       "manual",
+	EvictCorrupt:      "corrupt",
+	EvictDiskPressure: "disk-pressure",
+}
+
+// String returns the name of the reason as it appears in the journal and in Stats
+func (r EvictReason) String() string {
+	return evictReasonNames[r]
+}
+
+// MarshalText implements encoding.TextMarshaler, so that the counts in Stats are keyed
+// by name when encoded as JSON
+func (r EvictReason) MarshalText() ([]byte, error) {
+	name, ok := evictReasonNames[r]
+	if !ok {
+		return nil, fmt.Errorf("unknown eviction reason %d", int(r))
+	}
+	return []byte(name), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (r *EvictReason) UnmarshalText(text []byte) error {
+	for k, name := range evictReasonNames {
+		if name == string(text) {
+			*r = k
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown eviction reason %q", text)
+}
+
+// evictReason classifies a reason recorded in the eviction log
+func evictReason(reason string) EvictReason {
+	switch reason {
+	case reasonCapacity:
+		return EvictCapacity
+	case reasonExpired:
+		return EvictTTL
+	case reasonInvalidated, reasonPruned:
+		return EvictManual
+	case reasonCorrupt:
+		return EvictCorrupt
+	}
+	return 0
+}
+
+// countEviction records an eviction for the given identifier in the counts reported by
+// Stats
+func (c *Cache) countEviction(id []byte, reason EvictReason) {
+	ns, _ := splitID(id)
+	c.shared.cmu.Lock()
+	defer c.shared.cmu.Unlock()
+	if c.shared.evictions == nil {
+		c.shared.evictions = make(map[string]map[EvictReason]int64)
+	}
+	if c.shared.evictions[ns] == nil {
+		c.shared.evictions[ns] = make(map[EvictReason]int64)
+	}
+	c.shared.evictions[ns][reason]++
+}
@@ -0,0 +1,82 @@
+package lrudir
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvictionReasons(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := Create(dir, WithClock(clk), WithMaxEntries(2))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Put([]byte("a"), []byte("1")))
+	require.NoError(t, c.Put([]byte("b"), []byte("2")))
+	require.NoError(t, c.Put([]byte("c"), []byte("3")))
+
+	require.NoError(t, c.PutWithTTL([]byte("d"), []byte("4"), time.Minute))
+	clk.t = clk.t.Add(time.Hour)
+	_, err = c.Get([]byte("d"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, c.Bump())
+	require.NoError(t, c.ExpireNow())
+
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, map[EvictReason]int64{EvictCapacity: 2, EvictTTL: 1, EvictManual: 1}, s.Evictions)
+
+	// the counts are keyed by name in JSON
+	buf, err := json.Marshal(s.Evictions)
+	require.NoError(t, err)
+	var names map[string]int64
+	require.NoError(t, json.Unmarshal(buf, &names))
+	assert.Equal(t, map[string]int64{"capacity": 2, "ttl": 1, "manual": 1}, names)
+
+	var decoded map[EvictReason]int64
+	require.NoError(t, json.Unmarshal(buf, &decoded))
+	assert.Equal(t, s.Evictions, decoded)
+
+	// deletions are not evictions, and other namespaces are counted separately
+	ns := c.Namespace("other")
+	require.NoError(t, ns.Put([]byte("x"), nil))
+	require.NoError(t, ns.Delete([]byte("x")))
+	s, err = ns.Stats()
+	require.NoError(t, err)
+	assert.Nil(t, s.Evictions)
+}
+
+func TestEvictionReasonRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithEvictionLog(1<<20))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Put([]byte("a"), []byte("1")))
+	require.NoError(t, c.Put([]byte("b"), []byte("2")))
+	require.NoError(t, os.Remove(c.path(c.id([]byte("a")))))
+	require.NoError(t, c.Repair())
+
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.Equal(t, map[EvictReason]int64{EvictCorrupt: 1}, s.Evictions)
+	assert.EqualValues(t, 1, s.Entries)
+
+	records, err := c.EvictionLog()
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, "corrupt", records[0].Reason)
+	require.NoError(t, c.Verify())
+}
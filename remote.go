@@ -0,0 +1,115 @@
+package lrudir
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// RemoteCache is a client for a cache exposed by Server, such as one run by
+// cmd/lrudird. It implements Store, and missing keys produce errors satisfying
+// os.IsNotExist just as they do for a local cache.
+type RemoteCache struct {
+	url    string
+	client *http.Client
+}
+
+// NewRemoteCache creates a client for the server at the given base URL. If client is
+// nil then http.DefaultClient is used.
+func NewRemoteCache(url string, client *http.Client) *RemoteCache {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &RemoteCache{url: strings.TrimSuffix(url, "/"), client: client}
+}
+
+// keyURL gets the URL for the given key
+func (r *RemoteCache) keyURL(key []byte) string {
+	return r.url + "/keys/" + base64.RawURLEncoding.EncodeToString(key)
+}
+
+// send sends a request with the given body, which may be nil, and returns the response
+// if it succeeded, translating error statuses. The caller must close the response body.
+func (r *RemoteCache) send(method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &os.PathError{Op: strings.ToLower(method), Path: url, Err: os.ErrNotExist}
+	}
+	buf, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	return nil, fmt.Errorf("%s %s: %s: %s", method, url, resp.Status, strings.TrimSpace(string(buf)))
+}
+
+// do sends a request and returns the whole response body
+func (r *RemoteCache) do(method, url string, body io.Reader) ([]byte, error) {
+	resp, err := r.send(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Get returns the value for the given key
+func (r *RemoteCache) Get(key []byte) ([]byte, error) {
+	return r.do(http.MethodGet, r.keyURL(key), nil)
+}
+
+// OpenReader opens the value for the given key for reading from start to end, streaming
+// it from the server as it is read rather than holding it in memory. The caller must
+// close the returned reader.
+func (r *RemoteCache) OpenReader(key []byte) (io.ReadCloser, error) {
+	resp, err := r.send(http.MethodGet, r.keyURL(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Put sets the value for the given key
+func (r *RemoteCache) Put(key, value []byte) error {
+	_, err := r.do(http.MethodPut, r.keyURL(key), bytes.NewReader(value))
+	return err
+}
+
+// PutReader sets the value for the given key to everything read from value, streaming
+// it to the server, which streams it to disk in turn
+func (r *RemoteCache) PutReader(key []byte, value io.Reader) error {
+	_, err := r.do(http.MethodPut, r.keyURL(key), value)
+	return err
+}
+
+// Delete removes the given key
+func (r *RemoteCache) Delete(key []byte) error {
+	_, err := r.do(http.MethodDelete, r.keyURL(key), nil)
+	return err
+}
+
+// Stats returns the number of entries and total size of values on the server
+func (r *RemoteCache) Stats() (Stats, error) {
+	var s Stats
+	buf, err := r.do(http.MethodGet, r.url+"/stats", nil)
+	if err != nil {
+		return s, err
+	}
+	err = json.Unmarshal(buf, &s)
+	return s, err
+}
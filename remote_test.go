@@ -0,0 +1,134 @@
+package lrudir
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoteCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(Server(c))
+	defer srv.Close()
+
+	r := NewRemoteCache(srv.URL, nil)
+	var _ Store = r
+
+	key := []byte("\x00binary/key")
+	err = r.Put(key, []byte("remote value"))
+	require.NoError(t, err)
+
+	val, err := c.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("remote value"), val)
+
+	val, err = r.Get(key)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("remote value"), val)
+
+	s, err := r.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, s.Entries)
+	assert.EqualValues(t, 12, s.Bytes)
+	assert.EqualValues(t, 2, s.Hits)
+
+	err = r.Delete(key)
+	require.NoError(t, err)
+
+	_, err = r.Get(key)
+	assert.True(t, os.IsNotExist(err))
+
+	err = r.Delete(key)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRemoteCacheStreaming(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxValueBytes(1<<20))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(Server(c))
+	defer srv.Close()
+	r := NewRemoteCache(srv.URL, nil)
+
+	value := bytes.Repeat([]byte("0123456789abcdef"), 1<<16)
+	err = r.PutReader([]byte("big"), bytes.NewReader(value))
+	require.NoError(t, err)
+
+	rc, err := r.OpenReader([]byte("big"))
+	require.NoError(t, err)
+	buf, err := ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	require.NoError(t, rc.Close())
+	assert.Equal(t, value, buf)
+
+	_, err = r.OpenReader([]byte("missing"))
+	assert.True(t, os.IsNotExist(err))
+
+	// values over the server's limit are refused and leave the entry as it was
+	err = r.PutReader([]byte("big"), io.MultiReader(bytes.NewReader(value), bytes.NewReader(value)))
+	assert.Error(t, err)
+	val, err := c.Get([]byte("big"))
+	require.NoError(t, err)
+	assert.Equal(t, value, val)
+}
+
+func TestServeEntry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("a"), []byte("hello world"))
+	require.NoError(t, err)
+	err = c.Put([]byte("b"), []byte("x"))
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	c.ServeEntry(w, httptest.NewRequest("GET", "/", nil), []byte("a"))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hello world", w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	// the entry was promoted
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("a"), []byte("b")}, keys)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	c.ServeEntry(w, req, []byte("a"))
+	assert.Equal(t, http.StatusNotModified, w.Code)
+
+	req = httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Range", "bytes=6-")
+	w = httptest.NewRecorder()
+	c.ServeEntry(w, req, []byte("a"))
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "world", w.Body.String())
+
+	w = httptest.NewRecorder()
+	c.ServeEntry(w, httptest.NewRequest("GET", "/", nil), []byte("missing"))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
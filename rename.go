@@ -0,0 +1,113 @@
+package lrudir
+
+import (
+	"bytes"
+	"errors"
+	"os"
+)
+
+// Rename moves the entry for oldKey to newKey, keeping its position in the recency
+// order. The value file is renamed rather than copied, so this is cheap even for large
+// values. If newKey already exists, it is replaced.
+func (c *Cache) Rename(oldKey, newKey []byte) error {
+	if len(oldKey) == 0 {
+		return errors.New("cannot rename the empty key")
+	}
+	err := c.ValidateKey(newKey)
+	if err != nil {
+		return err
+	}
+	return c.update(func(x *state) error {
+		return c.rename(x, c.id(oldKey), c.id(newKey))
+	})
+}
+
+// rename moves the entry for one identifier to another
+func (c *Cache) rename(x *state, from, to []byte) error {
+	_, err := os.Stat(c.path(from))
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(from, to) {
+		return nil
+	}
+
+	// remove any existing entry at the destination first, since doing so may rewrite
+	// the pointers of the entry being renamed
+	err = c.delete(x, to)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = c.journal(from, to)
+	if err != nil {
+		return err
+	}
+
+	next, err := c.readPtr(c.nextPtr(from))
+	if err != nil {
+		return err
+	}
+
+	prev, err := c.readPtr(c.prevPtr(from))
+	if err != nil {
+		return err
+	}
+
+	m, err := c.readMeta(from)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(c.path(from), c.path(to))
+	if err != nil {
+		return err
+	}
+
+	err = c.renamePtr(c.nextPtr(from), c.nextPtr(to))
+	if err != nil {
+		return err
+	}
+
+	err = c.renamePtr(c.prevPtr(from), c.prevPtr(to))
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(c.metaPtr(from), c.metaPtr(to))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if c.xattrMeta {
+		// the attribute moved with the value but still names the old entry
+		err = c.writeMeta(to, m)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = c.removeKey(from)
+	if err != nil {
+		return err
+	}
+
+	err = c.writeKey(to)
+	if err != nil {
+		return err
+	}
+
+	err = c.writePtr(c.nextPtr(prev), to)
+	if err != nil {
+		return err
+	}
+
+	err = c.writePtr(c.prevPtr(next), to)
+	if err != nil {
+		return err
+	}
+
+	c.record(EventDelete, from, 0)
+	c.record(EventPut, to, 0)
+	return nil
+}
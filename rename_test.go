@@ -0,0 +1,56 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRename(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2, k3, k4 := []byte("key1"), []byte("key2"), []byte("key3"), []byte("key4")
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	err = c.Put(k3, []byte("three"))
+	require.NoError(t, err)
+
+	err = c.Rename(k2, k4)
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k3, k4, k1}, keys)
+
+	val, err := c.Get(k4)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("two"), val)
+
+	// renaming onto an existing neighbour replaces it
+	err = c.Rename(k4, k3)
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k3, k1}, keys)
+
+	val, err = c.Get(k3)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("two"), val)
+
+	err = c.Rename(k2, k4)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,204 @@
+package lrudir
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Replica reads a cache that another process populates, without ever taking its lock
+// or writing to it. Reads do not move entries to the head of the list, so the owner
+// sees them only if they are recorded with WithAccessLog and merged by MergeAccesses.
+type Replica struct {
+	c *Cache
+}
+
+// WithAccessLog makes a Replica append the identifier of every entry it reads to the
+// file at the given path, which should be private to the replica, so that the owner of
+// the cache can apply them with MergeAccesses. It has no effect on a Cache.
+func WithAccessLog(path string) Option {
+	return func(c *Cache) {
+		c.accessLog = path
+	}
+}
+
+// OpenReplica opens the cache in the given directory for reading only. No lock is
+// taken, so reads never wait for the owner, but neither is an interrupted operation
+// repaired, and the cache must already exist. Settings fixed when the cache was created
+// are read from its state file as for Open.
+func OpenReplica(path string, opts ...Option) (*Replica, error) {
+	c := &Cache{
+		Dir:    path,
+		shared: new(shared),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	x, err := c.state()
+	if err != nil {
+		return nil, err
+	}
+	c.escaping = x.Escaping
+	c.escapeVersion = x.EscapeVersion
+	c.normalization = x.Normalization
+	c.orderFile = x.OrderFile
+	c.xattrMeta = x.XattrMeta
+	return &Replica{c: c}, nil
+}
+
+// Namespace gets a view of the replica for the given namespace, as for Cache.Namespace
+func (r *Replica) Namespace(name string) *Replica {
+	return &Replica{c: r.c.Namespace(name)}
+}
+
+// Get returns the value for the given key. The owner may replace or remove the value
+// while it is being read, so if it disappears part way through the read is retried
+// once, and only then reported as missing. Expired entries are reported as missing but
+// left for the owner to remove, and sliding expiries are not renewed.
+func (r *Replica) Get(key []byte) (value []byte, err error) {
+	if len(key) == 0 {
+		return nil, errors.New("cannot get the empty key")
+	}
+
+	c := r.c
+	id := c.id(key)
+	defer func() { c.countGet(id, err) }()
+
+	for attempt := 0; ; attempt++ {
+		var retry bool
+		value, retry, err = r.get(id)
+		if !retry || attempt > 0 {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.logAccess(id)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// get reads the value for the given identifier, reporting true if it was found but then
+// disappeared before it could be read
+func (r *Replica) get(id []byte) ([]byte, bool, error) {
+	c := r.c
+	_, err := os.Lstat(c.path(id))
+	if err != nil {
+		return nil, false, err
+	}
+
+	m, err := c.readMeta(id)
+	if err != nil {
+		return nil, os.IsNotExist(err), err
+	}
+	reason, err := c.invalid(m)
+	if err != nil {
+		return nil, false, err
+	}
+	if reason != "" {
+		return nil, false, &os.PathError{Op: "get", Path: c.path(id), Err: os.ErrNotExist}
+	}
+	err = checkComplete(m)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, err := c.readValue(c.path(id))
+	if err != nil {
+		return nil, os.IsNotExist(err), err
+	}
+	if len(value) == 0 && m.Negative {
+		return nil, false, ErrNegativeEntry
+	}
+	return value, false, nil
+}
+
+// Exists returns true if there is a live entry for the given key
+func (r *Replica) Exists(key []byte) (bool, error) {
+	_, err := r.Get(key)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err == ErrNegativeEntry {
+		return true, nil
+	}
+	return err == nil, err
+}
+
+// Keys gets all keys in the replica's namespace, sorted from most to least recently
+// used. Since no lock is held, the owner may change the list during the walk, which is
+// then retried once. The result reflects the order the owner last saved.
+func (r *Replica) Keys() ([][]byte, error) {
+	c := r.c
+	var keys [][]byte
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		// pointers cached from the order file by an earlier walk may be stale
+		c.forgetOrder()
+		keys, err = c.keys()
+		if err == nil {
+			break
+		}
+	}
+	c.forgetOrder()
+	return keys, err
+}
+
+// logAccess records a read in the access log given to WithAccessLog, if any
+func (r *Replica) logAccess(id []byte) error {
+	if r.c.accessLog == "" {
+		return nil
+	}
+	f, err := os.OpenFile(r.c.accessLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write([]byte(base64.StdEncoding.EncodeToString(id) + "\n"))
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// MergeAccesses applies the reads recorded in the access log at the given path by a
+// Replica opened with WithAccessLog, moving the entries read to the head of the list
+// as if they had been read through this cache, and then removes the log. The log is
+// renamed before it is read, so the replica can go on recording reads meanwhile.
+// Entries removed since they were read are skipped.
+func (c *Cache) MergeAccesses(path string) error {
+	merging := path + "~merge"
+	err := os.Rename(path, merging)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	buf, err := ioutil.ReadFile(merging)
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(bytes.TrimSpace(buf)), "\n") {
+		// the last line may have been cut short if the replica crashed
+		id, err := base64.StdEncoding.DecodeString(line)
+		if err == nil && len(id) > 0 {
+			c.recordAccess(id)
+		}
+	}
+
+	err = c.flushAccesses()
+	if err != nil {
+		return err
+	}
+	return os.Remove(merging)
+}
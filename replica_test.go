@@ -0,0 +1,86 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplica(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	logDir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(logDir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+	for _, k := range []string{"a", "b", "c"} {
+		require.NoError(t, c.Put([]byte(k), []byte("value-"+k)))
+	}
+
+	log := filepath.Join(logDir, "accesses")
+	r, err := OpenReplica(dir, WithAccessLog(log))
+	require.NoError(t, err)
+
+	// the replica holds no lock, so it can read while the owner holds it
+	require.NoError(t, c.lock())
+	val, err := r.Get([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "value-a", string(val))
+	require.NoError(t, c.unlock())
+
+	_, err = r.Get([]byte("missing"))
+	assert.True(t, os.IsNotExist(err))
+
+	ok, err := r.Exists([]byte("b"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// reads do not change the order until the owner merges them
+	keys, err := r.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("c"), []byte("b"), []byte("a")}, keys)
+
+	require.NoError(t, c.MergeAccesses(log))
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("b"), []byte("a"), []byte("c")}, keys)
+
+	_, err = os.Stat(log)
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, c.MergeAccesses(log))
+
+	// entries removed by the owner disappear from the replica
+	require.NoError(t, c.Delete([]byte("a")))
+	_, err = r.Get([]byte("a"))
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, c.Verify())
+}
+
+func TestReplicaOrderFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithOrderFile())
+	require.NoError(t, err)
+	require.NoError(t, c.Put([]byte("a"), []byte("1")))
+
+	r, err := OpenReplica(dir)
+	require.NoError(t, err)
+	keys, err := r.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("a")}, keys)
+
+	// changes saved by the owner are seen by the next walk
+	require.NoError(t, c.Put([]byte("b"), []byte("2")))
+	keys, err = r.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("b"), []byte("a")}, keys)
+}
@@ -0,0 +1,116 @@
+package lrudir
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Server returns a handler that exposes the cache over HTTP so that it can be shared
+// by several machines through RemoteCache. Keys appear in URLs as unpadded URL-safe
+// base64 under /keys/, and GET, PUT, and DELETE on those URLs correspond to Get, Put,
+// and Delete. Values travel as raw request and response bodies, which are streamed to
+// and from the value files rather than held in memory, so large values can be moved
+// without buffering them. GET /stats returns the Stats for the cache as JSON.
+func Server(c *Cache) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys/", func(w http.ResponseWriter, r *http.Request) {
+		key, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(r.URL.Path, "/keys/"))
+		if err != nil || len(key) == 0 {
+			http.Error(w, "invalid key", http.StatusBadRequest)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/octet-stream")
+			c.ServeEntry(w, r, key)
+		case http.MethodPut:
+			err = c.PutReader(key, r.Body)
+			if errors.Is(err, ErrValueTooLarge) {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			if err != nil {
+				serverError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			err = c.Delete(key)
+			if err != nil {
+				serverError(w, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		s, err := c.Stats()
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s)
+	})
+	return mux
+}
+
+// ServeEntry responds to r with the value for the given key and moves the entry to the
+// head of the list. The response is produced by http.ServeContent from the value file
+// itself, so it supports range and conditional requests, and the body is sent with
+// sendfile where the platform allows rather than being copied through memory. The
+// Last-Modified header is the time the value was written and the ETag changes whenever
+// the value does. Missing, expired, and negative entries get a 404 response.
+func (c *Cache) ServeEntry(w http.ResponseWriter, r *http.Request, key []byte) {
+	f, err := c.open(key)
+	if err != nil {
+		if len(key) > 0 {
+			c.countGet(c.id(key), err)
+		}
+		serverError(w, err)
+		return
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		serverError(w, err)
+		return
+	}
+	if !st.Mode().IsRegular() {
+		http.Error(w, "entry is not a regular file", http.StatusInternalServerError)
+		return
+	}
+	if st.Size() == 0 {
+		m, err := c.readMeta(c.id(key))
+		if err != nil {
+			serverError(w, err)
+			return
+		}
+		if m.Negative {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	c.countGet(c.id(key), nil)
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, st.ModTime().UnixNano(), st.Size()))
+	http.ServeContent(w, r, "", st.ModTime(), f)
+}
+
+// serverError reports a cache error to an HTTP client
+func serverError(w http.ResponseWriter, err error) {
+	if os.IsNotExist(err) {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,244 @@
+package lrudir
+
+import (
+	"bytes"
+	"context"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// WithLockShards splits locking between the lock that guards the list and n further
+// lock files that each guard the entries whose keys hash to them. Put then writes the
+// value under its shard's lock and holds the main lock only to link the entry in, and
+// reads that leave the list unchanged hold only a shared lock on their shard. Reads
+// leave the list unchanged when promotion is batched by WithBatchedPromotion or
+// skipped by WithPromoteAfter, so those options are what make sharding worthwhile. The
+// number of shards is chosen when a cache is created and persisted in the state file,
+// so that every process agrees on it.
+func WithLockShards(n int) Option {
+	return func(c *Cache) {
+		c.lockShards = n
+	}
+}
+
+// shard is one of the lock files created by WithLockShards, along with the in-process
+// locking that the file lock needs, since flock does not exclude goroutines
+type shard struct {
+	mu      sync.RWMutex // excludes writers in this process, and guards closed
+	rmu     sync.Mutex   // guards readers
+	readers int          // number of goroutines holding the shared file lock
+	closed  bool         // whether the cache has been closed
+	file    fileLock     // the lock file
+}
+
+// openShards opens the lock files for the shards recorded in the state, if any
+func (c *Cache) openShards() error {
+	var shards []*shard
+	for i := 0; i < c.lockShards; i++ {
+		f, err := c.newFileLock(c.internalPath("lock." + strconv.Itoa(i)))
+		if err != nil {
+			for _, s := range shards {
+				s.file.Close()
+			}
+			return err
+		}
+		shards = append(shards, &shard{file: f})
+	}
+	c.shared.shards = shards
+	return nil
+}
+
+// closeShards closes the lock files for the shards, waiting for operations that hold
+// them to finish
+func (c *Cache) closeShards() {
+	for _, s := range c.shared.shards {
+		s.mu.Lock()
+		s.closed = true
+		s.file.Close()
+		s.mu.Unlock()
+	}
+}
+
+// shard gets the shard for the given identifier, or nil if the cache is not sharded
+func (c *Cache) shard(id []byte) *shard {
+	if len(c.shared.shards) == 0 {
+		return nil
+	}
+	h := fnv.New32a()
+	h.Write(id)
+	return c.shared.shards[h.Sum32()%uint32(len(c.shared.shards))]
+}
+
+// lock acquires exclusive access to the entries in the shard. It must be acquired
+// before the main lock, never after.
+func (s *shard) lock() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	err := s.file.Lock()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// unlock releases the lock acquired by lock
+func (s *shard) unlock() error {
+	err := s.file.Unlock()
+	s.mu.Unlock()
+	return err
+}
+
+// rlock acquires shared access to the entries in the shard. As for the main lock, the
+// file lock is held on behalf of every reader in the process.
+func (s *shard) rlock() error {
+	s.mu.RLock()
+	if s.closed {
+		s.mu.RUnlock()
+		return ErrClosed
+	}
+	s.rmu.Lock()
+	defer s.rmu.Unlock()
+	if s.readers == 0 {
+		err := s.file.RLock()
+		if err != nil {
+			s.mu.RUnlock()
+			return err
+		}
+	}
+	s.readers++
+	return nil
+}
+
+// runlock releases the lock acquired by rlock
+func (s *shard) runlock() error {
+	var err error
+	s.rmu.Lock()
+	s.readers--
+	if s.readers == 0 {
+		err = s.file.RUnlock()
+	}
+	s.rmu.Unlock()
+	s.mu.RUnlock()
+	return err
+}
+
+// write stores the value and metadata for the given identifier and evicts as needed.
+// The value is written to a file of its own before the main lock is taken, under the
+// shard's lock in a sharded cache, and the lock is held only to move it into place and
+// link the entry in. Concurrent writes of the same key, by this process or others,
+// therefore never mix their data or their changes to the list: each replaces the value
+// whole, and the last to take the lock wins.
+func (c *Cache) write(ctx context.Context, id, value []byte, m *meta) error {
+	if c.contentAddressed {
+		// values are only stored once their hash is checked against the blobs
+		return c.update(func(x *state) error {
+			err := c.put(x, id, value, m)
+			if err != nil {
+				return err
+			}
+			return c.evictContext(ctx, x, c.ns)
+		})
+	}
+
+	n, err := c.fit(int64(len(value)))
+	if err != nil {
+		return err
+	}
+	return c.writeFrom(ctx, id, bytes.NewReader(value[:n]), m)
+}
+
+// writeFrom is like write but copies the value from r, which must already respect the
+// value size limit, into the staged file
+func (c *Cache) writeFrom(ctx context.Context, id []byte, r io.Reader, m *meta) error {
+	if s := c.shard(id); s != nil {
+		err := s.lock()
+		if err != nil {
+			return err
+		}
+		defer s.unlock()
+	}
+
+	// tempPath is only for writes made under the lock, so stage the value under a
+	// unique name
+	f, err := ioutil.TempFile(c.stagingPath(""), "put~*~tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	err = f.Close()
+	if err != nil {
+		return err
+	}
+
+	m = m.clone()
+	m.Blob = ""
+	return c.update(func(x *state) error {
+		err := c.commit(x, id, f.Name(), m)
+		if err != nil {
+			return err
+		}
+		return c.evictContext(ctx, x, c.ns)
+	})
+}
+
+// getShared reads the value for the given identifier holding only a shared lock on its
+// shard, for reads that will not change the list. It reports false, without an error,
+// if the entry needs something only the main lock allows, such as removal once expired
+// or renewal of a sliding expiry.
+func (c *Cache) getShared(s *shard, id []byte) ([]byte, bool, error) {
+	err := s.rlock()
+	if err != nil {
+		return nil, false, err
+	}
+	defer s.runlock()
+
+	m, err := c.readMeta(id)
+	if err != nil {
+		return nil, false, err
+	}
+	reason, err := c.invalid(m)
+	if err != nil {
+		return nil, false, err
+	}
+	if reason != "" || m.Sliding != 0 {
+		return nil, false, nil
+	}
+	err = checkComplete(m)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, err := c.readValue(c.path(id))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(value) == 0 && m.Negative {
+		return nil, false, ErrNegativeEntry
+	}
+	return value, true, nil
+}
+
+// accessShared records a read made by getShared, which cannot promote the entry itself
+func (c *Cache) accessShared(id []byte) error {
+	if !c.batched || c.promotedRecently(id) {
+		return nil
+	}
+	if c.recordAccess(id) {
+		return c.flushAccesses()
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package lrudir
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockShards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithLockShards(4), WithBatchedPromotion(0, 0), WithMaxEntries(8))
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := []byte(fmt.Sprintf("key%d", i))
+			for j := 0; j < 10; j++ {
+				assert.NoError(t, c.Put(key, []byte(fmt.Sprintf("value%d", j))))
+				_, err := c.Get(key)
+				assert.NoError(t, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 8)
+	require.NoError(t, c.Verify())
+
+	err = c.Append([]byte("key0"), []byte("!"))
+	require.NoError(t, err)
+	val, err := c.Get([]byte("key0"))
+	require.NoError(t, err)
+	assert.Equal(t, "value9!", string(val))
+
+	found, err := c.Exists([]byte("key1"))
+	require.NoError(t, err)
+	assert.True(t, found)
+
+	// the number of shards is kept when reopening, whatever the options say
+	require.NoError(t, c.Close())
+	c, err = Open(dir, WithLockShards(2))
+	require.NoError(t, err)
+	assert.Len(t, c.shared.shards, 4)
+
+	_, err = c.Get([]byte("key2"))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Close())
+	_, err = c.Exists([]byte("key2"))
+	assert.Equal(t, ErrClosed, err)
+}
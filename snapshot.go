@@ -0,0 +1,112 @@
+package lrudir
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Snapshot writes a consistent point-in-time copy of the cache directory to dstDir,
+// which must be empty or not exist. The lock is held while copying, so the cache can be
+// snapshotted while in use by other processes. Values are hardlinked when dstDir is on
+// the same filesystem, which is safe because the cache never modifies a value file
+// that is shared with another link; bookkeeping files are always copied. The snapshot
+// can be opened with Open like any other cache.
+func (c *Cache) Snapshot(dstDir string) error {
+	err := os.MkdirAll(dstDir, 0777)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ioutil.ReadDir(dstDir)
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return errors.New("snapshot destination is not empty: " + dstDir)
+	}
+
+	err = c.lock()
+	if err != nil {
+		return err
+	}
+	defer c.unlock()
+
+	files, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+		if !isValueFile(name) {
+			continue
+		}
+		src, dst := filepath.Join(c.Dir, name), filepath.Join(dstDir, name)
+		if f.IsDir() {
+			err = copyTree(src, dst)
+		} else {
+			err = copyFile(src, dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	err = os.Mkdir(filepath.Join(dstDir, metaDir), 0777)
+	if err != nil {
+		return err
+	}
+
+	files, err = ioutil.ReadDir(c.internalPath(""))
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		name := f.Name()
+		src, dst := c.internalPath(name), filepath.Join(dstDir, metaDir, name)
+		switch {
+		case name == "lock" || strings.HasPrefix(name, "lock.") || strings.HasSuffix(name, "~tmp") || strings.HasPrefix(name, "import~") || strings.HasPrefix(name, "merge~"):
+			// the snapshot gets its own lock, and staged files are not part of the cache
+			continue
+		case strings.HasPrefix(name, eventLog):
+			// events describe changes to this cache, not the snapshot
+			continue
+		case name == blobDir:
+			err = linkTree(src, dst)
+		default:
+			err = copyContents(src, dst)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkTree recreates a flat directory of immutable files at dst, linking each file when
+// possible and copying it otherwise
+func linkTree(src, dst string) error {
+	err := os.Mkdir(dst, 0777)
+	if err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), "~tmp") {
+			continue
+		}
+		err = copyFile(filepath.Join(src, f.Name()), filepath.Join(dst, f.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	snap := filepath.Join(dir+"-snapshot", "backup")
+	defer os.RemoveAll(dir + "-snapshot")
+
+	err = c.Snapshot(snap)
+	require.NoError(t, err)
+
+	// changes after the snapshot are not visible in it
+	err = c.Put(k1, []byte("changed"))
+	require.NoError(t, err)
+
+	err = c.Append(k2, []byte("more"))
+	require.NoError(t, err)
+
+	s, err := Open(snap)
+	require.NoError(t, err)
+
+	keys, err := s.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k2, k1}, keys)
+
+	val, err := s.Get(k1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	val, err = s.Get(k2)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("two"), val)
+
+	err = c.Snapshot(snap)
+	assert.Error(t, err)
+}
@@ -0,0 +1,101 @@
+package lrudir
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithStagingDir makes writes stage new values in the given directory, rather than in
+// the metadata directory, before moving them into place. The directory is created if
+// necessary and is used by this handle alone, so other processes may stage elsewhere.
+// Values are moved into place by renaming, which is atomic and cheap when the staging
+// directory is on the same filesystem as the cache. A staging directory on another
+// filesystem, such as faster media, also works, but then each value is copied into the
+// metadata directory before being renamed into place. Anything left in the directory
+// by writes that never finished is removed by Compact and Vacuum, so the directory must
+// not be used for anything else.
+func WithStagingDir(path string) Option {
+	return func(c *Cache) {
+		c.stagingDir = path
+	}
+}
+
+// stagingPath gets the path to the given name in the directory in which new values are
+// staged
+func (c *Cache) stagingPath(name string) string {
+	if c.stagingDir == "" {
+		return c.internalPath(name)
+	}
+	return filepath.Join(c.stagingDir, name)
+}
+
+// unstage copies a value staged on a different filesystem from the cache into the
+// metadata directory, from where it can be renamed into place, returning its new path
+func (c *Cache) unstage(src string) (string, error) {
+	dst := c.internalPath(filepath.Base(src))
+	err := os.RemoveAll(dst)
+	if err != nil {
+		return "", err
+	}
+
+	err = copyTree(src, dst)
+	if err != nil {
+		os.RemoveAll(dst)
+		return "", err
+	}
+
+	err = os.RemoveAll(src)
+	if err != nil {
+		os.RemoveAll(dst)
+		return "", err
+	}
+	return dst, nil
+}
+
+// sweepStaging removes values left in the staging directory given to WithStagingDir by
+// writes that never finished, returning how many there were and their total size.
+// Values staged by Put before taking the lock are only removed once abandoned. It must
+// be called with the lock held for every shard.
+func (c *Cache) sweepStaging() (int, int64, error) {
+	if c.stagingDir == "" {
+		return 0, 0, nil
+	}
+
+	names, err := readDirNames(c.stagingDir)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var files int
+	var reclaimed int64
+	for _, name := range names {
+		if !strings.HasSuffix(name, "~tmp") {
+			continue
+		}
+		path := filepath.Join(c.stagingDir, name)
+		if strings.HasPrefix(name, "put~") {
+			old, err := abandoned(path)
+			if err != nil {
+				return 0, 0, err
+			}
+			if !old {
+				continue
+			}
+		}
+		size, err := valueSize(path)
+		if err != nil {
+			return 0, 0, err
+		}
+		err = os.RemoveAll(path)
+		if err != nil {
+			return 0, 0, err
+		}
+		files++
+		reclaimed += size
+	}
+	return files, reclaimed, nil
+}
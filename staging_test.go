@@ -0,0 +1,81 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagingDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	staging := filepath.Join(tmp, "staging")
+	c, err := Create(dir, WithStagingDir(staging))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Put([]byte("a"), []byte("one")))
+	require.NoError(t, c.Append([]byte("a"), []byte("two")))
+
+	val, err := c.Get([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "onetwo", string(val))
+
+	names, err := readDirNames(staging)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+
+	// values left behind by writes that never finished are swept
+	require.NoError(t, ioutil.WriteFile(filepath.Join(staging, "b~tmp"), []byte("xyz"), 0777))
+	files, reclaimed, err := c.Vacuum()
+	require.NoError(t, err)
+	assert.Equal(t, 1, files)
+	assert.EqualValues(t, 3, reclaimed)
+
+	names, err = readDirNames(staging)
+	require.NoError(t, err)
+	assert.Empty(t, names)
+	require.NoError(t, c.Verify())
+
+	// the directory is created when opening the cache too
+	require.NoError(t, os.RemoveAll(staging))
+	c, err = Open(dir, WithStagingDir(staging))
+	require.NoError(t, err)
+	require.NoError(t, c.Put([]byte("b"), []byte("two")))
+}
+
+func TestUnstage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tmp, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(tmp)
+
+	staging := filepath.Join(tmp, "staging")
+	c, err := Create(dir, WithStagingDir(staging))
+	require.NoError(t, err)
+
+	// as done when the staging directory is on another filesystem
+	src := c.tempPath(c.id([]byte("a")))
+	require.NoError(t, ioutil.WriteFile(src, []byte("one"), 0777))
+	dst, err := c.unstage(src)
+	require.NoError(t, err)
+	assert.Equal(t, c.internalPath(filepath.Base(src)), dst)
+
+	_, err = os.Stat(src)
+	assert.True(t, os.IsNotExist(err))
+	buf, err := ioutil.ReadFile(dst)
+	require.NoError(t, err)
+	assert.Equal(t, "one", string(buf))
+}
@@ -0,0 +1,143 @@
+package lrudir
+
+import (
+	"expvar"
+	"os"
+	"path/filepath"
+)
+
+// Stats describes the contents of a cache. Hits and Misses count the Gets made through
+// this process since the cache was opened, while the other fields describe what is on
+// disk.
+type Stats struct {
+	Entries   int64 `json:"entries"`
+	Bytes     int64 `json:"bytes"`
+	Inodes    int64 `json:"inodes"` // files used by the entries, see WithMaxInodes
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	GhostHits int64 `json:"ghostHits,omitempty"` // misses for keys in the ghost list, see WithGhostList
+
+	// Evictions counts the entries evicted by this process, by reason
+	Evictions map[EvictReason]int64 `json:"evictions,omitempty"`
+}
+
+// Stats returns the number of entries and total size of values in this cache's
+// namespace, along with the hits, misses, and evictions for the namespace in this
+// process. It reads the persisted usage counters and does not walk the list.
+func (c *Cache) Stats() (Stats, error) {
+	var s Stats
+	err := c.view(func() error {
+		x, err := c.state()
+		if err != nil {
+			return err
+		}
+		u := x.usage(c.ns)
+		s.Entries, s.Bytes, s.Inodes = u.Entries, u.Bytes, u.Inodes
+		return nil
+	})
+
+	c.shared.cmu.Lock()
+	s.Hits, s.Misses = c.shared.hits[c.ns], c.shared.misses[c.ns]
+	s.GhostHits = c.shared.ghostHits[c.ns]
+	for reason, n := range c.shared.evictions[c.ns] {
+		if s.Evictions == nil {
+			s.Evictions = make(map[EvictReason]int64)
+		}
+		s.Evictions[reason] = n
+	}
+	c.shared.cmu.Unlock()
+	return s, err
+}
+
+// DiskUsage reports the space the whole cache occupies on disk, across all namespaces:
+// the value files, the bookkeeping in the metadata directory and in the directory given
+// to WithStagingDir, and their sum. Space is counted in allocated blocks where the
+// platform reports them, so small files count for a whole block and sparse files for
+// less than their size, and directories count too. A file with several links, such as
+// a value shared by WithContentAddressing, is counted once, as a value. A shared lock is
+// held while the directories are walked.
+func (c *Cache) DiskUsage() (values, metadata, total int64, err error) {
+	err = c.view(func() error {
+		seen := make(map[[2]uint64]bool)
+		count := func(root string, skip string, n *int64) error {
+			return filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+				if os.IsNotExist(err) {
+					// writes stage files without holding the lock
+					return nil
+				}
+				if err != nil {
+					return err
+				}
+				if p == skip {
+					return filepath.SkipDir
+				}
+				if key, ok := fileKey(info); ok {
+					if seen[key] {
+						return nil
+					}
+					seen[key] = true
+				}
+				*n += diskSize(info)
+				return nil
+			})
+		}
+
+		err := count(c.Dir, c.internalPath(""), &values)
+		if err != nil {
+			return err
+		}
+		err = count(c.internalPath(""), "", &metadata)
+		if err != nil {
+			return err
+		}
+		if c.stagingDir != "" {
+			return count(c.stagingDir, "", &metadata)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return values, metadata, values + metadata, nil
+}
+
+// countGet records the outcome of a Get for the given identifier as a hit or a miss,
+// and as a ghost hit if it missed a key in the ghost list. Errors other than missing
+// keys are neither.
+func (c *Cache) countGet(id []byte, err error) {
+	var counts []*map[string]int64
+	switch {
+	case err == nil:
+		counts = append(counts, &c.shared.hits)
+	case os.IsNotExist(err):
+		counts = append(counts, &c.shared.misses)
+		if c.isGhost(id) {
+			counts = append(counts, &c.shared.ghostHits)
+		}
+	default:
+		return
+	}
+
+	c.shared.cmu.Lock()
+	defer c.shared.cmu.Unlock()
+	for _, m := range counts {
+		if *m == nil {
+			*m = make(map[string]int64)
+		}
+		(*m)[c.ns]++
+	}
+}
+
+// PublishExpvar publishes the Stats for this cache's namespace under the given name, so
+// that they appear in the JSON served by the standard expvar handler. The stats are
+// read each time the variable is requested. Like expvar.Publish, it panics if the name
+// is already in use.
+func (c *Cache) PublishExpvar(prefix string) {
+	expvar.Publish(prefix, expvar.Func(func() interface{} {
+		s, err := c.Stats()
+		if err != nil {
+			return map[string]string{"error": err.Error()}
+		}
+		return s
+	}))
+}
@@ -0,0 +1,77 @@
+package lrudir
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	// expvar names can only be published once per process, so each run needs its own
+	name := "lrudir_test_stats_" + filepath.Base(dir)
+	a := c.Namespace("a")
+	a.PublishExpvar(name)
+
+	err = a.Put([]byte("foo"), []byte("bar"))
+	require.NoError(t, err)
+
+	_, err = a.Get([]byte("foo"))
+	require.NoError(t, err)
+
+	_, err = a.Get([]byte("missing"))
+	require.Error(t, err)
+
+	// gets in other namespaces are counted separately
+	_, err = c.Get([]byte("foo"))
+	require.Error(t, err)
+
+	var s Stats
+	err = json.Unmarshal([]byte(expvar.Get(name).String()), &s)
+	require.NoError(t, err)
+	assert.Equal(t, Stats{Entries: 1, Bytes: 3, Inodes: 4, Hits: 1, Misses: 1}, s)
+}
+
+func TestDiskUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	_, emptyMeta, _, err := c.DiskUsage()
+	require.NoError(t, err)
+
+	value := bytes.Repeat([]byte("x"), 100000)
+	require.NoError(t, c.Put([]byte("a"), value))
+	require.NoError(t, c.Put([]byte("b"), value[:1]))
+
+	values, metadata, total, err := c.DiskUsage()
+	require.NoError(t, err)
+	assert.True(t, values >= 100001, "values use %d bytes", values)
+	assert.True(t, metadata > emptyMeta, "metadata uses %d bytes", metadata)
+	assert.Equal(t, values+metadata, total)
+
+	// a second link to a value is not counted again
+	require.NoError(t, os.Link(c.path([]byte("a")), c.internalPath("extra")))
+	again, _, _, err := c.DiskUsage()
+	require.NoError(t, err)
+	if runtime.GOOS != "windows" {
+		assert.Equal(t, values, again)
+	}
+}
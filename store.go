@@ -0,0 +1,163 @@
+package lrudir
+
+import (
+	"os"
+	"sync"
+)
+
+// Store is a key-value store that a cache can sit in front of, such as an object store
+// on S3 or GCS. Get must return an error satisfying os.IsNotExist for missing keys.
+// *Cache implements Store, so caches can also be stacked.
+type Store interface {
+	Get(key []byte) ([]byte, error)
+	Put(key, value []byte) error
+	Delete(key []byte) error
+}
+
+// Backed is a cache that uses a local directory cache as a fast tier in front of a
+// slower backing store. Reads are served locally when possible and fall back to the
+// store on a miss, populating the local tier. Writes go to both tiers, either
+// synchronously (write-through) or via an asynchronous queue (write-back).
+type Backed struct {
+	local   *Cache
+	backing Store
+
+	queue chan backedOp  // nil for write-through
+	wg    sync.WaitGroup // counts operations that have not reached the store yet
+	mu    sync.Mutex     // guards err
+	err   error          // first error from an asynchronous write
+	done  chan struct{}  // closed when the flusher exits
+	once  sync.Once      // guards closing the queue
+}
+
+// backedOp is a write waiting to be applied to the backing store
+type backedOp struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+// NewWriteThrough creates a cache in which every write is applied to the backing store
+// before returning
+func NewWriteThrough(local *Cache, backing Store) *Backed {
+	return &Backed{local: local, backing: backing}
+}
+
+// NewWriteBack creates a cache in which writes are applied to the local tier
+// immediately and queued for the backing store, which is updated in the background in
+// the order the writes were made. At most queueSize writes are buffered before Put and
+// Delete block. Call Flush to wait for queued writes and Close to stop the background
+// flusher.
+func NewWriteBack(local *Cache, backing Store, queueSize int) *Backed {
+	b := &Backed{
+		local:   local,
+		backing: backing,
+		queue:   make(chan backedOp, queueSize),
+		done:    make(chan struct{}),
+	}
+	go b.flusher()
+	return b
+}
+
+// flusher applies queued writes to the backing store
+func (b *Backed) flusher() {
+	defer close(b.done)
+	for op := range b.queue {
+		err := b.apply(op)
+		if err != nil {
+			b.mu.Lock()
+			if b.err == nil {
+				b.err = err
+			}
+			b.mu.Unlock()
+		}
+		b.wg.Done()
+	}
+}
+
+// apply performs a single write against the backing store
+func (b *Backed) apply(op backedOp) error {
+	if op.delete {
+		err := b.backing.Delete(op.key)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return b.backing.Put(op.key, op.value)
+}
+
+// submit applies a write to the backing store now or later depending on the mode
+func (b *Backed) submit(op backedOp) error {
+	if b.queue == nil {
+		return b.apply(op)
+	}
+	b.wg.Add(1)
+	b.queue <- op
+	return nil
+}
+
+// Get returns the value for the given key from the local tier, or from the backing
+// store if it is not present locally, in which case it is also stored locally
+func (b *Backed) Get(key []byte) ([]byte, error) {
+	value, err := b.local.Get(key)
+	if err == nil || !os.IsNotExist(err) {
+		return value, err
+	}
+
+	value, err = b.backing.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.local.Put(key, value)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Put sets the value for the given key in both tiers
+func (b *Backed) Put(key, value []byte) error {
+	err := b.local.Put(key, value)
+	if err != nil {
+		return err
+	}
+	return b.submit(backedOp{key: key, value: value})
+}
+
+// Delete removes the given key from both tiers. It is not an error for the key to be
+// missing from either tier.
+func (b *Backed) Delete(key []byte) error {
+	err := b.local.Delete(key)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return b.submit(backedOp{key: key, delete: true})
+}
+
+// Flush waits until every write made so far has been applied to the backing store and
+// returns the first error encountered by an asynchronous write, if any. It does nothing
+// for write-through caches.
+func (b *Backed) Flush() error {
+	b.wg.Wait()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	err := b.err
+	b.err = nil
+	return err
+}
+
+// Close flushes any queued writes and stops the background flusher. The cache must not
+// be written to after Close.
+func (b *Backed) Close() error {
+	if b.queue == nil {
+		return nil
+	}
+	err := b.Flush()
+	b.once.Do(func() {
+		close(b.queue)
+	})
+	<-b.done
+	return err
+}
@@ -0,0 +1,102 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore is an in-memory Store for testing
+type memStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (s *memStore) Get(key []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.data[string(key)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return value, nil
+}
+
+func (s *memStore) Put(key, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[string(key)] = value
+	return nil
+}
+
+func (s *memStore) Delete(key []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, string(key))
+	return nil
+}
+
+func TestWriteThrough(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	s := &memStore{data: map[string][]byte{"remote": []byte("from store")}}
+	b := NewWriteThrough(c, s)
+
+	err = b.Put([]byte("foo"), []byte("bar"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bar"), s.data["foo"])
+
+	val, err := b.Get([]byte("remote"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from store"), val)
+
+	// the miss populated the local tier
+	val, err = c.Get([]byte("remote"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("from store"), val)
+
+	err = b.Delete([]byte("foo"))
+	require.NoError(t, err)
+	assert.NotContains(t, s.data, "foo")
+
+	_, err = b.Get([]byte("foo"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWriteBack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	s := &memStore{data: make(map[string][]byte)}
+	b := NewWriteBack(c, s, 4)
+	defer b.Close()
+
+	for _, key := range []string{"a", "b", "c", "d", "e", "f"} {
+		err = b.Put([]byte(key), []byte(key))
+		require.NoError(t, err)
+	}
+
+	err = b.Delete([]byte("a"))
+	require.NoError(t, err)
+
+	err = b.Flush()
+	require.NoError(t, err)
+
+	s.mu.Lock()
+	assert.Len(t, s.data, 5)
+	assert.NotContains(t, s.data, "a")
+	s.mu.Unlock()
+}
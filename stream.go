@@ -0,0 +1,70 @@
+package lrudir
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// PutReader sets the value for the given key to everything read from r. The value is
+// copied into a staged file as it is read, so values larger than memory can be stored,
+// and the entry is only replaced once r is exhausted. If reading fails, the entry is
+// left as it was. The limit set by WithMaxValueBytes applies as for Put. Caches created
+// with WithContentAddressing read the whole value into memory to hash it.
+func (c *Cache) PutReader(key []byte, r io.Reader) (err error) {
+	err = c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+
+	ctx, sp := c.startSpan(context.Background(), "lrudir.PutReader", key)
+	defer func() { sp.end(err) }()
+
+	if c.contentAddressed {
+		value, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		return c.write(ctx, c.id(key), value, nil)
+	}
+	return c.writeFrom(ctx, c.id(key), &limitedValue{c: c, r: r}, nil)
+}
+
+// limitedValue applies the limit set by WithMaxValueBytes to a value that is read
+// incrementally, ending it at the limit or failing once it is exceeded
+type limitedValue struct {
+	c *Cache
+	r io.Reader
+	n int64 // number of bytes read so far
+}
+
+// Read implements io.Reader
+func (v *limitedValue) Read(p []byte) (int, error) {
+	max := v.c.maxValueBytes
+	if max <= 0 {
+		return v.r.Read(p)
+	}
+
+	if v.n >= max {
+		if v.c.truncateValues {
+			return 0, io.EOF
+		}
+		var b [1]byte
+		_, err := io.ReadFull(v.r, b[:])
+		if err == io.EOF {
+			return 0, io.EOF
+		}
+		if err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: value is more than the limit of %d bytes", ErrValueTooLarge, max)
+	}
+
+	if int64(len(p)) > max-v.n {
+		p = p[:max-v.n]
+	}
+	n, err := v.r.Read(p)
+	v.n += int64(n)
+	return n, err
+}
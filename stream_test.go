@@ -0,0 +1,70 @@
+package lrudir
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failingReader returns its data and then an error
+type failingReader struct {
+	r io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, errors.New("connection reset")
+	}
+	return n, err
+}
+
+func TestPutReader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxValueBytes(8))
+	require.NoError(t, err)
+
+	require.NoError(t, c.PutReader([]byte("a"), strings.NewReader("12345678")))
+	val, err := c.Get([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "12345678", string(val))
+
+	// a failed read leaves the entry as it was
+	err = c.PutReader([]byte("a"), &failingReader{strings.NewReader("xyz")})
+	assert.Error(t, err)
+	err = c.PutReader([]byte("a"), strings.NewReader("123456789"))
+	assert.True(t, errors.Is(err, ErrValueTooLarge))
+	val, err = c.Get([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "12345678", string(val))
+
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, s.Entries)
+	assert.EqualValues(t, 8, s.Bytes)
+	require.NoError(t, c.Verify())
+}
+
+func TestPutReaderTruncates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxValueBytes(4), WithTruncateValues())
+	require.NoError(t, err)
+
+	require.NoError(t, c.PutReader([]byte("a"), bytes.NewReader([]byte("123456789"))))
+	val, err := c.Get([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "1234", string(val))
+}
@@ -0,0 +1,17 @@
+package lrudir
+
+// GetString returns the value for the given string key. Converting the key to a byte
+// slice copies it, so the caller's string can never be aliased by the cache.
+func (c *Cache) GetString(key string) ([]byte, error) {
+	return c.Get([]byte(key))
+}
+
+// PutString sets the value for the given string key
+func (c *Cache) PutString(key string, value []byte) error {
+	return c.Put([]byte(key), value)
+}
+
+// DeleteString removes the given string key from the cache
+func (c *Cache) DeleteString(key string) error {
+	return c.Delete([]byte(key))
+}
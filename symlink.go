@@ -0,0 +1,46 @@
+package lrudir
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// PutLink makes a symbolic link to the file or directory at target the value for the
+// given key, so that large files that already live elsewhere can be tracked by the
+// cache without being copied into it. Get and the other methods that read values follow
+// the link, and a link whose target has gone is reported as missing. Deleting or
+// evicting the entry removes only the link, never the target. The size of the entry is
+// the size of the link itself rather than that of its target, since that is the space
+// it takes in the cache.
+func (c *Cache) PutLink(key []byte, target string) error {
+	err := c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+
+	// the link is read from inside the cache, so it must not be relative
+	target, err = filepath.Abs(target)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stat(target)
+	if err != nil {
+		return err
+	}
+
+	return c.update(func(x *state) error {
+		id := c.id(key)
+		tmp := c.tempPath(id)
+		os.RemoveAll(tmp)
+		err := os.Symlink(target, tmp)
+		if err != nil {
+			return err
+		}
+
+		err = c.commit(x, id, tmp, nil)
+		if err != nil {
+			return err
+		}
+		return c.evict(x, c.ns)
+	})
+}
@@ -0,0 +1,57 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutLink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	other, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(other)
+
+	target := filepath.Join(other, "artifact")
+	require.NoError(t, ioutil.WriteFile(target, []byte("large artifact"), 0777))
+
+	c, err := Create(dir, WithMaxEntries(1))
+	require.NoError(t, err)
+
+	err = c.PutLink([]byte("missing"), filepath.Join(other, "missing"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, c.PutLink([]byte("a"), target))
+	val, err := c.Get([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, "large artifact", string(val))
+
+	st, err := os.Lstat(c.path(c.id([]byte("a"))))
+	require.NoError(t, err)
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, st.Size(), s.Bytes)
+
+	// eviction removes the link but not its target
+	require.NoError(t, c.Put([]byte("b"), []byte("x")))
+	_, err = c.Get([]byte("a"))
+	assert.True(t, os.IsNotExist(err))
+	buf, err := ioutil.ReadFile(target)
+	require.NoError(t, err)
+	assert.Equal(t, "large artifact", string(buf))
+
+	// a link whose target has gone is reported as missing and can still be deleted
+	require.NoError(t, c.PutLink([]byte("a"), target))
+	require.NoError(t, os.Remove(target))
+	_, err = c.Get([]byte("a"))
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, c.Delete([]byte("a")))
+	require.NoError(t, c.Verify())
+}
@@ -0,0 +1,44 @@
+package lrudir
+
+import "context"
+
+// PutWithTags sets the value for the given key and attaches the given tags to it, so
+// that it can later be removed along with every other entry carrying the same tag by
+// InvalidateTag. Any tags from a previous value for the key are replaced.
+func (c *Cache) PutWithTags(key, value []byte, tags ...string) error {
+	err := c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+
+	return c.write(context.Background(), c.id(key), value, &meta{Tags: tags})
+}
+
+// InvalidateTag deletes every entry in this cache's namespace that carries the given
+// tag. The whole operation happens under a single acquisition of the lock. This is an
+// O(N) operation.
+func (c *Cache) InvalidateTag(tag string) error {
+	return c.update(func(x *state) error {
+		return c.invalidateTag(x, tag)
+	})
+}
+
+// invalidateTag deletes every entry in this cache's namespace carrying the given tag
+func (c *Cache) invalidateTag(x *state, tag string) error {
+	return c.deleteMatching(x, func(id []byte) (bool, error) {
+		if _, ok := c.key(id); !ok {
+			return false, nil
+		}
+
+		m, err := c.readMeta(id)
+		if err != nil {
+			return false, err
+		}
+		for _, t := range m.Tags {
+			if t == tag {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+}
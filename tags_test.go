@@ -0,0 +1,48 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInvalidateTag(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	err = c.PutWithTags(k1, nil, "dataset-x")
+	require.NoError(t, err)
+
+	err = c.PutWithTags(k2, nil, "dataset-y")
+	require.NoError(t, err)
+
+	err = c.PutWithTags(k3, nil, "dataset-y", "dataset-x")
+	require.NoError(t, err)
+
+	err = c.InvalidateTag("dataset-x")
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k2}, keys)
+
+	// a plain put clears the tags
+	err = c.Put(k2, nil)
+	require.NoError(t, err)
+
+	err = c.InvalidateTag("dataset-y")
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k2}, keys)
+}
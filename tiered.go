@@ -0,0 +1,147 @@
+package lrudir
+
+import (
+	"container/list"
+	"os"
+	"sync"
+)
+
+// TieredCache keeps the most recently used values in memory in front of a directory
+// cache. Hits in memory do not touch the disk at all; instead the entry is remembered
+// as touched, and its position in the on-disk order is brought up to date when it
+// leaves memory or when Sync is called. Values written by other processes are not
+// observed until the in-memory copy is evicted.
+type TieredCache struct {
+	disk *Cache
+	max  int
+
+	mu    sync.Mutex
+	ll    *list.List               // entries from most to least recently used
+	items map[string]*list.Element // elements of ll by key
+}
+
+// memEntry is a value held in memory
+type memEntry struct {
+	key     string
+	value   []byte
+	touched bool // whether the entry was used since its on-disk position was updated
+}
+
+// Tiered creates a cache that holds up to memEntries values in memory in front of c
+func Tiered(memEntries int, c *Cache) *TieredCache {
+	return &TieredCache{
+		disk:  c,
+		max:   memEntries,
+		ll:    list.New(),
+		items: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value for the given key, from memory if possible
+func (t *TieredCache) Get(key []byte) ([]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// memory is keyed the way the disk would see the key
+	key = t.disk.normalize(key)
+
+	if el, ok := t.items[string(key)]; ok {
+		t.ll.MoveToFront(el)
+		e := el.Value.(*memEntry)
+		e.touched = true
+		return append([]byte(nil), e.value...), nil
+	}
+
+	value, err := t.disk.Get(key)
+	if err != nil {
+		return nil, err
+	}
+
+	err = t.add(key, value)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Put sets the value for the given key in memory and on disk
+func (t *TieredCache) Put(key, value []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key = t.disk.normalize(key)
+
+	err := t.disk.Put(key, value)
+	if err != nil {
+		return err
+	}
+	return t.add(key, value)
+}
+
+// Delete removes the given key from memory and from disk
+func (t *TieredCache) Delete(key []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key = t.disk.normalize(key)
+
+	if el, ok := t.items[string(key)]; ok {
+		t.ll.Remove(el)
+		delete(t.items, string(key))
+	}
+	return t.disk.Delete(key)
+}
+
+// Sync brings the on-disk order up to date with every in-memory hit so far
+func (t *TieredCache) Sync() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// touch from least to most recently used so the relative order is preserved
+	for el := t.ll.Back(); el != nil; el = el.Prev() {
+		err := t.sync(el.Value.(*memEntry))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// add puts a copy of the value at the front of the in-memory list, evicting from
+// memory as necessary. The value has just been read from or written to disk, so the
+// on-disk order is already up to date for it.
+func (t *TieredCache) add(key, value []byte) error {
+	value = append([]byte(nil), value...)
+	if el, ok := t.items[string(key)]; ok {
+		t.ll.MoveToFront(el)
+		e := el.Value.(*memEntry)
+		e.value, e.touched = value, false
+		return nil
+	}
+
+	t.items[string(key)] = t.ll.PushFront(&memEntry{key: string(key), value: value})
+	for t.ll.Len() > t.max {
+		el := t.ll.Back()
+		t.ll.Remove(el)
+		e := el.Value.(*memEntry)
+		delete(t.items, e.key)
+		err := t.sync(e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sync moves a touched entry to the head of the on-disk list
+func (t *TieredCache) sync(e *memEntry) error {
+	if !e.touched {
+		return nil
+	}
+	err := t.disk.Touch([]byte(e.key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	e.touched = false
+	return nil
+}
@@ -0,0 +1,66 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTiered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	tc := Tiered(2, c)
+
+	err = tc.Put(k1, []byte("one"))
+	require.NoError(t, err)
+
+	err = tc.Put(k2, []byte("two"))
+	require.NoError(t, err)
+
+	// a memory hit does not change the on-disk order until synced
+	val, err := tc.Get(k1)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("one"), val)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k2, k1}, keys)
+
+	err = tc.Sync()
+	require.NoError(t, err)
+
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k1, k2}, keys)
+
+	// pushing an entry out of memory applies its pending touch
+	_, err = tc.Get(k2)
+	require.NoError(t, err)
+
+	err = tc.Put(k3, []byte("three"))
+	require.NoError(t, err)
+
+	err = tc.Put(k1, []byte("uno"))
+	require.NoError(t, err)
+
+	// k2 left memory when k1 was put back, so it went to the head on disk
+	keys, err = c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k2, k1, k3}, keys)
+
+	err = tc.Delete(k1)
+	require.NoError(t, err)
+
+	_, err = tc.Get(k1)
+	assert.True(t, os.IsNotExist(err))
+}
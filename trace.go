@@ -0,0 +1,109 @@
+package lrudir
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+)
+
+// Tracer is the hook through which a cache traces its operations, emitting a span for
+// each Get, Put, and Delete, and for each round of evictions. Package lruotel provides
+// one backed by OpenTelemetry, which lives in its own package so that lrudir itself
+// does not depend on any tracing library.
+type Tracer interface {
+	// Start begins a span with the given name and attributes as a child of any span
+	// carried by ctx, and returns a context that carries the new span
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is a span begun by a Tracer
+type Span interface {
+	// SetAttributes records further attributes on the span
+	SetAttributes(attrs ...Attribute)
+
+	// End finishes the span, recording err as its outcome if it is not nil
+	End(err error)
+}
+
+// Attribute is a named value recorded on a span. Values are strings, int64s, or bools.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// WithTracer makes the cache emit spans through t. Spans carry a hash of the key
+// rather than the key itself, the number of bytes involved, and whether a Get was a
+// hit. Use GetContext, PutContext, and DeleteContext to make the spans children of an
+// existing trace.
+func WithTracer(t Tracer) Option {
+	return func(c *Cache) {
+		c.tracer = t
+	}
+}
+
+// span is a possibly absent trace span
+type span struct {
+	s Span
+}
+
+// startSpan starts a span for an operation on the given key, which may be nil. It
+// returns an empty span if tracing is not enabled.
+func (c *Cache) startSpan(ctx context.Context, name string, key []byte) (context.Context, span) {
+	if c.tracer == nil {
+		return ctx, span{}
+	}
+
+	var attrs []Attribute
+	if key != nil {
+		sum := sha256.Sum256(key)
+		attrs = append(attrs, Attribute{"lrudir.key_hash", hex.EncodeToString(sum[:8])})
+	}
+	if c.ns != "" {
+		attrs = append(attrs, Attribute{"lrudir.namespace", c.ns})
+	}
+
+	ctx, s := c.tracer.Start(ctx, name, attrs...)
+	return ctx, span{s}
+}
+
+// setBytes records the size of the value involved
+func (sp span) setBytes(n int64) {
+	if sp.s != nil {
+		sp.s.SetAttributes(Attribute{"lrudir.bytes", n})
+	}
+}
+
+// setEvicted records the number of entries and bytes evicted
+func (sp span) setEvicted(entries, bytes int64) {
+	if sp.s != nil {
+		sp.s.SetAttributes(
+			Attribute{"lrudir.evicted_entries", entries},
+			Attribute{"lrudir.evicted_bytes", bytes},
+		)
+	}
+}
+
+// endGet ends the span for a Get, recording a miss rather than an error if the key was
+// not found
+func (sp span) endGet(value []byte, err error) {
+	if sp.s == nil {
+		return
+	}
+	sp.s.SetAttributes(Attribute{"lrudir.hit", err == nil})
+	if err == nil {
+		sp.setBytes(int64(len(value)))
+	}
+	if os.IsNotExist(err) {
+		err = nil
+	}
+	sp.end(err)
+}
+
+// end ends the span, recording err if it is not nil
+func (sp span) end(err error) {
+	if sp.s == nil {
+		return
+	}
+	sp.s.End(err)
+}
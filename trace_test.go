@@ -0,0 +1,79 @@
+package lrudir
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordedSpan keeps the name, attributes, and outcome of a span
+type recordedSpan struct {
+	name  string
+	attrs map[string]interface{}
+	err   error
+	rec   *recordingTracer
+}
+
+func (s *recordedSpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) End(err error) {
+	s.err = err
+	s.rec.spans = append(s.rec.spans, s)
+}
+
+// recordingTracer collects ended spans
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	s := &recordedSpan{name: name, attrs: make(map[string]interface{}), rec: t}
+	s.SetAttributes(attrs...)
+	return ctx, s
+}
+
+func TestTracing(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	tr := new(recordingTracer)
+	c, err := Create(dir, WithMaxEntries(1), WithTracer(tr))
+	require.NoError(t, err)
+
+	require.NoError(t, c.Put([]byte("k1"), []byte("abc")))
+	require.NoError(t, c.Put([]byte("k2"), []byte("de")))
+
+	_, err = c.Get([]byte("k2"))
+	require.NoError(t, err)
+	_, err = c.Get([]byte("k1"))
+	require.Error(t, err)
+
+	require.NoError(t, c.Delete([]byte("k2")))
+	assert.Error(t, c.Delete([]byte("k2")))
+
+	var names []string
+	for _, s := range tr.spans {
+		names = append(names, s.name)
+	}
+	assert.Equal(t, []string{"lrudir.Put", "lrudir.evict", "lrudir.Put", "lrudir.Get", "lrudir.Get", "lrudir.Delete", "lrudir.Delete"}, names)
+
+	assert.EqualValues(t, 3, tr.spans[0].attrs["lrudir.bytes"])
+	assert.NotEqual(t, "k1", tr.spans[0].attrs["lrudir.key_hash"])
+	assert.EqualValues(t, 1, tr.spans[1].attrs["lrudir.evicted_entries"])
+	assert.EqualValues(t, 3, tr.spans[1].attrs["lrudir.evicted_bytes"])
+	assert.Equal(t, true, tr.spans[3].attrs["lrudir.hit"])
+	assert.Equal(t, false, tr.spans[4].attrs["lrudir.hit"])
+
+	// a miss is not an error, but deleting a missing key is
+	assert.NoError(t, tr.spans[4].err)
+	assert.True(t, os.IsNotExist(tr.spans[6].err))
+}
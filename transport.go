@@ -0,0 +1,200 @@
+package lrudir
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Transport returns a round tripper that caches GET responses from inner in c. A
+// response is stored when it has status 200, does not say no-store, has no Vary
+// header, and either carries a max-age or can be revalidated by ETag or
+// Last-Modified. Fresh responses are served from disk; stale ones are revalidated with
+// a conditional request and served from disk again on 304 Not Modified. The cache
+// bounds total disk usage through its usual limits.
+func Transport(c *Cache, inner http.RoundTripper) http.RoundTripper {
+	if inner == nil {
+		inner = http.DefaultTransport
+	}
+	return &transport{c: c, inner: inner}
+}
+
+type transport struct {
+	c     *Cache
+	inner http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || cacheControl(req.Header).has("no-store") {
+		return t.inner.RoundTrip(req)
+	}
+
+	key := []byte("GET " + req.URL.String())
+	buf, err := t.c.Get(key)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var cached *http.Response
+	var expires time.Time
+	if err == nil {
+		cached, expires, err = decodeResponse(buf, req)
+		if err != nil {
+			// an unreadable entry is treated as a miss and overwritten below
+			cached = nil
+		}
+	}
+
+	if cached != nil && t.c.now().Before(expires) && !cacheControl(req.Header).has("no-cache") {
+		return cached, nil
+	}
+
+	out := req
+	if cached != nil {
+		out = req.Clone(req.Context())
+		if etag := cached.Header.Get("ETag"); etag != "" {
+			out.Header.Set("If-None-Match", etag)
+		}
+		if lm := cached.Header.Get("Last-Modified"); lm != "" {
+			out.Header.Set("If-Modified-Since", lm)
+		}
+	}
+
+	resp, err := t.inner.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		// take updated freshness information from the 304
+		for _, h := range []string{"Cache-Control", "Date", "Expires", "ETag"} {
+			if v := resp.Header.Get(h); v != "" {
+				cached.Header.Set(h, v)
+			}
+		}
+		err = t.store(key, cached)
+		if err != nil {
+			return nil, err
+		}
+		return cached, nil
+	}
+
+	if !cacheable(resp) {
+		if cached != nil {
+			err = t.c.Delete(key)
+			if err != nil && !os.IsNotExist(err) {
+				resp.Body.Close()
+				return nil, err
+			}
+		}
+		return resp, nil
+	}
+
+	err = t.store(key, resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+// store writes the response under key, along with the time until which it is fresh.
+// The body of resp is read fully and replaced so that resp can still be returned.
+func (t *transport) store(key []byte, resp *http.Response) error {
+	dump, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return err
+	}
+
+	// responses marked no-cache are stored but always revalidated
+	var expires time.Time
+	cc := cacheControl(resp.Header)
+	if age, ok := cc.maxAge(); ok && !cc.has("no-cache") {
+		expires = t.c.now().Add(age)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(strconv.FormatInt(expires.UnixNano(), 10))
+	buf.WriteByte('\n')
+	buf.Write(dump)
+	return t.c.Put(key, buf.Bytes())
+}
+
+// decodeResponse parses an entry written by store
+func decodeResponse(buf []byte, req *http.Request) (*http.Response, time.Time, error) {
+	r := bufio.NewReader(bytes.NewReader(buf))
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	nanos, err := strconv.ParseInt(strings.TrimSpace(line), 10, 64)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return resp, time.Unix(0, nanos), nil
+}
+
+// cacheable returns true if the response may be stored
+func cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Vary") != "" {
+		return false
+	}
+	cc := cacheControl(resp.Header)
+	if cc.has("no-store") {
+		return false
+	}
+	if _, ok := cc.maxAge(); ok {
+		return true
+	}
+	return resp.Header.Get("ETag") != "" || resp.Header.Get("Last-Modified") != ""
+}
+
+// directives holds the comma-separated directives of a Cache-Control header
+type directives []string
+
+// cacheControl parses the Cache-Control header
+func cacheControl(h http.Header) directives {
+	var d directives
+	for _, v := range h.Values("Cache-Control") {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				d = append(d, strings.ToLower(part))
+			}
+		}
+	}
+	return d
+}
+
+// has returns true if the directive is present
+func (d directives) has(name string) bool {
+	for _, part := range d {
+		if part == name {
+			return true
+		}
+	}
+	return false
+}
+
+// maxAge returns the max-age directive, if present
+func (d directives) maxAge() (time.Duration, bool) {
+	for _, part := range d {
+		if strings.HasPrefix(part, "max-age=") {
+			secs, err := strconv.Atoi(strings.TrimPrefix(part, "max-age="))
+			if err == nil && secs >= 0 {
+				return time.Duration(secs) * time.Second, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	var hits, revalidations int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=3600")
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				revalidations++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+		case "/nostore":
+			w.Header().Set("Cache-Control", "no-store")
+		}
+		w.Write([]byte("body of " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	client := &http.Client{Transport: Transport(c, nil)}
+	get := func(path string) string {
+		resp, err := client.Get(srv.URL + path)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	// fresh responses are served from disk
+	assert.Equal(t, "body of /fresh", get("/fresh"))
+	assert.Equal(t, "body of /fresh", get("/fresh"))
+	assert.Equal(t, 1, hits)
+
+	// responses with only an ETag are revalidated every time
+	assert.Equal(t, "body of /etag", get("/etag"))
+	assert.Equal(t, "body of /etag", get("/etag"))
+	assert.Equal(t, 3, hits)
+	assert.Equal(t, 1, revalidations)
+
+	// no-store responses are never written
+	assert.Equal(t, "body of /nostore", get("/nostore"))
+	assert.Equal(t, "body of /nostore", get("/nostore"))
+	assert.Equal(t, 5, hits)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.Len(t, keys, 2)
+}
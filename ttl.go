@@ -0,0 +1,110 @@
+package lrudir
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// PutWithTTL sets the value for the given key and arranges for it to expire after the
+// given duration. Expired entries behave as if they were absent and are removed the
+// next time they are read. A non-positive ttl means the entry never expires. If the
+// cache was opened with WithSlidingExpiry, each read renews the ttl.
+func (c *Cache) PutWithTTL(key, value []byte, ttl time.Duration) error {
+	return c.putWithTTL(key, value, ttl, c.sliding)
+}
+
+// PutWithSlidingTTL is like PutWithTTL but the entry expires only once ttl has passed
+// without it being read, regardless of WithSlidingExpiry.
+func (c *Cache) PutWithSlidingTTL(key, value []byte, ttl time.Duration) error {
+	return c.putWithTTL(key, value, ttl, true)
+}
+
+// WithSlidingExpiry makes every read of an entry written by PutWithTTL renew its ttl,
+// so that entries expire once they have not been read for that long rather than a
+// fixed time after they were written.
+func WithSlidingExpiry() Option {
+	return func(c *Cache) {
+		c.sliding = true
+	}
+}
+
+// putWithTTL implements PutWithTTL and PutWithSlidingTTL
+func (c *Cache) putWithTTL(key, value []byte, ttl time.Duration, sliding bool) error {
+	var m meta
+	if ttl > 0 {
+		m.Expires = c.now().Add(ttl).UnixNano()
+		if sliding {
+			m.Sliding = int64(ttl)
+		}
+	}
+	return c.putWithMeta(key, value, &m)
+}
+
+// PutWithExpiry sets the value for the given key and arranges for it to expire at the
+// given moment, for values that become invalid at a known time. It is otherwise like
+// PutWithTTL, except that reads never extend the expiry. A zero time means the entry
+// never expires, and a time in the past means the entry is never returned.
+func (c *Cache) PutWithExpiry(key, value []byte, expires time.Time) error {
+	var m meta
+	if !expires.IsZero() {
+		m.Expires = expires.UnixNano()
+	}
+	return c.putWithMeta(key, value, &m)
+}
+
+// putWithMeta sets the value and metadata for the given key and evicts as necessary
+func (c *Cache) putWithMeta(key, value []byte, m *meta) error {
+	err := c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+
+	return c.write(context.Background(), c.id(key), value, m)
+}
+
+// checkExpiry removes the entry for the given identifier if it has expired or been
+// invalidated by Bump, and returns an error satisfying os.IsNotExist if so. Otherwise it renews the expiry of an entry
+// with a sliding ttl, since callers check expiry just before reading an entry. It must
+// be called with the lock held.
+func (c *Cache) checkExpiry(id []byte) error {
+	m, err := c.readMeta(id)
+	if err != nil {
+		return err
+	}
+	reason, err := c.invalid(m)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		if m.Sliding == 0 {
+			return nil
+		}
+		m.Expires = c.now().Add(time.Duration(m.Sliding)).UnixNano()
+		return c.writeMeta(id, m)
+	}
+	if m.isZero() {
+		// without a metadata file there may be no entry at all
+		_, err = os.Stat(c.path(id))
+		if err != nil {
+			return err
+		}
+	}
+
+	x, err := c.state()
+	if err != nil {
+		return err
+	}
+
+	err = c.discard(x, id, EventEvict, reason)
+	if err != nil {
+		return err
+	}
+
+	err = c.setState(x)
+	if err != nil {
+		return err
+	}
+
+	return &os.PathError{Op: "get", Path: c.path(id), Err: os.ErrNotExist}
+}
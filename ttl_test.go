@@ -0,0 +1,123 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutWithTTL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.PutWithTTL([]byte("short"), []byte("x"), time.Nanosecond)
+	require.NoError(t, err)
+
+	err = c.PutWithTTL([]byte("long"), []byte("y"), time.Hour)
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+
+	_, err = c.Get([]byte("short"))
+	assert.True(t, os.IsNotExist(err))
+
+	val, err := c.Get([]byte("long"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("y"), val)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("long")}, keys)
+}
+
+func TestPutWithExpiry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.PutWithExpiry([]byte("past"), []byte("x"), time.Now().Add(-time.Second))
+	require.NoError(t, err)
+
+	err = c.PutWithExpiry([]byte("future"), []byte("y"), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	err = c.PutWithExpiry([]byte("never"), []byte("z"), time.Time{})
+	require.NoError(t, err)
+
+	_, err = c.Get([]byte("past"))
+	assert.True(t, os.IsNotExist(err))
+
+	val, err := c.Get([]byte("future"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("y"), val)
+
+	m, err := c.readMeta(c.id([]byte("never")))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), m.Expires)
+}
+
+func TestSlidingExpiry(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithSlidingExpiry())
+	require.NoError(t, err)
+
+	k1, k2 := []byte("key1"), []byte("key2")
+
+	err = c.PutWithTTL(k1, []byte("one"), time.Hour)
+	require.NoError(t, err)
+
+	err = c.PutWithExpiry(k2, []byte("two"), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+
+	m1, err := c.readMeta(c.id(k1))
+	require.NoError(t, err)
+	m2, err := c.readMeta(c.id(k2))
+	require.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+
+	_, err = c.Get(k1)
+	require.NoError(t, err)
+	_, err = c.Get(k2)
+	require.NoError(t, err)
+
+	m, err := c.readMeta(c.id(k1))
+	require.NoError(t, err)
+	assert.True(t, m.Expires > m1.Expires)
+
+	m, err = c.readMeta(c.id(k2))
+	require.NoError(t, err)
+	assert.Equal(t, m2.Expires, m.Expires)
+
+	// fixed expiry is the default
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	err = c.PutWithTTL(k1, []byte("one"), time.Hour)
+	require.NoError(t, err)
+
+	m, err = c.readMeta(c.id(k1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), m.Sliding)
+
+	err = c.PutWithSlidingTTL(k1, []byte("one"), time.Hour)
+	require.NoError(t, err)
+
+	m, err = c.readMeta(c.id(k1))
+	require.NoError(t, err)
+	assert.Equal(t, int64(time.Hour), m.Sliding)
+}
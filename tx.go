@@ -0,0 +1,296 @@
+package lrudir
+
+import (
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Lines in the journal that record a transaction. Each operation is given on a line
+// of its own, and the transaction is only rolled forward by recovery if the commit
+// line that follows them was written in full.
+const (
+	txPut    = "put "
+	txDelete = "delete "
+	txCommit = "commit"
+)
+
+// Tx collects the changes made by a function passed to Update, so that they can be
+// applied together, or makes the reads for a function passed to View.
+type Tx struct {
+	c     *Cache
+	view  bool     // whether this is a read transaction for View
+	ops   []txOp   // changes to apply, for Update
+	reads [][]byte // identifiers read, to be promoted once the view ends
+}
+
+// txOp is a single change made in a transaction
+type txOp struct {
+	id     []byte
+	value  []byte // the new value, for puts
+	delete bool
+}
+
+// Put sets the value for the given key when the transaction is applied
+func (tx *Tx) Put(key, value []byte) error {
+	if tx.view {
+		return errors.New("cannot put in a read transaction")
+	}
+
+	err := tx.c.ValidateKey(key)
+	if err != nil {
+		return err
+	}
+
+	n, err := tx.c.fit(int64(len(value)))
+	if err != nil {
+		return err
+	}
+
+	value = append([]byte(nil), value[:n]...)
+	tx.ops = append(tx.ops, txOp{id: tx.c.id(key), value: value})
+	return nil
+}
+
+// Delete removes the given key when the transaction is applied. Deleting a key that is
+// not in the cache does nothing.
+func (tx *Tx) Delete(key []byte) error {
+	if tx.view {
+		return errors.New("cannot delete in a read transaction")
+	}
+	if len(key) == 0 {
+		return errors.New("cannot delete the empty key")
+	}
+	tx.ops = append(tx.ops, txOp{id: tx.c.id(key), delete: true})
+	return nil
+}
+
+// Update calls f to collect a set of puts and deletes and then applies them together
+// under one acquisition of the lock. Other processes observe either all of the changes
+// or none of them, even if this process crashes part way through: every value is
+// staged and the transaction recorded in the journal before anything is changed, so
+// that recovery can finish the transaction, or discard it if it was never recorded.
+// Nothing is changed if f returns an error. Entries are evicted as needed once all of
+// the changes have been applied.
+func (c *Cache) Update(f func(tx *Tx) error) error {
+	tx := &Tx{c: c}
+	err := f(tx)
+	if err != nil {
+		return err
+	}
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	return c.update(func(x *state) error {
+		err := c.stageTx(tx.ops)
+		if err != nil {
+			return err
+		}
+
+		err = c.applyTx(x, tx.ops, 0)
+		if err != nil {
+			// finish the transaction as recovery would after a crash, or leave the
+			// journal for the next Open to do so
+			c.debug("transaction failed, recovering", "err", err)
+			if rerr := c.recoverJournal(); rerr != nil {
+				return err
+			}
+			// recovery saved the state, which x no longer matches
+			saved, err := c.state()
+			if err != nil {
+				return err
+			}
+			*x = *saved
+		}
+		return c.evict(x, c.ns)
+	})
+}
+
+// Get returns the value for the given key as of the start of the read transaction.
+// Expired entries are reported as missing but left for a later write to remove, and
+// sliding expiries are not renewed. Entries read are moved to the head of the list once
+// View returns.
+func (tx *Tx) Get(key []byte) (value []byte, err error) {
+	if !tx.view {
+		return nil, errors.New("cannot get in a transaction for Update")
+	}
+	if len(key) == 0 {
+		return nil, errors.New("cannot get the empty key")
+	}
+
+	c := tx.c
+	id := c.id(key)
+	defer func() { c.countGet(id, err) }()
+
+	m, err := c.readMeta(id)
+	if err != nil {
+		return nil, err
+	}
+	reason, err := c.invalid(m)
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return nil, &os.PathError{Op: "get", Path: c.path(id), Err: os.ErrNotExist}
+	}
+	err = checkComplete(m)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err = c.readValue(c.path(id))
+	if err != nil {
+		return nil, err
+	}
+	tx.reads = append(tx.reads, id)
+
+	if len(value) == 0 && m.Negative {
+		return nil, ErrNegativeEntry
+	}
+	return value, nil
+}
+
+// Keys gets all keys in the cache, sorted from most to least recently used, as of the
+// start of the read transaction
+func (tx *Tx) Keys() ([][]byte, error) {
+	if !tx.view {
+		return nil, errors.New("cannot list keys in a transaction for Update")
+	}
+	return tx.c.keys()
+}
+
+// View calls f with a read transaction that holds a shared lock throughout, so that
+// every Get and Keys made through it sees the same snapshot of the cache, with no
+// writes or evictions in between. It must not call other methods of the cache. Entries
+// read are moved to the head of the list after the shared lock is released, as for
+// batched promotion.
+func (c *Cache) View(f func(tx *Tx) error) error {
+	tx := &Tx{c: c, view: true}
+	err := c.view(func() error {
+		return f(tx)
+	})
+	if err != nil {
+		return err
+	}
+
+	var flush bool
+	for _, id := range tx.reads {
+		if !c.promotedRecently(id) && c.recordAccess(id) {
+			flush = true
+		}
+	}
+	if flush || (!c.batched && len(tx.reads) > 0) {
+		return c.flushAccesses()
+	}
+	return nil
+}
+
+// stageTx writes the values for a transaction and then records it in the journal. Once
+// this returns, recovery will roll the transaction forward. It must be called with the
+// lock held.
+func (c *Cache) stageTx(ops []txOp) (err error) {
+	defer func() {
+		if err != nil {
+			for i := range ops {
+				os.Remove(c.txStagePath(i))
+			}
+		}
+	}()
+
+	var buf []byte
+	for i, op := range ops {
+		if op.delete {
+			buf = append(buf, txDelete...)
+		} else {
+			err = ioutil.WriteFile(c.txStagePath(i), op.value, 0777)
+			if err != nil {
+				return err
+			}
+			buf = append(buf, txPut...)
+		}
+		buf = append(buf, base64.StdEncoding.EncodeToString(op.id)...)
+		buf = append(buf, '\n')
+	}
+	buf = append(buf, txCommit+"\n"...)
+	return c.appendJournal(buf)
+}
+
+// applyTx applies the operations in a transaction from the given index onwards, once
+// their values have been staged by stageTx. Deletes of entries that are already gone
+// are skipped. It must be called with the lock held.
+func (c *Cache) applyTx(x *state, ops []txOp, start int) error {
+	for i := start; i < len(ops); i++ {
+		op := ops[i]
+		if !op.delete {
+			err := c.commit(x, op.id, c.txStagePath(i), nil)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		_, err := os.Lstat(c.path(op.id))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		err = c.delete(x, op.id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayTx rolls forward a transaction that was interrupted part way through. A put
+// whose staged value is gone has been applied, and so have the operations before it,
+// so only the operations after the last such put are applied again. It must be called
+// with the lock held.
+func (c *Cache) replayTx(x *state, ops []txOp) error {
+	start := 0
+	for i, op := range ops {
+		if op.delete {
+			continue
+		}
+		_, err := os.Lstat(c.txStagePath(i))
+		if os.IsNotExist(err) {
+			start = i + 1
+		} else if err != nil {
+			return err
+		}
+	}
+	return c.applyTx(x, ops, start)
+}
+
+// txStagePath gets the path at which the value for the i-th operation in a transaction
+// is staged
+func (c *Cache) txStagePath(i int) string {
+	return c.internalPath("tx~" + strconv.Itoa(i) + "~tmp")
+}
+
+// parseTxOp parses a line from the journal that records an operation in a transaction
+func parseTxOp(line string) (txOp, bool) {
+	var op txOp
+	switch {
+	case strings.HasPrefix(line, txPut):
+		line = strings.TrimPrefix(line, txPut)
+	case strings.HasPrefix(line, txDelete):
+		line = strings.TrimPrefix(line, txDelete)
+		op.delete = true
+	default:
+		return op, false
+	}
+
+	id, err := base64.StdEncoding.DecodeString(line)
+	if err != nil || len(id) == 0 {
+		return op, false
+	}
+	op.id = id
+	return op, true
+}
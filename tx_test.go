@@ -0,0 +1,146 @@
+package lrudir
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("old"), []byte("x"))
+	require.NoError(t, err)
+
+	err = c.Update(func(tx *Tx) error {
+		require.NoError(t, tx.Put([]byte("data"), []byte("abc")))
+		require.NoError(t, tx.Put([]byte("manifest"), []byte("def")))
+		require.NoError(t, tx.Delete([]byte("old")))
+		require.NoError(t, tx.Delete([]byte("missing")))
+		return nil
+	})
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("manifest"), []byte("data")}, keys)
+
+	val, err := c.Get([]byte("data"))
+	require.NoError(t, err)
+	assert.Equal(t, "abc", string(val))
+
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, s.Entries)
+	assert.EqualValues(t, 6, s.Bytes)
+
+	// nothing is applied if the function fails
+	boom := errors.New("boom")
+	err = c.Update(func(tx *Tx) error {
+		require.NoError(t, tx.Put([]byte("other"), []byte("x")))
+		return boom
+	})
+	assert.Equal(t, boom, err)
+
+	_, err = c.Get([]byte("other"))
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, c.Verify())
+}
+
+func TestUpdateRecovery(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("a"), []byte("1"))
+	require.NoError(t, err)
+
+	// crash after recording a transaction and applying only its first put
+	ops := []txOp{
+		{id: c.id([]byte("b")), value: []byte("2")},
+		{id: c.id([]byte("a")), delete: true},
+		{id: c.id([]byte("c")), value: []byte("3")},
+	}
+	require.NoError(t, c.lock())
+	x, err := c.state()
+	require.NoError(t, err)
+	require.NoError(t, c.stageTx(ops))
+	require.NoError(t, c.applyTx(x, ops[:1], 0))
+	crash(c)
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("c"), []byte("b")}, keys)
+	require.NoError(t, c.Verify())
+
+	// a transaction that was never fully recorded is discarded
+	require.NoError(t, c.lock())
+	require.NoError(t, ioutil.WriteFile(c.txStagePath(0), []byte("4"), 0777))
+	require.NoError(t, c.appendJournal([]byte(txPut+"ZA==\n")))
+	crash(c)
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	_, err = os.Stat(c.txStagePath(0))
+	assert.True(t, os.IsNotExist(err))
+	_, err = c.Get([]byte("d"))
+	assert.True(t, os.IsNotExist(err))
+	require.NoError(t, c.Verify())
+}
+
+func TestView(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	for _, k := range []string{"a", "b", "c"} {
+		err = c.Put([]byte(k), []byte("value-"+k))
+		require.NoError(t, err)
+	}
+
+	err = c.View(func(tx *Tx) error {
+		val, err := tx.Get([]byte("a"))
+		require.NoError(t, err)
+		assert.Equal(t, "value-a", string(val))
+
+		_, err = tx.Get([]byte("missing"))
+		assert.True(t, os.IsNotExist(err))
+
+		// reads are not applied until the view ends
+		keys, err := tx.Keys()
+		require.NoError(t, err)
+		assert.EqualValues(t, [][]byte{[]byte("c"), []byte("b"), []byte("a")}, keys)
+
+		assert.Error(t, tx.Put([]byte("d"), []byte("x")))
+		return nil
+	})
+	require.NoError(t, err)
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("a"), []byte("c"), []byte("b")}, keys)
+
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, s.Hits)
+	assert.EqualValues(t, 1, s.Misses)
+}
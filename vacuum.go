@@ -0,0 +1,68 @@
+package lrudir
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Vacuum removes everything that Compact does, and also value files that are not in the
+// list along with any pointers and metadata they have. Processes that crash part way
+// through a write can leave such files behind. It returns the number of files removed
+// and their total size, counting each directory value as one file. Vacuum assumes that
+// every file in the directory belongs to the cache, so it must not be used on a cache
+// that shares its directory with other files. It holds the lock throughout.
+func (c *Cache) Vacuum() (files int, reclaimed int64, err error) {
+	err = c.updateAllShards(func(x *state) error {
+		files, reclaimed, err = c.vacuum()
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	c.debug("vacuumed", "dir", c.Dir, "files", files, "bytes", reclaimed)
+	return files, reclaimed, nil
+}
+
+// vacuum implements Vacuum. It must be called with the lock held.
+func (c *Cache) vacuum() (int, int64, error) {
+	live := make(map[string]bool)
+	err := c.walk(func(id []byte) error {
+		live[c.escape(id)] = true
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	names, err := c.valueNames()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var files int
+	var reclaimed int64
+	for _, name := range names {
+		if live[name] {
+			continue
+		}
+		path := filepath.Join(c.Dir, name)
+		size, err := valueSize(path)
+		if err != nil {
+			return 0, 0, err
+		}
+		err = os.RemoveAll(path)
+		if err != nil {
+			return 0, 0, err
+		}
+		files++
+		reclaimed += size
+	}
+
+	// with the values gone, their pointers and metadata are dead records, as are any
+	// blobs that only they linked to
+	n, size, err := c.compact()
+	if err != nil {
+		return 0, 0, err
+	}
+	return files + n, reclaimed + size, nil
+}
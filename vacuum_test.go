@@ -0,0 +1,50 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVacuum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("key"), []byte("value"))
+	require.NoError(t, err)
+
+	// a value that was moved into place just before a crash, with its metadata
+	err = ioutil.WriteFile(filepath.Join(dir, "orphan"), []byte("12345678"), 0777)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(c.internalPath("orphan~meta"), []byte("{}"), 0777)
+	require.NoError(t, err)
+	err = ioutil.WriteFile(c.internalPath("key~tmp"), []byte("123"), 0777)
+	require.NoError(t, err)
+
+	files, reclaimed, err := c.Vacuum()
+	require.NoError(t, err)
+	assert.Equal(t, 3, files)
+	assert.Equal(t, int64(13), reclaimed)
+
+	_, err = os.Stat(filepath.Join(dir, "orphan"))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, c.Verify())
+	val, err := c.Get([]byte("key"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", string(val))
+
+	// nothing is left to remove
+	files, reclaimed, err = c.Vacuum()
+	require.NoError(t, err)
+	assert.Equal(t, 0, files)
+	assert.Equal(t, int64(0), reclaimed)
+}
@@ -0,0 +1,50 @@
+package lrudir
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidKey is returned when writing a key that the cache will not store. Errors
+// that wrap it can be detected with errors.Is, and are always of type *KeyError.
+var ErrInvalidKey = errors.New("invalid key")
+
+// KeyError describes why a key was rejected.
+type KeyError struct {
+	Len    int    // length of the key in bytes, after normalization
+	Reason string // human-readable explanation
+}
+
+func (e *KeyError) Error() string {
+	return ErrInvalidKey.Error() + ": " + e.Reason
+}
+
+// Unwrap makes errors.Is(err, ErrInvalidKey) true
+func (e *KeyError) Unwrap() error {
+	return ErrInvalidKey
+}
+
+// WithMaxKeyBytes limits keys to at most n bytes, after normalization. Puts of longer
+// keys fail with a *KeyError. Zero means no limit, which is the default: keys too long
+// for a filename are stored under a hash of the key.
+func WithMaxKeyBytes(n int) Option {
+	return func(c *Cache) {
+		c.maxKeyBytes = n
+	}
+}
+
+// ValidateKey checks whether the given key could be written to this cache, returning a
+// *KeyError if not. Every operation that writes a key performs the same check first.
+func (c *Cache) ValidateKey(key []byte) error {
+	if len(key) == 0 {
+		return &KeyError{Reason: "the empty key cannot be stored"}
+	}
+	n := len(c.normalize(key))
+	if c.maxKeyBytes > 0 && n > c.maxKeyBytes {
+		return &KeyError{
+			Len:    n,
+			Reason: fmt.Sprintf("key is %d bytes but the limit is %d", n, c.maxKeyBytes),
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package lrudir
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxKeyBytes(4))
+	require.NoError(t, err)
+
+	assert.NoError(t, c.ValidateKey([]byte("abcd")))
+
+	err = c.ValidateKey(nil)
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+
+	err = c.ValidateKey([]byte("abcde"))
+	var ke *KeyError
+	require.True(t, errors.As(err, &ke))
+	assert.Equal(t, 5, ke.Len)
+
+	// writes are rejected before touching the filesystem
+	err = c.Put([]byte("abcde"), []byte("one"))
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+
+	err = c.Put([]byte("abcd"), []byte("one"))
+	require.NoError(t, err)
+
+	err = c.Rename([]byte("abcd"), []byte("abcde"))
+	assert.True(t, errors.Is(err, ErrInvalidKey))
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("abcd")}, keys)
+
+	// without a limit only the empty key is invalid
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("abcde"), []byte("two"))
+	require.NoError(t, err)
+}
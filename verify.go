@@ -0,0 +1,186 @@
+package lrudir
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+)
+
+// WithStrictOpen makes Open run a full Verify and fail if the cache is not
+// consistent. By default Open only checks the ends of the list, which is cheap.
+func WithStrictOpen() Option {
+	return func(c *Cache) {
+		c.strictOpen = true
+	}
+}
+
+// Verify checks the structure of the whole cache: that the list is consistent in both
+// directions and contains each entry once, that every entry has a value, and that the
+// usage recorded in the state file matches the entries present. It returns an error
+// wrapping ErrCorrupt describing the first problem found, which Repair can usually fix.
+// This is an O(N) operation that holds a shared lock.
+func (c *Cache) Verify() error {
+	return c.view(c.verify)
+}
+
+// Repair rebuilds the list from its forward and backward pointers, as is done on Open
+// after a crash, so that it is consistent in both directions and contains each entry
+// exactly once, and then recounts the usage. Entries whose value is missing are dropped
+// along with their pointers and metadata, and reported as evictions with the reason
+// EvictCorrupt. Value files that are not in the list at all are left alone, and are
+// removed by Vacuum. What was repaired is logged to the logger given to WithLogger.
+// This is an O(N) operation that holds the lock, and the locks for every shard,
+// throughout.
+func (c *Cache) Repair() error {
+	return c.updateAllShards(c.repairList)
+}
+
+// repairList implements Repair. It must be called with the lock held.
+func (c *Cache) repairList(x *state) error {
+	relinked, dropped, err := c.rebuildList(nil)
+	if err != nil {
+		return err
+	}
+	x.Usage, err = c.count()
+	if err != nil {
+		return err
+	}
+	for _, id := range dropped {
+		// the value is already gone, so all that is left is to report the eviction
+		c.record(EventEvict, id, EvictCorrupt)
+		c.countEviction(id, EvictCorrupt)
+		c.logEviction(id, 0, reasonCorrupt)
+	}
+	c.debug("repaired list", "dir", c.Dir, "relinked", relinked, "dropped", len(dropped))
+	return nil
+}
+
+// verify implements Verify. It must be called with the lock held.
+func (c *Cache) verify() error {
+	x, err := c.state()
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[string]*usage)
+	var prev []byte
+	err = c.walk(func(id []byte) error {
+		back, err := c.readPtr(c.prevPtr(id))
+		if os.IsNotExist(err) {
+			return corrupt("entry %q has no prev pointer", id)
+		}
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(back, prev) {
+			return corrupt("prev pointer of %q is %q but should be %q", id, back, prev)
+		}
+
+		size, err := c.chargedSize(c.path(id))
+		if os.IsNotExist(err) {
+			return corrupt("entry %q has no value", id)
+		}
+		if err != nil {
+			return err
+		}
+
+		if isHashed(c.escape(id)) {
+			recorded, err := ioutil.ReadFile(c.keyPtr(id))
+			if err != nil || !bytes.Equal(recorded, id) {
+				return corrupt("entry %q does not have its full key recorded", id)
+			}
+		}
+
+		ns, _ := splitID(id)
+		u, ok := counts[ns]
+		if !ok {
+			u = new(usage)
+			counts[ns] = u
+		}
+		m, err := c.readMeta(id)
+		if err != nil {
+			return err
+		}
+
+		inodes, err := c.entryInodes(id, c.path(id))
+		if err != nil {
+			return err
+		}
+
+		u.Entries++
+		u.Bytes += size
+		u.Extra += m.extraCost()
+		u.Inodes += inodes
+
+		prev = id
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	tail, err := c.readPtr(c.prevPtr(nil))
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(tail, prev) {
+		return corrupt("tail pointer is %q but the last entry is %q", tail, prev)
+	}
+
+	for ns, u := range x.Usage {
+		if u.Entries == 0 && u.Bytes == 0 && u.Extra == 0 && u.Inodes == 0 {
+			continue
+		}
+		if counts[ns] == nil || *counts[ns] != *u {
+			return corrupt("recorded usage for namespace %q does not match its entries", ns)
+		}
+	}
+	for ns, u := range counts {
+		if x.Usage[ns] == nil {
+			return corrupt("no usage recorded for namespace %q with %d entries", ns, u.Entries)
+		}
+	}
+	return nil
+}
+
+// checkSentinels makes sure that the head and tail pointers exist and agree with the
+// entries they point to. Missing sentinels are recreated if the list is otherwise empty,
+// since there is then nothing to lose. It must be called with the lock held.
+func (c *Cache) checkSentinels() error {
+	head, herr := c.readPtr(c.nextPtr(nil))
+	tail, terr := c.readPtr(c.prevPtr(nil))
+	if os.IsNotExist(herr) && os.IsNotExist(terr) {
+		names, err := c.valueNames()
+		if err != nil {
+			return err
+		}
+		if len(names) > 0 {
+			return corrupt("head and tail pointers are missing")
+		}
+		c.debug("recreating missing head and tail pointers", "dir", c.Dir)
+		return c.link(nil, nil)
+	}
+	if herr != nil {
+		return herr
+	}
+	if terr != nil {
+		return terr
+	}
+	if (len(head) == 0) != (len(tail) == 0) {
+		return corrupt("exactly one of the head and tail pointers is empty")
+	}
+	if len(head) == 0 {
+		return nil
+	}
+
+	back, err := c.readPtr(c.prevPtr(head))
+	if err != nil || len(back) != 0 {
+		return corrupt("head entry %q does not point back to the head", head)
+	}
+
+	fwd, err := c.readPtr(c.nextPtr(tail))
+	if err != nil || len(fwd) != 0 {
+		return corrupt("tail entry %q does not point forward to the tail", tail)
+	}
+	return nil
+}
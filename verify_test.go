@@ -0,0 +1,155 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	k1, k2, k3 := []byte("key1"), []byte("key2"), []byte("key3")
+
+	for _, k := range [][]byte{k1, k2, k3} {
+		err = c.Put(k, []byte("x"))
+		require.NoError(t, err)
+	}
+
+	err = c.Verify()
+	require.NoError(t, err)
+
+	_, err = Open(dir, WithStrictOpen())
+	require.NoError(t, err)
+
+	// break the back pointer in the middle of the list
+	err = ioutil.WriteFile(c.prevPtr(k2), k1, 0777)
+	require.NoError(t, err)
+
+	err = c.Verify()
+	assert.ErrorIs(t, err, ErrCorrupt)
+
+	// a plain open only checks the ends of the list
+	_, err = Open(dir)
+	require.NoError(t, err)
+
+	_, err = Open(dir, WithStrictOpen())
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestOpenMissingSentinels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	// an empty cache whose sentinels were lost is repaired on open
+	err = os.Remove(c.nextPtr(nil))
+	require.NoError(t, err)
+	err = os.Remove(c.prevPtr(nil))
+	require.NoError(t, err)
+
+	c, err = Open(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("foo"), nil)
+	require.NoError(t, err)
+
+	// a non-empty cache whose sentinels were lost cannot be opened
+	err = os.Remove(c.nextPtr(nil))
+	require.NoError(t, err)
+	err = os.Remove(c.prevPtr(nil))
+	require.NoError(t, err)
+
+	_, err = Open(dir)
+	assert.ErrorIs(t, err, ErrCorrupt)
+}
+
+func TestSquashDuplicate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	a, b, k := []byte("a"), []byte("b"), []byte("c")
+	for _, key := range [][]byte{a, b, k} {
+		require.NoError(t, c.Put(key, key))
+	}
+
+	// linking b in a second time, as if detaching it had failed, is noticed and undone
+	require.NoError(t, c.lock())
+	require.NoError(t, c.attachHead(c.id(b)))
+	c.unlock()
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{b, k, a}, keys)
+	require.NoError(t, c.Verify())
+}
+
+func TestRepair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	a, b, k := []byte("a"), []byte("b"), []byte("c")
+	for _, key := range [][]byte{a, b, k} {
+		require.NoError(t, c.Put(key, key))
+	}
+
+	// make a appear again after c, and lose the value of b
+	require.NoError(t, ioutil.WriteFile(c.nextPtr(c.id(a)), c.id(k), 0777))
+	require.NoError(t, os.Remove(c.path(c.id(b))))
+	assert.ErrorIs(t, c.Verify(), ErrCorrupt)
+
+	require.NoError(t, c.Repair())
+	require.NoError(t, c.Verify())
+
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{k, a}, keys)
+}
+
+func TestDetachHalfLinked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	a, b, k := []byte("a"), []byte("b"), []byte("c")
+	for _, key := range [][]byte{a, b, k} {
+		require.NoError(t, c.Put(key, key))
+	}
+
+	require.NoError(t, c.lock())
+	assert.True(t, os.IsNotExist(c.detach(c.id([]byte("missing")))))
+	c.unlock()
+
+	// reads fail loudly on an entry with only one pointer
+	require.NoError(t, os.Remove(c.prevPtr(c.id(b))))
+	assert.ErrorIs(t, c.Touch(b), ErrCorrupt)
+
+	// while writing it again repairs the list first
+	require.NoError(t, c.Put(b, []byte("again")))
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{b, k, a}, keys)
+	require.NoError(t, c.Verify())
+}
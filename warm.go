@@ -0,0 +1,65 @@
+package lrudir
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Warm asks the operating system to load the values for the given keys into its page
+// cache, so that the first reads after a reboot do not all go to disk. Where the
+// platform supports posix_fadvise the values are loaded in the background, and
+// elsewhere they are read and discarded. Entries are not moved in the list, and keys
+// that are not in the cache are skipped. No lock is held, so warming never delays
+// other operations.
+func (c *Cache) Warm(keys [][]byte) error {
+	for _, key := range keys {
+		if len(key) == 0 {
+			return errors.New("cannot warm the empty key")
+		}
+		err := warmPath(c.Path(key))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// WarmAll is like Warm for the limit most recently used keys in this cache's namespace
+func (c *Cache) WarmAll(limit int) error {
+	keys, err := c.NewestN(limit)
+	if err != nil {
+		return err
+	}
+	return c.Warm(keys)
+}
+
+// warmPath loads the value at the given path, which may be a directory, into the page
+// cache
+func warmPath(path string) error {
+	return filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		return warmFile(p)
+	})
+}
+
+// warmFile loads a single file into the page cache
+func warmFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if fadvise(f, adviseWillNeed) == nil {
+		return nil
+	}
+	_, err = io.Copy(io.Discard, f)
+	return err
+}
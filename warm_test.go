@@ -0,0 +1,41 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarm(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	err = c.Put([]byte("a"), []byte("abc"))
+	require.NoError(t, err)
+
+	src, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(src)
+	err = ioutil.WriteFile(filepath.Join(src, "file"), []byte("xyz"), 0777)
+	require.NoError(t, err)
+	err = c.PutDir([]byte("d"), src)
+	require.NoError(t, err)
+
+	err = c.Warm([][]byte{[]byte("a"), []byte("d"), []byte("missing")})
+	require.NoError(t, err)
+	err = c.WarmAll(10)
+	require.NoError(t, err)
+
+	// warming leaves the order alone
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("d"), []byte("a")}, keys)
+}
@@ -0,0 +1,255 @@
+package lrudir
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const (
+	// eventLog is the name of the journal of changes within the metadata directory
+	eventLog = "events"
+
+	// maxEventLog is the size beyond which the journal is rotated
+	maxEventLog = 1 << 20
+)
+
+// EventOp identifies the kind of change reported by Watch
+type EventOp int
+
+// The kinds of change reported by Watch
+const (
+	EventPut    EventOp = iota + 1 // a value was written
+	EventDelete                    // an entry was removed explicitly
+	EventEvict                     // an entry was evicted to respect limits, or expired
+)
+
+var eventOpNames = map[EventOp]string{
+	EventPut:    "put",
+	EventDelete: "delete",
+	EventEvict:  "evict",
+}
+
+// String returns the name of the event kind as it appears in the journal
+func (op EventOp) String() string {
+	return eventOpNames[op]
+}
+
+// Event describes a change to a cache. If Err is set then the watcher has failed and
+// the channel is closed after this event.
+type Event struct {
+	Op     EventOp
+	Key    []byte
+	Reason EvictReason // why the entry was evicted, for EventEvict
+	Err    error
+}
+
+// record appends a change to the journal if one is being kept. The journal only
+// exists once Watch has been called on the cache, so caches that are never watched pay
+// nothing. Failures are ignored because the cache itself is consistent at this point;
+// watchers may then miss the event. The reason is recorded for evictions. It must be
+// called with the lock held.
+func (c *Cache) record(op EventOp, id []byte, reason EvictReason) {
+	path := c.internalPath(eventLog)
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0777)
+	if err != nil {
+		return
+	}
+
+	line := op.String() + " " + base64.StdEncoding.EncodeToString(id)
+	if op == EventEvict && reason != 0 {
+		line += " " + reason.String()
+	}
+	line += "\n"
+	_, err = f.Write([]byte(line))
+	if err != nil {
+		f.Close()
+		return
+	}
+
+	st, err := f.Stat()
+	f.Close()
+	if err == nil && st.Size() > maxEventLog {
+		// watchers finish reading the old journal before moving to the new one
+		os.Rename(path, path+"~old")
+		ioutil.WriteFile(path, nil, 0777)
+	}
+}
+
+// Watch reports changes to this cache's namespace as they happen, including those made
+// by other processes sharing the directory. Once any process has called Watch, every
+// process appends its changes to a journal in the metadata directory, which watchers
+// follow using filesystem notifications. Events that happened before Watch was called
+// are not reported. Call the returned function, or close the cache, to stop watching;
+// the channel is then closed.
+func (c *Cache) Watch() (<-chan Event, func()) {
+	ch := make(chan Event, 64)
+	fail := func(err error) (<-chan Event, func()) {
+		ch <- Event{Err: err}
+		close(ch)
+		return ch, func() {}
+	}
+
+	path := c.internalPath(eventLog)
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0777)
+	if err != nil {
+		return fail(err)
+	}
+	_, err = f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return fail(err)
+	}
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		f.Close()
+		return fail(err)
+	}
+	err = w.Add(filepath.Dir(path))
+	if err != nil {
+		w.Close()
+		f.Close()
+		return fail(err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(ch)
+		// the journal is reopened when it is rotated, so close whichever is open last
+		defer func() { f.Close() }()
+
+		var pending []byte
+		send := func(ev Event) bool {
+			select {
+			case ch <- ev:
+				return true
+			case <-done:
+				return false
+			}
+		}
+
+		// drain reads everything new in the journal and sends the complete records
+		drain := func() bool {
+			buf, err := ioutil.ReadAll(f)
+			if err != nil {
+				send(Event{Err: err})
+				return false
+			}
+			pending = append(pending, buf...)
+			for {
+				i := bytes.IndexByte(pending, '\n')
+				if i < 0 {
+					return true
+				}
+				line := pending[:i]
+				pending = pending[i+1:]
+				if ev, ok := c.parseEvent(line); ok && !send(ev) {
+					return false
+				}
+			}
+		}
+
+		for {
+			select {
+			case <-done:
+				return
+			case err, ok := <-w.Errors:
+				if ok {
+					send(Event{Err: err})
+				}
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if ev.Name != path {
+					continue
+				}
+				switch {
+				case ev.Op&fsnotify.Write != 0:
+					if !drain() {
+						return
+					}
+				case ev.Op&fsnotify.Create != 0:
+					// the journal was rotated, so finish the old one and start on the new
+					if !drain() {
+						return
+					}
+					f.Close()
+					f, err = os.Open(path)
+					if err != nil {
+						send(Event{Err: err})
+						return
+					}
+					pending = nil
+					if !drain() {
+						return
+					}
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			w.Close()
+			wg.Wait()
+		})
+	}
+	if !c.onClose(stop) {
+		stop()
+	}
+	return ch, stop
+}
+
+// parseEvent decodes a journal record, returning false if it is malformed or belongs
+// to another namespace
+func (c *Cache) parseEvent(line []byte) (Event, bool) {
+	i := bytes.IndexByte(line, ' ')
+	if i < 0 {
+		return Event{}, false
+	}
+
+	var op EventOp
+	for k, name := range eventOpNames {
+		if name == string(line[:i]) {
+			op = k
+		}
+	}
+	if op == 0 {
+		return Event{}, false
+	}
+
+	// evictions give their reason after the identifier, except in older journals
+	fields := bytes.Fields(line[i+1:])
+	if len(fields) == 0 {
+		return Event{}, false
+	}
+	id, err := base64.StdEncoding.DecodeString(string(fields[0]))
+	if err != nil {
+		return Event{}, false
+	}
+
+	var reason EvictReason
+	if len(fields) > 1 {
+		reason.UnmarshalText(fields[1])
+	}
+
+	key, ok := c.key(id)
+	if !ok {
+		return Event{}, false
+	}
+	return Event{Op: op, Key: key, Reason: reason}, true
+}
@@ -0,0 +1,60 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir)
+	require.NoError(t, err)
+
+	events, stop := c.Namespace("watched").Watch()
+	defer stop()
+
+	// changes made through another handle, as another process would, are reported
+	other, err := Open(dir, WithMaxEntries(1))
+	require.NoError(t, err)
+	ns := other.Namespace("watched")
+
+	err = ns.Put([]byte("a"), []byte("1"))
+	require.NoError(t, err)
+
+	err = other.Put([]byte("unwatched"), nil)
+	require.NoError(t, err)
+
+	err = ns.Put([]byte("b"), []byte("2"))
+	require.NoError(t, err)
+
+	err = ns.Delete([]byte("b"))
+	require.NoError(t, err)
+
+	expected := []Event{
+		{Op: EventPut, Key: []byte("a")},
+		{Op: EventEvict, Key: []byte("a"), Reason: EvictCapacity},
+		{Op: EventPut, Key: []byte("b")},
+		{Op: EventDelete, Key: []byte("b")},
+	}
+	for _, want := range expected {
+		select {
+		case ev := <-events:
+			require.NoError(t, ev.Err)
+			assert.Equal(t, want, ev)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %v %q", want.Op, want.Key)
+		}
+	}
+
+	stop()
+	_, ok := <-events
+	assert.False(t, ok)
+}
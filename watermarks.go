@@ -0,0 +1,76 @@
+package lrudir
+
+import "context"
+
+// WithWatermarks makes eviction work in batches rather than one entry at a time on
+// every Put once the cache is full. Once usage passes the high watermark, given as a
+// fraction of each limit and quota, the least recently used entries are evicted until
+// usage is back down to the low watermark. With WithJanitor the janitor does this on
+// each sweep, and a Put evicts only if it takes usage over the limit itself, still
+// evicting down to the low watermark. A fraction that is not between zero and one
+// leaves that watermark at the limits themselves, and low is at most high. For example,
+// WithWatermarks(0.85, 0.95) starts evicting at 95% of the limits and stops at 85%.
+func WithWatermarks(low, high float64) Option {
+	return func(c *Cache) {
+		c.highWater = fraction(high)
+		c.lowWater = fraction(low)
+		if c.lowWater > c.highWater {
+			c.lowWater = c.highWater
+		}
+	}
+}
+
+// fraction gets the watermark for f, which is one unless f is between zero and one
+func fraction(f float64) float64 {
+	if f <= 0 || f > 1 {
+		return 1
+	}
+	return f
+}
+
+// scale gets the given fraction of the limits. Limits that are set stay at least one,
+// so that they are not mistaken for no limit. A fraction of zero is a watermark that was
+// never set, which leaves the limits as they are.
+func (l limits) scale(f float64) limits {
+	if f <= 0 || f >= 1 {
+		return l
+	}
+	scale := func(n int64) int64 {
+		if n <= 0 {
+			return n
+		}
+		m := int64(float64(n) * f)
+		if m < 1 {
+			m = 1
+		}
+		return m
+	}
+	return limits{
+		entries: scale(l.entries),
+		bytes:   scale(l.bytes),
+		cost:    scale(l.cost),
+		inodes:  scale(l.inodes),
+	}
+}
+
+// evictToWatermark evicts from every namespace with a quota, and from the cache as a
+// whole, wherever usage has passed the high watermark. The janitor calls it on each
+// sweep.
+func (c *Cache) evictToWatermark() error {
+	if c.highWater <= 0 || c.highWater >= 1 {
+		return nil
+	}
+	return c.update(func(x *state) error {
+		err := c.evictAbove(context.Background(), x, c.ns, c.highWater)
+		if err != nil {
+			return err
+		}
+		for ns := range c.quotas {
+			err = c.evictAbove(context.Background(), x, ns, c.highWater)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
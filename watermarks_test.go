@@ -0,0 +1,61 @@
+package lrudir
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatermarks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxEntries(10), WithWatermarks(0.5, 0.8))
+	require.NoError(t, err)
+
+	for i := 0; i < 8; i++ {
+		require.NoError(t, c.Put([]byte(fmt.Sprint(i)), []byte("x")))
+	}
+	s, err := c.Stats()
+	require.NoError(t, err)
+	assert.EqualValues(t, 8, s.Entries)
+
+	// passing the high watermark evicts down to the low one in one go
+	require.NoError(t, c.Put([]byte("8"), []byte("x")))
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.EqualValues(t, [][]byte{[]byte("8"), []byte("7"), []byte("6"), []byte("5"), []byte("4")}, keys)
+	require.NoError(t, c.Verify())
+}
+
+func TestWatermarksJanitor(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c, err := Create(dir, WithMaxEntries(10), WithWatermarks(0.5, 0.8), WithJanitor(time.Millisecond))
+	require.NoError(t, err)
+	defer c.Close()
+
+	// puts only evict at the limit itself, so it is the janitor that evicts these
+	for i := 0; i < 9; i++ {
+		require.NoError(t, c.Put([]byte(fmt.Sprint(i)), []byte("x")))
+	}
+
+	var s Stats
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		s, err = c.Stats()
+		require.NoError(t, err)
+		if s.Entries <= 5 {
+			break
+		}
+	}
+	assert.EqualValues(t, 5, s.Entries)
+}
@@ -0,0 +1,141 @@
+package lrudir
+
+import (
+	"encoding/json"
+	"os"
+)
+
+// metaXattr is the extended attribute of a value file that holds the metadata for its
+// entry, for caches created with WithXattrMetadata
+const metaXattr = "user.lrudir.meta"
+
+// xattrMeta is the content of the metadata attribute. Value files can be hardlinked to
+// several entries, by Copy and content addressing, and to other caches, by Snapshot and
+// Merge, so the attribute is only written while the value has a single link. It records
+// which entry it belongs to, and a metadata file, where there is one, takes precedence,
+// so that an entry whose value became shared after the attribute was written keeps its
+// metadata in a file of its own instead.
+type xattrMeta struct {
+	Owner string `json:"owner"` // escaped name of the entry the metadata belongs to
+	Meta  *meta  `json:"meta"`
+}
+
+// WithXattrMetadata makes the cache keep the metadata for each entry, such as its
+// expiry, tags, and access count, in an extended attribute of its value file rather
+// than in a file of its own in the metadata directory, which halves the number of files
+// for entries with metadata. This is chosen when the cache is created and persisted in
+// the state file. If the filesystem does not support extended attributes, which is
+// also the case on platforms other than Linux, the option is ignored. Entries whose
+// value cannot hold the attribute, such as links made by PutLink or values shared with
+// other entries, still keep their metadata in a file.
+func WithXattrMetadata() Option {
+	return func(c *Cache) {
+		c.xattrMeta = true
+	}
+}
+
+// xattrSupported returns true if extended attributes can be set on the given directory
+func xattrSupported(dir string) bool {
+	const probe = "user.lrudir.probe"
+	if setXattr(dir, probe, nil) != nil {
+		return false
+	}
+	removeXattr(dir, probe)
+	return true
+}
+
+// readMetaXattr loads the metadata for the given identifier from the attribute of its
+// value file, returning false if the attribute is missing or belongs to another entry.
+// Callers look for a metadata file first, since that overrides the attribute.
+func (c *Cache) readMetaXattr(id []byte) (*meta, bool, error) {
+	owner, m, err := c.loadXattr(id)
+	if err != nil || owner != c.escape(id) {
+		return nil, false, err
+	}
+	return m.clone(), true, nil
+}
+
+// writeMetaXattr stores the metadata for the given identifier in the attribute of its
+// value file, or removes the attribute if there is nothing to store, returning false if
+// the metadata must be stored in a file instead. In that case it also reports whether
+// the value holds an attribute for this entry that the file must override, even if the
+// metadata is empty. It must be called with the lock held.
+func (c *Cache) writeMetaXattr(id []byte, m *meta) (stored, shadowed bool, err error) {
+	path := c.path(id)
+	st, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return false, false, nil
+	}
+	if err != nil {
+		return false, false, err
+	}
+	if st.Mode()&os.ModeSymlink != 0 {
+		return false, false, nil
+	}
+
+	owner, _, err := c.loadXattr(id)
+	if err != nil {
+		return false, false, err
+	}
+
+	// a value with other links may belong to another entry or another cache, which
+	// would see any change to the attribute, so it is left as it is
+	if linkCount(st) != 1 {
+		return false, owner == c.escape(id), nil
+	}
+
+	if m.isZero() {
+		err = removeXattr(path, metaXattr)
+		if err != nil && !os.IsNotExist(err) {
+			return false, false, err
+		}
+		return true, false, nil
+	}
+
+	buf, err := json.Marshal(xattrMeta{Owner: c.escape(id), Meta: m})
+	if err != nil {
+		return false, false, err
+	}
+	err = setXattr(path, metaXattr, buf)
+	if err != nil {
+		// the value cannot hold the attribute, perhaps because it is too large, so make
+		// sure that no stale attribute hides the file written instead
+		c.debug("falling back to a metadata file", "path", path, "err", err)
+		if owner == c.escape(id) {
+			rmerr := removeXattr(path, metaXattr)
+			if rmerr != nil && !os.IsNotExist(rmerr) {
+				return false, false, rmerr
+			}
+		}
+		return false, false, nil
+	}
+	return true, false, nil
+}
+
+// loadXattr reads the attribute of the value file for the given identifier, returning
+// an empty owner if there is none
+func (c *Cache) loadXattr(id []byte) (string, *meta, error) {
+	buf, err := getXattr(c.path(id), metaXattr)
+	if os.IsNotExist(err) {
+		return "", nil, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	var x xattrMeta
+	err = json.Unmarshal(buf, &x)
+	if err != nil {
+		return "", nil, &os.PathError{Op: "read metadata", Path: c.path(id), Err: err}
+	}
+	return x.Owner, x.Meta, nil
+}
+
+// copyXattr copies the metadata attribute, if any, from one file to another, ignoring
+// failures since the attribute is only an optimization where it is not supported
+func copyXattr(src, dst string) {
+	buf, err := getXattr(src, metaXattr)
+	if err == nil {
+		setXattr(dst, metaXattr, buf)
+	}
+}
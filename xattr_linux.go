@@ -0,0 +1,94 @@
+package lrudir
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// getXattr reads the named extended attribute of the file at the given path, without
+// following symbolic links. A missing attribute, or one that the file cannot have, is
+// reported as not existing.
+func getXattr(path, name string) ([]byte, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	n, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+
+	// the attribute may grow between asking for its size and reading it
+	for {
+		size, _, errno := syscall.Syscall6(syscall.SYS_LGETXATTR, uintptr(unsafe.Pointer(p)),
+			uintptr(unsafe.Pointer(n)), 0, 0, 0, 0)
+		if errno == syscall.ENOTSUP {
+			return nil, &os.PathError{Op: "getxattr", Path: path, Err: os.ErrNotExist}
+		}
+		if errno != 0 {
+			return nil, xattrError("getxattr", path, errno)
+		}
+		buf := make([]byte, size+1)
+		read, _, errno := syscall.Syscall6(syscall.SYS_LGETXATTR, uintptr(unsafe.Pointer(p)),
+			uintptr(unsafe.Pointer(n)), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)), 0, 0)
+		if errno == syscall.ERANGE {
+			continue
+		}
+		if errno != 0 {
+			return nil, xattrError("getxattr", path, errno)
+		}
+		return buf[:read], nil
+	}
+}
+
+// setXattr sets the named extended attribute of the file at the given path, without
+// following symbolic links
+func setXattr(path, name string, value []byte) error {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	n, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	var v unsafe.Pointer
+	if len(value) > 0 {
+		v = unsafe.Pointer(&value[0])
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_LSETXATTR, uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(n)), uintptr(v), uintptr(len(value)), 0, 0)
+	if errno != 0 {
+		return xattrError("setxattr", path, errno)
+	}
+	return nil
+}
+
+// removeXattr removes the named extended attribute of the file at the given path,
+// without following symbolic links. A missing attribute is reported as not existing.
+func removeXattr(path, name string) error {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	n, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_LREMOVEXATTR, uintptr(unsafe.Pointer(p)),
+		uintptr(unsafe.Pointer(n)), 0)
+	if errno != 0 {
+		return xattrError("removexattr", path, errno)
+	}
+	return nil
+}
+
+// xattrError wraps an error from a system call on extended attributes, reporting a
+// missing attribute as not existing
+func xattrError(op, path string, errno syscall.Errno) error {
+	if errno == syscall.ENODATA {
+		return &os.PathError{Op: op, Path: path, Err: os.ErrNotExist}
+	}
+	return &os.PathError{Op: op, Path: path, Err: errno}
+}
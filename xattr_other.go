@@ -0,0 +1,31 @@
+//go:build !linux
+// +build !linux
+
+package lrudir
+
+import (
+	"errors"
+	"os"
+)
+
+// errNoXattr is returned on platforms where extended attributes are not supported
+var errNoXattr = errors.New("extended attributes are not supported on this platform")
+
+// getXattr reads the named extended attribute of the file at the given path, without
+// following symbolic links. A missing attribute, or one that the file cannot have, is
+// reported as not existing.
+func getXattr(path, name string) ([]byte, error) {
+	return nil, &os.PathError{Op: "getxattr", Path: path, Err: os.ErrNotExist}
+}
+
+// setXattr sets the named extended attribute of the file at the given path, without
+// following symbolic links
+func setXattr(path, name string, value []byte) error {
+	return errNoXattr
+}
+
+// removeXattr removes the named extended attribute of the file at the given path,
+// without following symbolic links. A missing attribute is reported as not existing.
+func removeXattr(path, name string) error {
+	return errNoXattr
+}
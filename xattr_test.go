@@ -0,0 +1,117 @@
+package lrudir
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXattrMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := Create(dir, WithXattrMetadata(), WithClock(clk))
+	require.NoError(t, err)
+	if !c.xattrMeta {
+		t.Skip("extended attributes are not supported here")
+	}
+
+	a, b := []byte("a"), []byte("b")
+	require.NoError(t, c.PutWithTags(a, []byte("1"), "t"))
+	_, err = os.Stat(c.metaPtr(c.id(a)))
+	assert.True(t, os.IsNotExist(err))
+
+	// the choice is persisted for other handles
+	c, err = Open(dir, WithClock(clk))
+	require.NoError(t, err)
+	assert.True(t, c.xattrMeta)
+
+	m, err := c.readMeta(c.id(a))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t"}, m.Tags)
+
+	// a copy that shares the value file keeps its own metadata
+	clk.t = clk.t.Add(time.Hour)
+	require.NoError(t, c.Copy(a, b))
+	require.NoError(t, c.Touch(b))
+	mb, err := c.readMeta(c.id(b))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t"}, mb.Tags)
+	assert.Equal(t, clk.t.UnixNano(), mb.Used)
+
+	ma, err := c.readMeta(c.id(a))
+	require.NoError(t, err)
+	assert.Equal(t, m.Used, ma.Used)
+
+	// metadata follows a renamed entry
+	require.NoError(t, c.Rename(a, []byte("c")))
+	m, err = c.readMeta(c.id([]byte("c")))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t"}, m.Tags)
+
+	require.NoError(t, c.InvalidateTag("t"))
+	keys, err := c.Keys()
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+	require.NoError(t, c.Verify())
+}
+
+func TestXattrMetadataSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	clk := &fakeClock{t: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)}
+	c, err := Create(dir, WithXattrMetadata(), WithClock(clk))
+	require.NoError(t, err)
+	if !c.xattrMeta {
+		t.Skip("extended attributes are not supported here")
+	}
+
+	a := []byte("a")
+	require.NoError(t, c.PutWithTags(a, []byte("1"), "t"))
+	before, err := c.readMeta(c.id(a))
+	require.NoError(t, err)
+
+	// the snapshot shares the value file but not the metadata
+	snap := filepath.Join(dir, "..", filepath.Base(dir)+"-snapshot")
+	defer os.RemoveAll(snap)
+	require.NoError(t, c.Snapshot(snap))
+	s, err := Open(snap, WithClock(clk))
+	require.NoError(t, err)
+
+	clk.t = clk.t.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		_, err = s.Get(a)
+		require.NoError(t, err)
+	}
+	ms, err := s.readMeta(s.id(a))
+	require.NoError(t, err)
+	assert.EqualValues(t, 3, ms.Accesses)
+	assert.Equal(t, []string{"t"}, ms.Tags)
+
+	after, err := c.readMeta(c.id(a))
+	require.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	// clearing metadata on a shared value is not undone by the attribute
+	require.NoError(t, c.update(func(x *state) error {
+		return c.writeMeta(c.id(a), nil)
+	}))
+	m, err := c.readMeta(c.id(a))
+	require.NoError(t, err)
+	assert.True(t, m.isZero())
+
+	ms, err = s.readMeta(s.id(a))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t"}, ms.Tags)
+	require.NoError(t, c.Verify())
+	require.NoError(t, s.Verify())
+}